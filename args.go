@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxArgfileDepth is how deeply @file arguments may name further @files,
+// which catches a file that names itself.
+const maxArgfileDepth = 10
+
+// expandArgfiles replaces each argument of the form @file in args with the
+// arguments read from file by splitArgfile, expanding any @file among those
+// in turn. An argument starting @@ stands for itself with one @ removed.
+// Arguments after a lone -- are left alone.
+func expandArgfiles(args []string, depth int) ([]string, error) {
+	var out []string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return append(out, args[i:]...), nil
+		case strings.HasPrefix(arg, "@@"):
+			out = append(out, arg[1:])
+		case strings.HasPrefix(arg, "@") && len(arg) > 1:
+			if depth >= maxArgfileDepth {
+				return nil, fmt.Errorf("%s: @files nested more than %d deep", arg, maxArgfileDepth)
+			}
+			data, err := os.ReadFile(arg[1:])
+			if err != nil {
+				return nil, err
+			}
+			words, err := splitArgfile(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", arg[1:], err)
+			}
+			words, err = expandArgfiles(words, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, words...)
+		default:
+			out = append(out, arg)
+		}
+	}
+	return out, nil
+}
+
+// splitArgfile splits the contents of an @file into arguments. Arguments are
+// separated by white space, including newlines, and may be quoted with ' or
+// " to hold spaces. Backslashes are kept as they are, for Windows paths.
+// Lines starting with # are comments.
+func splitArgfile(s string) ([]string, error) {
+	var (
+		args  []string
+		b     strings.Builder
+		inArg bool
+		quote rune
+	)
+	lineStart := true
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		if lineStart && quote == 0 && c == '#' {
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			continue
+		}
+		lineStart = c == '\n' && quote == 0
+		switch {
+		case quote == '\'' && c == '\'', quote == '"' && c == '"':
+			quote = 0
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote, inArg = c, true
+		case quote == 0 && strings.ContainsRune(" \t\r\n", c):
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		default:
+			b.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return args, nil
+}
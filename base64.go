@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+)
+
+// base64LineLength is how many characters of -base64-binary output go on
+// each line, as in MIME.
+const base64LineLength = 76
+
+// printBase64 prints the binary contents of name, sample followed by the
+// rest of body, base64-encoded for -base64-binary, and returns the number
+// of bytes read.
+func (r *runner) printBase64(name string, sample []byte, body io.Reader) (int64, error) {
+	rest, err := io.ReadAll(body)
+	if err != nil {
+		return 0, err
+	}
+	data := append(sample[:len(sample):len(sample)], rest...)
+	enc := base64.StdEncoding.EncodeToString(data)
+	var b bytes.Buffer
+	for len(enc) > base64LineLength {
+		b.WriteString(enc[:base64LineLength])
+		b.WriteByte('\n')
+		enc = enc[base64LineLength:]
+	}
+	if enc != "" {
+		b.WriteString(enc)
+		b.WriteByte('\n')
+	}
+	if r.countingTokens() {
+		r.tokens += int64(r.tok.count(b.Bytes()))
+	}
+	r.addNote("binary, " + r.size(int64(len(data))) + ", base64")
+	return r.printBlock(name, nil, &prefiltered{Reader: bytes.NewReader(b.Bytes()), n: int64(len(data))})
+}
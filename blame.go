@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// blameFilter starts each line with who last changed it and in which
+// commit, for -blame. prefixes holds the prefix for each line of the file,
+// in order; lines past the end of it, which git didn't see, get none.
+type blameFilter struct {
+	prefixes []string
+	i        int
+}
+
+func (b *blameFilter) filter(line []byte) []byte {
+	if b.i >= len(b.prefixes) {
+		return line
+	}
+	prefix := b.prefixes[b.i]
+	b.i++
+	if text, _ := splitEOL(line); len(text) == 0 {
+		prefix = strings.TrimRight(prefix, " ")
+	}
+	return append([]byte(prefix), line...)
+}
+
+func (b *blameFilter) flush() []byte { return nil }
+
+// useBlame sets r.opts to start each line of the file at path with its
+// -blame prefix, and returns a func that puts the usual options back. Files
+// git can't blame, such as ones outside a work tree or not yet added, are
+// printed as they are, and so is every file after the first -blame-max.
+func (r *runner) useBlame(path string) func() {
+	if r.reformatsJSON(path) || r.rendersNotebook(path) || r.rendersPDF(path) {
+		// Rewritten, so their lines aren't the ones git knows.
+		return func() {}
+	}
+	if r.blamed >= r.opts.blameMax {
+		if r.blamed == r.opts.blameMax && !r.opts.quiet {
+			fmt.Fprintf(os.Stderr, "Warning: -blame-max %d reached; printing the other files without blame\n", r.opts.blameMax)
+		}
+		r.blamed++
+		return func() {}
+	}
+	prefixes, err := blamePrefixes(path)
+	if err != nil {
+		if r.opts.verbose {
+			fmt.Fprintf(os.Stderr, "Not blaming %s: %v\n", path, err)
+		}
+		return func() {}
+	}
+	r.blamed++
+	saved := r.opts
+	opts := *saved
+	opts.blameLines = prefixes
+	r.opts = &opts
+	return func() { r.opts = saved }
+}
+
+// blamePrefixes runs git blame on the file at path and returns the -blame
+// prefix for each of its lines: the author, padded to the longest in the
+// file, and the abbreviated commit, as in "Ann Lee 1a2b3c4 | ".
+func blamePrefixes(path string) ([]string, error) {
+	cmd := exec.Command("git", "blame", "--porcelain", "--", filepath.Base(path))
+	cmd.Dir = filepath.Dir(path)
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("%s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, err
+	}
+	// Each line of the file is a header line starting with its commit,
+	// then, the first time that commit appears, lines about it, then the
+	// line itself after a tab.
+	authors := make(map[string]string)
+	var commit string
+	var commits []string
+	width := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "\t") {
+			commits = append(commits, commit)
+			width = max(width, utf8.RuneCountInString(authors[commit]))
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch {
+		case (len(key) == 40 || len(key) == 64) && strings.Trim(key, "0123456789abcdef") == "":
+			commit = key
+		case key == "author":
+			authors[commit] = value
+		}
+	}
+	prefixes := make([]string, len(commits))
+	for i, c := range commits {
+		pad := strings.Repeat(" ", width-utf8.RuneCountInString(authors[c]))
+		prefixes[i] = authors[c] + pad + " " + c[:7] + " | "
+	}
+	return prefixes, nil
+}
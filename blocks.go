@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// dedupMaxBlock is the longest block, in lines, that -dedup-blocks looks for
+// repeats of, which bounds the work it does per line.
+const dedupMaxBlock = 200
+
+// blockDedupFilter collapses a block of at least min lines repeated back to
+// back for -dedup-blocks: the block is printed once, followed by a
+// "[repeated block x N]" line for the N copies after it. A block must hold
+// a line that isn't blank, so runs of blank lines are left alone. The whole
+// file is held until the end, since a repeat can't be known until it is
+// complete.
+type blockDedupFilter struct {
+	min   int
+	lines [][]byte
+}
+
+func (d *blockDedupFilter) filter(line []byte) []byte {
+	d.lines = append(d.lines, bytes.Clone(line))
+	return nil
+}
+
+func (d *blockDedupFilter) flush() []byte {
+	var b bytes.Buffer
+	lines := d.lines
+	for i := 0; i < len(lines); {
+		k, n := repeatedBlock(lines[i:], d.min)
+		if n == 0 {
+			b.Write(lines[i])
+			i++
+			continue
+		}
+		for _, line := range lines[i : i+k] {
+			b.Write(line)
+		}
+		fmt.Fprintf(&b, "[repeated block x %d]\n", n)
+		i += k * (n + 1)
+	}
+	d.lines = nil
+	return b.Bytes()
+}
+
+// repeatedBlock looks for a block of at least min lines at the start of
+// lines that is repeated right after itself, and returns its length and the
+// number of repeats after the first copy, or 0, 0 if there is none. The
+// shortest such block wins.
+func repeatedBlock(lines [][]byte, min int) (k, n int) {
+	for k = min; k <= dedupMaxBlock && 2*k <= len(lines); k++ {
+		if !bytes.Equal(lines[0], lines[k]) || !sameLines(lines[:k], lines[k:2*k]) || blankLines(lines[:k]) {
+			continue
+		}
+		for n = 1; (n+2)*k <= len(lines) && sameLines(lines[:k], lines[(n+1)*k:(n+2)*k]); n++ {
+		}
+		return k, n
+	}
+	return 0, 0
+}
+
+// sameLines reports whether a and b hold the same lines.
+func sameLines(a, b [][]byte) bool {
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// blankLines reports whether every one of lines is blank or whitespace.
+func blankLines(lines [][]byte) bool {
+	for _, line := range lines {
+		if len(bytes.TrimSpace(line)) > 0 {
+			return false
+		}
+	}
+	return true
+}
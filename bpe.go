@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A tokenizer counts tokens the way a particular model family does.
+type tokenizer interface {
+	// count returns the number of tokens in text.
+	count(text []byte) int
+	// cut returns the length of the longest prefix of text that is at most
+	// max tokens, and the number of tokens in it.
+	cut(text []byte, max int) (n, tokens int)
+	// String names the tokenizer in messages.
+	String() string
+}
+
+// heuristicTokenizer estimates bytesPerToken bytes per token.
+type heuristicTokenizer struct{}
+
+func (heuristicTokenizer) count(text []byte) int {
+	return int(estimateTokens(int64(len(text))))
+}
+
+func (heuristicTokenizer) cut(text []byte, max int) (int, int) {
+	n := min(len(text), max*bytesPerToken)
+	return n, int(estimateTokens(int64(n)))
+}
+
+func (heuristicTokenizer) String() string { return "estimated" }
+
+// space is the character class for Unicode whitespace, which is what \s
+// means in tiktoken's patterns. In Go, \s is ASCII whitespace only.
+const space = `\t\n\v\f\r \x{85}\p{Z}`
+
+// bpeEncodings lists the encodings -tokenizer supports: the pattern that
+// splits text into pieces before byte-pair merging, and the tiktoken rank
+// file holding the merges.
+var bpeEncodings = map[string]struct {
+	pattern string
+	file    string
+}{
+	"cl100k": {
+		pattern: `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^` + space + `\p{L}\p{N}]+[\r\n]*|[` + space + `]*[\r\n]+`,
+		file:    "cl100k_base.tiktoken",
+	},
+	"o200k": {
+		pattern: `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|\p{N}{1,3}| ?[^` + space + `\p{L}\p{N}]+[\r\n/]*|[` + space + `]*[\r\n]+`,
+		file:    "o200k_base.tiktoken",
+	},
+}
+
+// tokenizerDir returns where the tiktoken rank files are looked for.
+func tokenizerDir() string {
+	if dir := os.Getenv("LLM_CAT_TOKENIZER_DIR"); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "llm-cat")
+	}
+	return "."
+}
+
+// bpeTokenizer counts tokens exactly as tiktoken's encode_ordinary does.
+type bpeTokenizer struct {
+	name   string
+	ranks  map[string]int
+	split  *regexp.Regexp
+	mu     sync.Mutex     // guards pieces; files may be counted concurrently
+	pieces map[string]int // token counts of pieces seen so far
+	cache  *tokenCache    // counts of texts from earlier runs; nil with -no-token-cache
+}
+
+// loadBPE loads the named encoding from dir.
+func loadBPE(name, dir string) (*bpeTokenizer, error) {
+	enc, ok := bpeEncodings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tokenizer %q (want cl100k or o200k)", name)
+	}
+	f, err := os.Open(filepath.Join(dir, enc.file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int, 200_000)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		tok, rank, ok := bytes.Cut(scanner.Bytes(), []byte(" "))
+		if !ok {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(string(tok))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", enc.file, err)
+		}
+		r, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", enc.file, err)
+		}
+		ranks[string(b)] = r
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &bpeTokenizer{
+		name:   name,
+		ranks:  ranks,
+		split:  regexp.MustCompile(`^(?:` + enc.pattern + `)`),
+		pieces: make(map[string]int),
+	}, nil
+}
+
+func (t *bpeTokenizer) String() string { return t.name }
+
+func (t *bpeTokenizer) count(text []byte) int {
+	if t.cache != nil && len(text) >= tokenCacheMin {
+		return t.cache.count(text, t.encode)
+	}
+	return t.encode(text)
+}
+
+// encode counts the tokens in text without the cache.
+func (t *bpeTokenizer) encode(text []byte) int {
+	total := 0
+	for len(text) > 0 {
+		n := t.nextPiece(text)
+		total += t.pieceTokens(text[:n])
+		text = text[n:]
+	}
+	return total
+}
+
+func (t *bpeTokenizer) cut(text []byte, max int) (int, int) {
+	off, total := 0, 0
+	for off < len(text) {
+		n := t.nextPiece(text[off:])
+		c := t.pieceTokens(text[off : off+n])
+		if total+c > max {
+			break
+		}
+		off += n
+		total += c
+	}
+	return off, total
+}
+
+// nextPiece returns the length of the piece at the start of text. The
+// encodings' patterns end with `\s+(?!\S)|\s+`, which RE2 can't express, so
+// those two alternatives are handled here instead.
+func (t *bpeTokenizer) nextPiece(text []byte) int {
+	if loc := t.split.FindIndex(text); loc != nil && loc[1] > 0 {
+		return loc[1]
+	}
+	// Measure the run of whitespace at the start of text, remembering
+	// where its last character begins.
+	n, last := 0, 0
+	for n < len(text) {
+		r, size := utf8.DecodeRune(text[n:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		last = n
+		n += size
+	}
+	switch {
+	case n == 0:
+		// Not whitespace and not matched above (such as invalid UTF-8):
+		// take one byte.
+		return 1
+	case n == len(text) || last == 0:
+		// \s+(?!\S) matches the whole run at the end of the text; a
+		// single space before a non-space falls through to \s+.
+		return n
+	default:
+		// Leave the last space to start the next piece, as in " foo".
+		return last
+	}
+}
+
+// pieceTokens returns the number of tokens that byte-pair encoding produces
+// for piece.
+func (t *bpeTokenizer) pieceTokens(piece []byte) int {
+	if _, ok := t.ranks[string(piece)]; ok {
+		return 1
+	}
+	t.mu.Lock()
+	n, ok := t.pieces[string(piece)]
+	t.mu.Unlock()
+	if ok {
+		return n
+	}
+	// parts holds the start of each current token, plus len(piece).
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+	for len(parts) > 2 {
+		best, bestRank := -1, 0
+		for i := 0; i+2 < len(parts); i++ {
+			if r, ok := t.ranks[string(piece[parts[i]:parts[i+2]])]; ok && (best < 0 || r < bestRank) {
+				best, bestRank = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	n = len(parts) - 1
+	t.mu.Lock()
+	if len(t.pieces) < 1<<16 {
+		t.pieces[string(piece)] = n
+	}
+	t.mu.Unlock()
+	return n
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// checkBudget dumps files to nowhere, with -total-max and -max-total-tokens
+// cutting nothing off, for -fail-over-budget. It returns an error saying how
+// far over them the real dump would go, if it would.
+func (r *runner) checkBudget(files *pathList) error {
+	opts := r.opts
+	out, quiet := r.out, opts.quiet
+	r.out, opts.quiet, r.measuring = io.Discard, true, true
+	r.dump(files)
+	r.out, opts.quiet, r.measuring = out, quiet, false
+
+	if total := r.total; opts.totalMax > 0 && total > opts.totalMax {
+		budget := "-total-max"
+		if opts.model != "" && !flagSet("total-max") {
+			budget = "the -model " + opts.model + " budget"
+		}
+		return fmt.Errorf("output would be %s, %s (%d%%) over %s of %s", r.size(total), r.size(total-opts.totalMax), overPercent(total, opts.totalMax), budget, r.size(opts.totalMax))
+	}
+	if tokens := r.tokenTotal(); opts.maxTokens > 0 && tokens > opts.maxTokens {
+		return fmt.Errorf("output would be %s tokens, %s (%d%%) over -max-total-tokens of %s", thousands(int(tokens)), thousands(int(tokens-opts.maxTokens)), overPercent(tokens, opts.maxTokens), thousands(int(opts.maxTokens)))
+	}
+	return nil
+}
+
+// overPercent returns how far n is over budget, as a rounded-up percentage of
+// budget.
+func overPercent(n, budget int64) int64 {
+	return (100*(n-budget) + budget - 1) / budget
+}
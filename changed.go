@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A changeSet is what -changed-since knows about the files that differ from
+// a git ref.
+type changeSet struct {
+	ref   string
+	root  string          // top of the work tree
+	paths map[string]bool // changed files by absolute path; true if new since ref
+}
+
+// loadChangeSet asks git which files in the work tree containing the
+// current directory differ from ref, including untracked ones.
+func loadChangeSet(ref string) (*changeSet, error) {
+	top, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-parse: %v", gitError(err))
+	}
+	c := &changeSet{ref: ref, root: strings.TrimSpace(string(top)), paths: make(map[string]bool)}
+	out, err := exec.Command("git", "-C", c.root, "diff", "--name-status", "--no-renames", "-z", ref, "--").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff: %v", gitError(err))
+	}
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, name := string(fields[i]), string(fields[i+1])
+		if status == "D" {
+			continue
+		}
+		c.paths[filepath.Join(c.root, filepath.FromSlash(name))] = status == "A"
+	}
+	out, err = exec.Command("git", "-C", c.root, "ls-files", "--others", "--exclude-standard", "-z").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files: %v", gitError(err))
+	}
+	for _, name := range bytes.Split(out, []byte{0}) {
+		if len(name) > 0 {
+			c.paths[filepath.Join(c.root, filepath.FromSlash(string(name)))] = true
+		}
+	}
+	return c, nil
+}
+
+// lookup returns the absolute path git knows path by, and whether that file
+// has changed since the ref.
+func (c *changeSet) lookup(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	if _, ok := c.paths[abs]; ok {
+		return abs, true
+	}
+	// git reports paths under the real location of the repository.
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		if _, ok := c.paths[real]; ok {
+			return real, true
+		}
+	}
+	return "", false
+}
+
+// changedContext returns the parts of data, the contents of name, that have
+// changed since the -changed-since ref, with -changed-context lines around
+// each change, and notes the line ranges shown. A file that is new since
+// the ref is returned whole. It reports false if name hasn't changed.
+func (r *runner) changedContext(name string, data []byte) ([]byte, bool, error) {
+	c := r.changes
+	abs, ok := c.lookup(name)
+	if !ok {
+		return nil, false, nil
+	}
+	if c.paths[abs] {
+		r.addNote("new since " + c.ref)
+		return data, true, nil
+	}
+	rel, err := filepath.Rel(c.root, abs)
+	if err != nil {
+		return nil, false, err
+	}
+	old, err := exec.Command("git", "-C", c.root, "show", c.ref+":"+filepath.ToSlash(rel)).Output()
+	if err != nil {
+		return nil, false, fmt.Errorf("git show: %v", gitError(err))
+	}
+	lines := splitLines(data)
+	windows := changedWindows(diffLines(splitLines(old), lines), len(lines), r.opts.changedContext)
+	if len(windows) == 0 {
+		return nil, false, nil
+	}
+	var b bytes.Buffer
+	ranges := make([]string, len(windows))
+	for i, w := range windows {
+		if i > 0 {
+			b.WriteString("...\n")
+		}
+		for _, l := range lines[w.start:w.end] {
+			b.WriteString(l)
+			if !strings.HasSuffix(l, "\n") {
+				b.WriteByte('\n')
+			}
+		}
+		ranges[i] = hunkLines(w)
+	}
+	r.addNote("changed since " + c.ref + ": lines " + strings.Join(ranges, ", "))
+	return b.Bytes(), true, nil
+}
+
+// A lineSpan is the lines from start up to end of a file, counting from 0.
+type lineSpan struct{ start, end int }
+
+// changedWindows returns the spans of the n new lines in ops that are
+// within context lines of a change, merging spans that touch. A removal
+// counts as a change to the line after it, or to the last line if nothing
+// follows.
+func changedWindows(ops []diffOp, n, context int) []lineSpan {
+	var spans []lineSpan
+	at := 0 // new lines before the current op
+	for _, op := range ops {
+		if op.kind == ' ' {
+			at++
+			continue
+		}
+		line := at
+		if op.kind == '+' {
+			at++
+		}
+		line = min(line, n-1)
+		if line < 0 {
+			continue // everything was removed
+		}
+		s := lineSpan{max(0, line-context), min(n, line+context+1)}
+		if k := len(spans) - 1; k >= 0 && s.start <= spans[k].end {
+			spans[k].end = max(spans[k].end, s.end)
+			continue
+		}
+		spans = append(spans, s)
+	}
+	return spans
+}
+
+// hunkLines formats w as a range of line numbers counting from 1.
+func hunkLines(w lineSpan) string {
+	if w.end-w.start == 1 {
+		return fmt.Sprint(w.start + 1)
+	}
+	return fmt.Sprintf("%d-%d", w.start+1, w.end)
+}
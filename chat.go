@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// A chatWriter writes the dump as a -chat-json messages array: a system
+// message, if there is a -system prompt, then the dump as user messages.
+// Like a splitWriter, it holds what is written until boundary is called
+// between files, so that with a size a new message is only started between
+// two files. A file too large for a message of its own still gets one, over
+// the size.
+type chatWriter struct {
+	w        io.Writer
+	system   string
+	size     int64 // most bytes in a user message; 0 for one message
+	messages []string
+	held     bytes.Buffer
+}
+
+// A chatMessage is one element of the messages array of a chat API.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (c *chatWriter) Write(p []byte) (int, error) {
+	return c.held.Write(p)
+}
+
+// boundary adds what has been held since the last call to the current
+// message, first starting a new one if it won't fit.
+func (c *chatWriter) boundary() {
+	if c.held.Len() == 0 {
+		return
+	}
+	n := len(c.messages)
+	if n == 0 || c.size > 0 && len(c.messages[n-1]) > 0 && int64(len(c.messages[n-1])+c.held.Len()) > c.size {
+		c.messages = append(c.messages, "")
+		n++
+	}
+	c.messages[n-1] += c.held.String()
+	c.held.Reset()
+}
+
+// close adds anything still held and writes the messages array.
+func (c *chatWriter) close() error {
+	c.boundary()
+	var messages []chatMessage
+	if c.system != "" {
+		messages = append(messages, chatMessage{"system", c.system})
+	}
+	for _, m := range c.messages {
+		messages = append(messages, chatMessage{"user", m})
+	}
+	if messages == nil {
+		messages = []chatMessage{}
+	}
+	enc := json.NewEncoder(c.w)
+	// The contents are for a model to read, not a browser.
+	enc.SetEscapeHTML(false)
+	return enc.Encode(messages)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"unicode/utf8"
+)
+
+// printChunks prints the file at path, which is larger than -max-size, as
+// numbered parts of at most -max-size bytes each for -chunk. Parts that would
+// take the output over -total-max are left out.
+func (r *runner) printChunks(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if r.binary(path, r.head(data)) {
+		r.skip(path, "binary", "binary file %s", path)
+		return 0, nil
+	}
+
+	parts := splitChunks(data, int(r.opts.maxSize))
+	defer func() { r.part = "" }()
+	var written int64
+	for i, part := range parts {
+		if r.opts.totalMax > 0 && !r.measuring && r.total+written+int64(len(part)) > r.opts.totalMax {
+			fmt.Fprintf(os.Stderr, "Skipping parts %d-%d of %s (total limit %s reached)\n", i+1, len(parts), path, r.size(r.opts.totalMax))
+			break
+		}
+		r.part = fmt.Sprintf(" (part %d/%d)", i+1, len(parts))
+		n, err := r.printContents(path, bytes.NewReader(part), 0)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// splitChunks splits data into pieces of at most size bytes, ending each one
+// after the last newline that fits when there is one, and otherwise at a
+// character boundary.
+func splitChunks(data []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(data) > size {
+		cut := bytes.LastIndexByte(data[:size], '\n') + 1
+		if cut == 0 {
+			cut = size
+			for cut > 0 && !utf8.RuneStart(data[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = size
+			}
+		}
+		chunks = append(chunks, data[:cut])
+		data = data[cut:]
+	}
+	if len(data) > 0 {
+		chunks = append(chunks, data)
+	}
+	return chunks
+}
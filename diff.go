@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffContext is how many unchanged lines -diff shows around each change.
+const diffContext = 3
+
+// maxDiffEdits bounds the work diffLines does. Files that differ in more
+// lines than this are shown as one hunk replacing all of the old lines.
+const maxDiffEdits = 1000
+
+// counterpart returns the path of the file that corresponds to name under
+// the -diff-against directory.
+func (r *runner) counterpart(name string) string {
+	if filepath.IsAbs(name) {
+		if p, err := mirrorPath(r.opts.diffAgainst, name); err == nil {
+			return p
+		}
+	}
+	return filepath.Join(r.opts.diffAgainst, name)
+}
+
+// compare checks data, the contents of name, against its counterpart under
+// the -diff-against directory. It returns what to print for name, and false
+// if it is the same in both trees and shouldn't be printed at all. A file
+// missing from the other tree is printed in full, with a note saying so.
+func (r *runner) compare(name string, data []byte) ([]byte, bool, error) {
+	other := r.counterpart(name)
+	old, err := os.ReadFile(other)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.addNote("not in " + r.opts.diffAgainst)
+		return data, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.Equal(old, data) {
+		return nil, false, nil
+	}
+	if r.opts.diff {
+		return unifiedDiff(old, data, r.displayName(other), r.displayName(name)), true, nil
+	}
+	return data, true, nil
+}
+
+// noteOnlyInOther prints a marker for each file under the -diff-against
+// directory that files would have selected there, but that has no
+// counterpart here.
+func (r *runner) noteOnlyInOther(files *pathList) {
+	for _, f := range files.paths {
+		if f == "-" {
+			continue
+		}
+		f, depth, ok := splitDepth(f)
+		if !ok {
+			depth = r.opts.depth
+		}
+		root := r.counterpart(f)
+		walkPath(root, nil, depth, ok || r.opts.recurse, r.opts, func(e fileEntry) error {
+			if e.err != nil {
+				return nil
+			}
+			rel, err := filepath.Rel(root, e.path)
+			if err != nil {
+				return nil
+			}
+			mine := filepath.Join(f, rel)
+			if _, err := os.Lstat(mine); errors.Is(err, fs.ErrNotExist) {
+				r.marker(mine, "only in "+r.opts.diffAgainst)
+			}
+			return nil
+		})
+	}
+}
+
+// A diffOp is one line of a diff: kept (' '), removed ('-') or added ('+').
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns a unified diff that turns a, named aName, into b,
+// named bName.
+func unifiedDiff(a, b []byte, aName, bName string) []byte {
+	ops := diffLines(splitLines(a), splitLines(b))
+	// at[i] is how many lines of a and of b come before ops[i].
+	type pos struct{ a, b int }
+	at := make([]pos, len(ops)+1)
+	for i, op := range ops {
+		at[i+1] = at[i]
+		if op.kind != '+' {
+			at[i+1].a++
+		}
+		if op.kind != '-' {
+			at[i+1].b++
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// A hunk runs until there are enough unchanged lines in a row
+		// to close it and open the next one without overlap.
+		start, end := max(0, i-diffContext), i+1
+		for j := i + 1; j < len(ops) && j-end <= 2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			}
+		}
+		end = min(len(ops), end+diffContext)
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(at[start].a, at[end].a), hunkRange(at[start].b, at[end].b))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+// hunkRange formats the lines from start up to end for a hunk header.
+func hunkRange(start, end int) string {
+	if n := end - start; n != 1 {
+		if n == 0 {
+			return fmt.Sprintf("%d,0", start)
+		}
+		return fmt.Sprintf("%d,%d", start+1, n)
+	}
+	return fmt.Sprint(start + 1)
+}
+
+// splitLines splits data into lines, each with its line ending.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		lines = append(lines, string(data[:n]))
+		data = data[n:]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script from a to b.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		ops = append(ops, diffOp{' ', a[pre]})
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops = append(ops, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, line := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myers finds the shortest edit script from a to b with Myers' algorithm,
+// giving up after maxDiffEdits edits.
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	off := n + m + 1
+	v := make([]int, 2*off+1) // v[off+k] is the furthest x reached on diagonal k
+	var trace [][]int         // trace[d][d+k] is v[off+k] after d edits
+	for d := 0; d <= n+m && d <= maxDiffEdits; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+				return backtrack(a, b, trace)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+// backtrack recovers the edit script from the trace of a finished myers run.
+func backtrack(a, b []string, trace [][]int) []diffOp {
+	var rev []diffOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		k := x - y
+		prev := func(k int) int { return trace[d-1][d-1+k] }
+		pk := k - 1
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			pk = k + 1
+		}
+		px := prev(pk)
+		py := px - pk
+		midX, midY := px, py+1 // after inserting b[py]
+		if pk == k-1 {
+			midX, midY = px+1, py // after deleting a[px]
+		}
+		for x > midX && y > midY {
+			x, y = x-1, y-1
+			rev = append(rev, diffOp{' ', a[x]})
+		}
+		if pk == k+1 {
+			rev = append(rev, diffOp{'+', b[py]})
+		} else {
+			rev = append(rev, diffOp{'-', a[px]})
+		}
+		x, y = px, py
+	}
+	for x > 0 {
+		x--
+		rev = append(rev, diffOp{' ', a[x]})
+	}
+	ops := make([]diffOp, len(rev))
+	for i, op := range rev {
+		ops[len(rev)-1-i] = op
+	}
+	return ops
+}
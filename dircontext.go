@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// printDirContext prints, ahead of name if it is the first file printed
+// from r.contextDir, the start of that directory's README, for -dir-context.
+// A README that the dump selects is only referred to, since it is printed in
+// full anyway, and nothing is printed ahead of the README itself.
+func (r *runner) printDirContext(name string) {
+	dir := r.contextDir
+	if dir == "" || r.dirContexts[dir] {
+		return
+	}
+	if r.dirContexts == nil {
+		r.dirContexts = make(map[string]bool)
+	}
+	r.dirContexts[dir] = true
+	readme, info := dirReadme(dir)
+	if readme == "" || readme == filepath.Clean(name) {
+		return
+	}
+	r.flushSmall()
+	label := r.displayName(dir) + "/"
+	if selects(readme, r.opts) && newEnough(info, r.opts) {
+		r.contextHeader(label + " (context: see " + r.displayName(readme) + ")")
+		return
+	}
+	data, err := os.ReadFile(readme)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	sample := data
+	if n := r.opts.sampleSize; n > 0 && n < len(sample) {
+		sample = sample[:n]
+	}
+	if r.binary(readme, sample) {
+		return
+	}
+	lines := splitLines(data)
+	note := "context from " + r.displayName(readme)
+	if len(lines) > r.opts.dirContextMax {
+		note += fmt.Sprintf(", first %d of %d lines", r.opts.dirContextMax, len(lines))
+		lines = lines[:r.opts.dirContextMax]
+	}
+	text := strings.Join(lines, "")
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	fence := ""
+	if r.opts.markdown {
+		fence = codeFence([]byte(text))
+	}
+	r.contextHeader(label + " (" + note + ")")
+	if fence != "" {
+		fmt.Fprintf(r.out, "%smarkdown\n%s%s\n", fence, text, fence)
+		return
+	}
+	fmt.Fprint(r.out, text)
+}
+
+// contextHeader prints the header of a -dir-context block.
+func (r *runner) contextHeader(label string) {
+	if r.opts.markdown {
+		fmt.Fprintf(r.out, "\n### %s\n\n", label)
+		return
+	}
+	fmt.Fprintf(r.out, "\n--- %s ---\n", label)
+}
+
+// dirReadme returns the README in dir, if it has one: the first regular
+// file, by name, whose name starts with README in any case.
+func dirReadme(dir string) (string, os.FileInfo) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(strings.ToUpper(e.Name()), "README") || !e.Type().IsRegular() {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			return filepath.Join(dir, e.Name()), info
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// dirTotals is what -count-by-dir adds up for one directory.
+type dirTotals struct {
+	dir           string
+	bytes, tokens int64
+	files         int
+}
+
+// countByDir writes a table to path (or stdout if it is empty) of the bytes,
+// tokens and files that files selects in each directory, largest first, in
+// place of a dump. Directories are cut off -count-depth levels below each
+// argument, with everything deeper counted in the directory above it. Binary
+// files are left out, as a dump would leave them out.
+func (r *runner) countByDir(files *pathList, path string) error {
+	totals := make(map[string]*dirTotals)
+	err := files.each(func(root string) {
+		if root == "-" {
+			return
+		}
+		err := processPath(root, nil, r.opts, func(e fileEntry) error {
+			if e.err != nil {
+				return e.err
+			}
+			if e.link != "" {
+				return nil
+			}
+			data, err := os.ReadFile(e.path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.path, err)
+				return nil
+			}
+			if r.binary(e.path, r.head(data)) {
+				return nil
+			}
+			base, _, _ := splitDepth(root)
+			dir := cutDir(base, e.path, r.opts.countDepth)
+			t := totals[dir]
+			if t == nil {
+				t = &dirTotals{dir: dir}
+				totals[dir] = t
+			}
+			t.bytes += int64(len(data))
+			t.tokens += int64(r.tok.count(data))
+			t.files++
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", root, err)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+	}
+
+	sorted := make([]*dirTotals, 0, len(totals))
+	var sum dirTotals
+	for _, t := range totals {
+		sorted = append(sorted, t)
+		sum.bytes += t.bytes
+		sum.tokens += t.tokens
+		sum.files += t.files
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].bytes != sorted[j].bytes {
+			return sorted[i].bytes > sorted[j].bytes
+		}
+		return sorted[i].dir < sorted[j].dir
+	})
+
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Bytes\tTokens\tFiles\t\tDirectory")
+	for _, t := range sorted {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\t%s\n", sizeCell(t.bytes, r.opts.human), t.tokens, t.files, r.displayName(t.dir))
+	}
+	fmt.Fprintf(tw, "%s\t%d\t%d\t\t%s\n", sizeCell(sum.bytes, r.opts.human), sum.tokens, sum.files, "Total")
+	err = tw.Flush()
+	if path != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// cutDir returns the directory of p, which was found under root, cut off
+// depth levels below root.
+func cutDir(root, p string, depth int) string {
+	dir := filepath.Dir(p)
+	if p == root {
+		return dir
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return dir
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) <= depth {
+		return dir
+	}
+	return filepath.Join(append([]string{root}, parts[:depth]...)...)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// edit prints files to a temporary file for -edit, opens that in $EDITOR,
+// and once the editor exits successfully copies what was saved to the file
+// at path, or to stdout if path is empty. If the editor fails, nothing is
+// copied.
+func (r *runner) edit(files *pathList, path string) error {
+	editor := os.Getenv("EDITOR")
+	ext := ".txt"
+	switch {
+	case r.opts.markdown:
+		ext = ".md"
+	case r.opts.xml:
+		ext = ".xml"
+	}
+	tmp, err := os.CreateTemp("", "llm-cat-*"+ext)
+	if err != nil {
+		return err
+	}
+	name := tmp.Name()
+	tmp.Close()
+	defer os.Remove(name)
+	if err := r.dumpTo(files, name); err != nil {
+		return err
+	}
+
+	cmd := editorCommand(editor, name)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	// The list of files may have come from stdin and the output may be
+	// piped, so the editor talks to the terminal directly where there is
+	// one.
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		cmd.Stdin, cmd.Stdout = tty, tty
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("-edit: %s: %v; nothing was printed", editor, err)
+	}
+
+	edited, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer edited.Close()
+	out := os.Stdout
+	if path != "" {
+		if out, err = os.Create(path); err != nil {
+			return err
+		}
+	}
+	_, err = io.Copy(out, edited)
+	if path != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
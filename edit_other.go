@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// editorCommand returns the command that opens path in editor, the value of
+// $EDITOR. There is no sh to run it with on this platform, so it is split
+// on whitespace, and quoting isn't supported.
+func editorCommand(editor, path string) *exec.Cmd {
+	args := strings.Fields(editor)
+	return exec.Command(args[0], append(args[1:], path)...)
+}
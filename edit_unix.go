@@ -0,0 +1,12 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import "os/exec"
+
+// editorCommand returns the command that opens path in editor, the value of
+// $EDITOR. Like git, it runs editor with the shell, so that it can hold
+// arguments and quoted paths with spaces in them.
+func editorCommand(editor, path string) *exec.Cmd {
+	return exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An editorconfigFile is a parsed .editorconfig.
+type editorconfigFile struct {
+	dir      string // the directory it is in, which patterns are relative to
+	root     bool   // root = true: stop looking in parent directories
+	sections []editorconfigSection
+}
+
+type editorconfigSection struct {
+	match *regexp.Regexp // nil if the pattern could not be parsed
+	props map[string]string
+}
+
+// editorconfigs caches the .editorconfig files read so far, by directory;
+// nil means there is none. Files may be prepared concurrently.
+var editorconfigs = struct {
+	sync.Mutex
+	files map[string]*editorconfigFile
+}{files: make(map[string]*editorconfigFile)}
+
+// editorconfigFor returns the EditorConfig properties that apply to the file
+// name, with keys and values in lower case. Closer .editorconfig files win
+// over ones further up, and later sections over earlier ones, as the spec
+// says. It returns nil if no rule covers the file.
+func editorconfigFor(name string) map[string]string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil
+	}
+	var chain []*editorconfigFile
+	for dir := filepath.Dir(abs); ; {
+		if ec := loadEditorconfig(dir); ec != nil {
+			chain = append(chain, ec)
+			if ec.root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	var props map[string]string
+	for i := len(chain) - 1; i >= 0; i-- {
+		ec := chain[i]
+		rel, err := filepath.Rel(ec.dir, abs)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, s := range ec.sections {
+			if s.match == nil || !s.match.MatchString(rel) {
+				continue
+			}
+			if props == nil {
+				props = make(map[string]string)
+			}
+			for k, v := range s.props {
+				props[k] = v
+			}
+		}
+	}
+	return props
+}
+
+// loadEditorconfig returns the parsed .editorconfig in dir, or nil.
+func loadEditorconfig(dir string) *editorconfigFile {
+	editorconfigs.Lock()
+	defer editorconfigs.Unlock()
+	if ec, ok := editorconfigs.files[dir]; ok {
+		return ec
+	}
+	ec, err := parseEditorconfig(dir)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v\n", filepath.Join(dir, ".editorconfig"), err)
+	}
+	editorconfigs.files[dir] = ec
+	return ec
+}
+
+func parseEditorconfig(dir string) (*editorconfigFile, error) {
+	f, err := os.Open(filepath.Join(dir, ".editorconfig"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ec := &editorconfigFile{dir: dir}
+	var cur *editorconfigSection
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[' && line[len(line)-1] == ']':
+			match, _ := editorconfigGlob(line[1 : len(line)-1])
+			ec.sections = append(ec.sections, editorconfigSection{match: match, props: make(map[string]string)})
+			cur = &ec.sections[len(ec.sections)-1]
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.ToLower(strings.TrimSpace(v))
+		if cur == nil {
+			if k == "root" {
+				ec.root = v == "true"
+			}
+			continue
+		}
+		cur.props[k] = v
+	}
+	return ec, scanner.Err()
+}
+
+// editorconfigGlob compiles an EditorConfig section pattern into a regexp
+// that matches slash-separated paths relative to the .editorconfig's
+// directory. A pattern with no slash matches a file name at any depth.
+func editorconfigGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	if err := globToRegexp(&b, pattern, 0); err != nil {
+		return nil, err
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+var numericRange = regexp.MustCompile(`^\{(-?\d+)\.\.(-?\d+)\}`)
+
+// globToRegexp translates pattern: * and ** (a name, and any run of
+// directories), ?, [seq] and [!seq], {a,b} and {1..3}. depth is the number of
+// enclosing braces, inside which a comma separates alternatives.
+func globToRegexp(b *strings.Builder, pattern string, depth int) error {
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '{':
+			if m := numericRange.FindStringSubmatch(pattern[i:]); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				if hi-lo > 1000 {
+					return fmt.Errorf("range %s is too large", m[0])
+				}
+				alts := make([]string, 0, hi-lo+1)
+				for n := lo; n <= hi; n++ {
+					alts = append(alts, strconv.Itoa(n))
+				}
+				b.WriteString("(?:" + strings.Join(alts, "|") + ")")
+				i += len(m[0]) - 1
+				continue
+			}
+			end := closingBrace(pattern[i:])
+			inner := pattern[i+1 : i+max(end, 1)]
+			if end < 0 || !strings.Contains(inner, ",") {
+				// Not a set of alternatives; the brace is literal.
+				b.WriteString(`\{`)
+				continue
+			}
+			b.WriteString("(?:")
+			if err := globToRegexp(b, inner, depth+1); err != nil {
+				return err
+			}
+			b.WriteString(")")
+			i += end
+		case ',':
+			if depth > 0 {
+				b.WriteString("|")
+			} else {
+				b.WriteString(",")
+			}
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return nil
+}
+
+// closingBrace returns the index of the brace that closes the one at s[0],
+// or -1.
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// editorconfigFilters returns the filters that carry out the EditorConfig
+// properties for name: end_of_line, trim_trailing_whitespace, indent_style
+// and indent_size (for the languages -reindent supports), and
+// insert_final_newline. Properties that are unset or "unset" are ignored.
+func editorconfigFilters(name string) []lineFilter {
+	props := editorconfigFor(name)
+	if props == nil {
+		return nil
+	}
+	var filters []lineFilter
+	eol := ""
+	switch props["end_of_line"] {
+	case "lf":
+		eol = "\n"
+	case "crlf":
+		eol = "\r\n"
+	}
+	if eol != "" {
+		filters = append(filters, eolFilter(eol))
+	}
+	if props["trim_trailing_whitespace"] == "true" {
+		filters = append(filters, trimFilter{})
+	}
+	if level := editorconfigIndent(props); level != nil && reindentUnsafe(name) == "" {
+		filters = append(filters, &reindentFilter{level: level, syntax: reindentLanguages[detectLanguage(name, nil)]})
+	}
+	switch props["insert_final_newline"] {
+	case "true", "false":
+		if eol == "" {
+			eol = "\n"
+		}
+		filters = append(filters, &finalNewlineFilter{want: props["insert_final_newline"] == "true", eol: []byte(eol)})
+	}
+	return filters
+}
+
+// editorconfigIndent returns one level of indentation as props describe it,
+// or nil if they don't.
+func editorconfigIndent(props map[string]string) []byte {
+	switch props["indent_style"] {
+	case "tab":
+		return []byte("\t")
+	case "space":
+		size := props["indent_size"]
+		if size == "tab" {
+			size = props["tab_width"]
+		}
+		if n, err := strconv.Atoi(size); err == nil && n > 0 {
+			return bytes.Repeat([]byte(" "), n)
+		}
+	}
+	return nil
+}
+
+// eolFilter ends every line that has an ending with this one, for
+// EditorConfig's end_of_line.
+type eolFilter string
+
+func (f eolFilter) filter(line []byte) []byte {
+	text, eol := splitEOL(line)
+	if eol == nil {
+		return line
+	}
+	return append(text, f...)
+}
+
+func (eolFilter) flush() []byte { return nil }
+
+// trimFilter removes trailing spaces and tabs, for EditorConfig's
+// trim_trailing_whitespace.
+type trimFilter struct{}
+
+func (trimFilter) filter(line []byte) []byte {
+	text, eol := splitEOL(line)
+	return append(bytes.TrimRight(text, " \t"), eol...)
+}
+
+func (trimFilter) flush() []byte { return nil }
+
+// finalNewlineFilter makes the file end with a line ending if want is set,
+// and without one if not, for EditorConfig's insert_final_newline. It holds
+// each line ending back until the next line shows it isn't the last.
+type finalNewlineFilter struct {
+	want    bool
+	eol     []byte // the ending to add
+	seen    bool
+	pending []byte // the last line's ending, not yet written
+}
+
+func (f *finalNewlineFilter) filter(line []byte) []byte {
+	text, eol := splitEOL(line)
+	out := append(f.pending, text...)
+	f.seen, f.pending = true, bytes.Clone(eol)
+	return out
+}
+
+func (f *finalNewlineFilter) flush() []byte {
+	switch {
+	case !f.want || !f.seen:
+		return nil
+	case f.pending != nil:
+		return f.pending
+	}
+	return f.eol
+}
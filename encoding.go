@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// outputEncodings are the charsets -output-encoding can write, each as a
+// function that encodes one non-ASCII rune, reporting false if the charset
+// has no byte for it. utf-8 is the default and needs no encoder.
+var outputEncodings = map[string]func(rune) (byte, bool){
+	"ascii": func(rune) (byte, bool) { return 0, false },
+	"latin1": func(r rune) (byte, bool) {
+		return byte(r), r <= 0xff
+	},
+	"windows-1252": func(r rune) (byte, bool) {
+		if b, ok := cp1252[r]; ok {
+			return b, true
+		}
+		return byte(r), r >= 0xa0 && r <= 0xff
+	},
+}
+
+// encodingAliases are other names for the outputEncodings.
+var encodingAliases = map[string]string{
+	"us-ascii":   "ascii",
+	"iso-8859-1": "latin1",
+	"cp1252":     "windows-1252",
+}
+
+// cp1252 is where Windows-1252 differs from Latin-1: the printable
+// characters it puts in 0x80-0x9f.
+var cp1252 = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84,
+	'…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c,
+	'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// lookupEncoding returns the canonical name of the -output-encoding called
+// name, or an error listing the known ones.
+func lookupEncoding(name string) (string, error) {
+	name = strings.ToLower(name)
+	if alias, ok := encodingAliases[name]; ok {
+		name = alias
+	}
+	if _, ok := outputEncodings[name]; ok || name == "utf-8" {
+		return name, nil
+	}
+	known := []string{"utf-8"}
+	for n := range outputEncodings {
+		known = append(known, n)
+	}
+	sort.Strings(known[1:])
+	return "", fmt.Errorf("unknown -output-encoding %q (known encodings: %s)", name, strings.Join(known, ", "))
+}
+
+// An encodingWriter transcodes the UTF-8 written to it into a legacy charset
+// for -output-encoding. A character the charset can't represent, or a byte
+// that isn't valid UTF-8, is written as ? or, unless replace is set, ends the
+// output there: what follows is dropped, and close reports the error, so that
+// the dump fails once rather than for every file after it.
+type encodingWriter struct {
+	w       io.Writer
+	name    string
+	encode  func(rune) (byte, bool)
+	replace bool
+	partial []byte // an incomplete UTF-8 sequence at the end of the last write
+	err     error
+	failed  bool // err is an unmappable character, and output has stopped
+}
+
+func (e *encodingWriter) Write(p []byte) (int, error) {
+	if e.failed {
+		return len(p), nil
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+	data := p
+	if len(e.partial) > 0 {
+		data = append(e.partial, p...)
+		e.partial = nil
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if c := data[i]; c < utf8.RuneSelf {
+			out = append(out, c)
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			e.partial = append([]byte(nil), data[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		b, ok := e.encode(r)
+		if !ok || r == utf8.RuneError && size == 1 {
+			if !e.replace {
+				e.w.Write(out)
+				e.err, e.failed = e.unmappable(data[i:i+size]), true
+				return len(p), nil
+			}
+			b = '?'
+		}
+		out = append(out, b)
+		i += size
+	}
+	if _, err := e.w.Write(out); err != nil {
+		e.err = err
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// close writes out a sequence left incomplete at the end of the output, and
+// returns the first error the writer met.
+func (e *encodingWriter) close() error {
+	if e.err == nil && len(e.partial) > 0 {
+		if !e.replace {
+			e.err = e.unmappable(e.partial)
+		} else if _, err := e.w.Write([]byte("?")); err != nil {
+			e.err = err
+		}
+	}
+	return e.err
+}
+
+func (e *encodingWriter) unmappable(seq []byte) error {
+	if r, _ := utf8.DecodeRune(seq); r != utf8.RuneError {
+		return fmt.Errorf("%U (%c) can't be written in %s (use -on-unmappable replace)", r, r, e.name)
+	}
+	return fmt.Errorf("invalid UTF-8 (%q) can't be written in %s (use -on-unmappable replace)", seq, e.name)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// eolReport writes a line for each file that files selects to path (or
+// stdout if it is empty), giving its line-ending style and whether it ends in
+// a newline, in place of a dump:
+//
+//	lf	final-newline	main.go
+//	crlf	no-final-newline	notes.txt
+//
+// The style is lf, crlf or cr if only one kind is used, mixed if more are,
+// and none for a file with a single unterminated line. Empty files are
+// reported as "empty -" and binary ones as "binary -".
+func (r *runner) eolReport(files *pathList, path string) error {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	walkFiles(files, r.opts, func(e fileEntry) error {
+		if e.err != nil {
+			return e.err
+		}
+		if e.link != "" {
+			return nil
+		}
+		style, final, err := r.lineEndings(e.path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.path, err)
+			return nil
+		}
+		if style != "" {
+			name := e.path
+			if name == "-" {
+				name = r.opts.stdinName
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", style, final, name)
+		}
+		return nil
+	})
+	err := w.Flush()
+	if path != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// lineEndings returns the -eol-report columns for path. It returns an empty
+// style for files a dump would skip regardless of their contents.
+func (r *runner) lineEndings(path string) (style, final string, err error) {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		// Stat before opening: opening a named pipe would block.
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", "", err
+		}
+		if !info.Mode().IsRegular() {
+			return "", "", nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return "", "", err
+		}
+		defer f.Close()
+		in = f
+	}
+	sample, err := readSample(in, r.opts.sampleSize)
+	if err != nil {
+		return "", "", err
+	}
+	if r.binary(path, sample) {
+		return "binary", "-", nil
+	}
+	var c eolCounter
+	c.Write(sample)
+	if _, err := io.Copy(&c, in); err != nil {
+		return "", "", err
+	}
+	return c.style(), c.final(), nil
+}
+
+// eolCounter counts the line endings written to it.
+type eolCounter struct {
+	lf, crlf, cr int
+	n            int64
+	last         byte
+}
+
+func (c *eolCounter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		switch {
+		case b == '\n' && c.last == '\r':
+			c.cr--
+			c.crlf++
+		case b == '\n':
+			c.lf++
+		case b == '\r':
+			c.cr++
+		}
+		c.last = b
+	}
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+func (c *eolCounter) style() string {
+	if c.n == 0 {
+		return "empty"
+	}
+	var style string
+	kinds := 0
+	for _, k := range []struct {
+		name  string
+		count int
+	}{{"lf", c.lf}, {"crlf", c.crlf}, {"cr", c.cr}} {
+		if k.count > 0 {
+			style = k.name
+			kinds++
+		}
+	}
+	switch kinds {
+	case 0:
+		return "none"
+	case 1:
+		return style
+	}
+	return "mixed"
+}
+
+func (c *eolCounter) final() string {
+	switch {
+	case c.n == 0:
+		return "-"
+	case c.last == '\n' || c.last == '\r':
+		return "final-newline"
+	}
+	return "no-final-newline"
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// An eventLog writes -events: a newline-delimited JSON object per event, so
+// that a program wrapping llm-cat can show its progress. Each event is
+// written as soon as it happens. A nil *eventLog discards events.
+type eventLog struct {
+	enc *json.Encoder
+}
+
+func newEventLog(w io.Writer) *eventLog {
+	return &eventLog{enc: json.NewEncoder(w)}
+}
+
+// The events, in the order they appear for each file: start, then either
+// done or skip. A summary follows the last file.
+type (
+	startEvent struct {
+		Event string `json:"event"`
+		Path  string `json:"path"`
+	}
+	skipEvent struct {
+		Event  string `json:"event"`
+		Path   string `json:"path"`
+		Reason string `json:"reason"`
+		Error  string `json:"error,omitempty"` // for reason "error"
+	}
+	doneEvent struct {
+		Event string `json:"event"`
+		Path  string `json:"path"`
+		Bytes int64  `json:"bytes"`
+	}
+	summaryEvent struct {
+		Event   string `json:"event"`
+		Files   int    `json:"files"`
+		Skipped int    `json:"skipped"`
+		Bytes   int64  `json:"bytes"`
+		Tokens  int64  `json:"tokens"`
+	}
+)
+
+func (l *eventLog) write(v any) {
+	if l != nil {
+		// Like the dump itself, events are best effort: a consumer
+		// that goes away shouldn't stop the dump.
+		l.enc.Encode(v)
+	}
+}
+
+func (l *eventLog) start(path string) {
+	l.write(startEvent{"start", path})
+}
+
+func (l *eventLog) skip(path, reason string, err error) {
+	e := skipEvent{Event: "skip", Path: path, Reason: reason}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	l.write(e)
+}
+
+func (l *eventLog) done(path string, bytes int64) {
+	l.write(doneEvent{"done", path, bytes})
+}
+
+func (l *eventLog) summary(files, skipped int, bytes, tokens int64) {
+	l.write(summaryEvent{"summary", files, skipped, bytes, tokens})
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// exactMagic starts an -exact dump, naming the format and its version.
+const exactMagic = "llm-cat exact 1\n"
+
+// exactConflicts are the flags that change what a file's contents print as,
+// or cut them short, which -exact would have to ignore.
+var exactConflicts = []string{
+	"blame", "nfc", "trim-trailing-blank-lines", "dedup-blocks", "strip-imports",
+	"compact-imports", "editorconfig", "reindent", "comment-out", "highlight",
+	"show-whitespace", "line-template", "wrap", "max-lines", "html-escape",
+	"transforms", "escape-delimiters", "strip-license", "minify-json", "pretty-json",
+	"render-notebooks", "pdf-text", "max-tokens-per-file", "grep-context",
+	"first-match", "changed-since", "diff", "base64-binary", "image-placeholders",
+	"output-encoding", "merge-small", "chunk", "dedupe-content",
+	"total-max", "drop-over", "max-total-tokens", "max-total-lines", "per-dir-max",
+	"max-files-per-dir",
+}
+
+// maxExactName is the longest name -split accepts, well past what any
+// filesystem allows, so that a damaged length can't exhaust memory.
+const maxExactName = 1 << 16
+
+// exactDump writes the selected files to path (or stdout if it is empty) in
+// the -exact framing, in place of a dump: after exactMagic, each file is
+// the length of its name in bytes, a space, the name, a space, the length
+// of its contents in bytes and a newline, then the contents exactly as they
+// are, then a newline that isn't part of them. Lengths are in decimal.
+// Nothing else is printed, and no filter or limit touches the contents; files
+// are chosen as a dump would choose them.
+func (r *runner) exactDump(files *pathList, path string) error {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	w.WriteString(exactMagic)
+	walkFiles(files, r.opts, func(e fileEntry) error {
+		if e.err != nil {
+			return e.err
+		}
+		name := e.path
+		var data []byte
+		var err error
+		switch {
+		case e.inline != nil:
+			data = e.inline
+		case e.path == "-":
+			name = r.opts.stdinName
+			data, err = io.ReadAll(os.Stdin)
+		case e.link != "":
+			if !r.opts.quiet {
+				fmt.Fprintf(os.Stderr, "Skipping symlink %s -> %s (not followed)\n", e.path, e.link)
+			}
+			return nil
+		case r.opts.maxSize > 0 && e.size > r.opts.maxSize:
+			r.skip(e.path, "too-large", "%s (size %s exceeds limit %s)", e.path, r.size(e.size), r.size(r.opts.maxSize))
+			return nil
+		default:
+			data, err = readExact(e)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.path, err)
+			return nil
+		}
+		if skipped, err := r.exactSkipped(e, name, data); err != nil || skipped {
+			return err
+		}
+		name = r.displayName(name)
+		fmt.Fprintf(w, "%d %s %d\n", len(name), name, len(data))
+		w.Write(data)
+		w.WriteByte('\n')
+		return nil
+	})
+	if r.untracked > 0 && !r.opts.quiet {
+		r.reportUntracked()
+	}
+	if r.oldCommits > 0 && !r.opts.quiet {
+		r.reportOldCommits()
+	}
+	err := w.Flush()
+	if path != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// exactSkipped reports whether a dump would leave out e, named name and
+// holding data, for its place in git, its size or its contents, and records
+// why. Binary files are the exception: -exact keeps them.
+func (r *runner) exactSkipped(e fileEntry, name string, data []byte) (bool, error) {
+	opts := r.opts
+	if e.path != "-" && e.inline == nil {
+		if opts.trackedOnly {
+			tracked, err := r.tracked(e.path)
+			if err != nil {
+				return false, err
+			}
+			if !tracked {
+				r.untracked++
+				r.skip(e.path, "untracked", "")
+				return true, nil
+			}
+		}
+		if !opts.newerCommit.IsZero() {
+			recent, err := r.committedRecently(e.path)
+			if err != nil {
+				return false, err
+			}
+			if !recent {
+				r.oldCommits++
+				r.skip(e.path, "old-commit", "")
+				return true, nil
+			}
+		}
+	}
+	if opts.maxSize > 0 && int64(len(data)) > opts.maxSize {
+		// Stdin, whose size isn't known until it is read.
+		r.skip(name, "too-large", "%s (size %s exceeds limit %s)", name, r.size(int64(len(data))), r.size(opts.maxSize))
+		return true, nil
+	}
+	sample := r.head(data)
+	switch {
+	case opts.mime != "" && !mimeMatches(sniffMIME(sample), opts.mime),
+		opts.langFilter != nil && !r.wantLanguage(name, detectLanguage(name, sample)),
+		opts.grep != nil && !opts.grep.Match(data):
+		r.skip(name, "no-match", "")
+		return true, nil
+	case opts.skipMatching != nil && opts.skipMatching.Match(data):
+		r.skip(name, "skip-matching", "%s (matches -skip-matching)", name)
+		return true, nil
+	case opts.skipMinified && minified(name, sample):
+		r.skip(name, "minified", "minified file %s", name)
+		return true, nil
+	}
+	if opts.requireUTF8 {
+		if off := invalidUTF8(data, false); off >= 0 {
+			r.skip(name, "invalid-utf8", "%s (not valid UTF-8 at byte %d)", name, off)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readExact returns the contents of the regular file e, refusing special
+// files, which reading could block on.
+func readExact(e fileEntry) ([]byte, error) {
+	info := e.info
+	if info == nil {
+		var err error
+		if info, err = os.Stat(e.path); err != nil {
+			return nil, err
+		}
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s, not a regular file", fileKind(info.Mode()))
+	}
+	return os.ReadFile(e.path)
+}
+
+// splitExact reads an -exact dump from in and writes each file in it
+// under dir, for -split. Names are placed as -output-dir would place them.
+func splitExact(in io.Reader, dir string) error {
+	br := bufio.NewReader(in)
+	magic := make([]byte, len(exactMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != exactMagic {
+		return errors.New("input is not an -exact dump")
+	}
+	for i := 1; ; i++ {
+		n, err := readExactLength(br, ' ')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("file %d: %v", i, err)
+		}
+		if n > maxExactName {
+			return fmt.Errorf("file %d: name of %d bytes is too long", i, n)
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(br, name); err != nil {
+			return fmt.Errorf("file %d: name cut short", i)
+		}
+		if c, err := br.ReadByte(); err != nil || c != ' ' {
+			return fmt.Errorf("%s: no space after the name", name)
+		}
+		size, err := readExactLength(br, '\n')
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		target, err := mirrorPath(dir, filepath.FromSlash(string(name)))
+		if err != nil {
+			return err
+		}
+		if err := writeExact(target, br, size); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if c, err := br.ReadByte(); err != nil || c != '\n' {
+			return fmt.Errorf("%s: no newline after the contents", name)
+		}
+	}
+}
+
+// readExactLength reads a decimal length ending in delim. It returns io.EOF
+// if in ends before the first digit, as it does after the last file.
+func readExactLength(in *bufio.Reader, delim byte) (int64, error) {
+	var n int64
+	for digits := 0; ; digits++ {
+		c, err := in.ReadByte()
+		switch {
+		case err == io.EOF && digits == 0:
+			return 0, io.EOF
+		case err != nil:
+			return 0, errors.New("length cut short")
+		case c == delim && digits > 0:
+			return n, nil
+		case c < '0' || c > '9' || digits == 18:
+			return 0, fmt.Errorf("bad length at %q", c)
+		}
+		n = n*10 + int64(c-'0')
+	}
+}
+
+// writeExact writes the next size bytes of in to the file at target,
+// creating directories as needed.
+func writeExact(target string, in io.Reader, size int64) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(f, in, size)
+	if err == io.EOF {
+		err = errors.New("contents cut short")
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exactNames returns the names of the files in an -exact dump.
+func exactNames(t *testing.T, dump []byte) []string {
+	t.Helper()
+	br := bufio.NewReader(bytes.NewReader(dump[len(exactMagic):]))
+	var names []string
+	for {
+		n, err := readExactLength(br, ' ')
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := make([]byte, n+1)
+		io.ReadFull(br, name)
+		size, err := readExactLength(br, '\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		br.Discard(int(size) + 1)
+		names = append(names, string(name[:n]))
+	}
+}
+
+func TestExactSelection(t *testing.T) {
+	dir := gitRepo(t)
+	writeFiles(t, dir, map[string]string{
+		"big.txt":    strings.Repeat("big\n", 100),
+		"latin1.txt": "caf\xe9\n",
+		"min.js":     "var a=1;" + strings.Repeat("f();", 1000) + "\n",
+		"img.png":    "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+	})
+	chdir(t, dir)
+	all := []string{"big.txt", "img.png", "latin1.txt", "min.js", "old.go", "scratch.go", "sub/new.go"}
+	tests := []struct {
+		name string
+		set  func(*options)
+		want []string
+	}{
+		{"none", func(*options) {}, all},
+		{"grep", func(o *options) { o.grep = regexp.MustCompile("package") }, []string{"old.go", "scratch.go", "sub/new.go"}},
+		{"skip-matching", func(o *options) { o.skipMatching = regexp.MustCompile("package") },
+			[]string{"big.txt", "img.png", "latin1.txt", "min.js"}},
+		{"max-size", func(o *options) { o.maxSize = 100 }, []string{"img.png", "latin1.txt", "old.go", "scratch.go", "sub/new.go"}},
+		{"tracked-only", func(o *options) { o.trackedOnly = true }, []string{"old.go", "sub/new.go"}},
+		// Untracked files count as recent.
+		{"newer-commit", func(o *options) { o.newerCommit = time.Now().AddDate(0, 0, -30) },
+			[]string{"big.txt", "img.png", "latin1.txt", "min.js", "scratch.go", "sub/new.go"}},
+		{"mime", func(o *options) { o.mime = "image/png" }, []string{"img.png"}},
+		{"lang-filter", func(o *options) { o.langFilter = []string{"go"} }, []string{"old.go", "scratch.go", "sub/new.go"}},
+		{"require-utf8", func(o *options) { o.requireUTF8 = true }, []string{"big.txt", "min.js", "old.go", "scratch.go", "sub/new.go"}},
+		{"skip-minified", func(o *options) { o.skipMinified = true }, []string{"big.txt", "img.png", "latin1.txt", "old.go", "scratch.go", "sub/new.go"}},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.recurse = true
+		tt.set(opts)
+		out := filepath.Join(t.TempDir(), "dump")
+		if err := newRunner(opts).exactDump(&pathList{paths: []string{"."}}, out); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		dump, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := exactNames(t, dump)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("-exact with %s dumped %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A lineFilter rewrites file contents one line at a time. Filters are created
+// per file and may keep state between lines.
+type lineFilter interface {
+	// filter returns the replacement for line, which includes its
+	// terminating newline unless it is the last line of the file. The
+	// result may hold any number of lines, or none.
+	filter(line []byte) []byte
+	// flush returns anything held back until the end of the file.
+	flush() []byte
+}
+
+// filterOrder is the order the filters run in, unless -transforms gives
+// another one for the transforms in it.
+var filterOrder = []string{
+	// -blame goes by the lines as they are in the file.
+	"blame",
+	"nfc",
+	"trim-trailing-blank-lines",
+	"dedup-blocks",
+	"strip-imports",
+	"compact-imports",
+	"editorconfig",
+	"reindent",
+	"comment-out",
+	"highlight",
+	"show-whitespace",
+	"line-template",
+	"wrap",
+	// Truncation comes last so that it counts the lines actually shown.
+	"max-lines",
+	"html-escape",
+}
+
+// buildFilters returns the filters that opts asks for, in the order they
+// should run.
+func buildFilters(name string, opts *options) []lineFilter {
+	order := filterOrder
+	if opts.transforms != nil {
+		// -blame, -highlight and -line-template aren't transforms,
+		// and keep their places before and after the content is
+		// settled.
+		order = append(append([]string{"blame"}, opts.transforms...), "highlight", "line-template")
+	}
+	var filters []lineFilter
+	for _, f := range order {
+		filters = append(filters, namedFilters(f, name, opts)...)
+	}
+	// Escaping comes after that, so that it covers everything printed.
+	// Markdown fences are already chosen to be longer than any in the file.
+	if opts.escapePrefix != "" && !opts.markdown {
+		filters = append(filters, delimiterFilter{prefix: []byte(opts.escapePrefix), xml: opts.xml, frontMatter: opts.frontMatter, merged: opts.mergeSmall > 0})
+	}
+	return filters
+}
+
+// namedFilters returns the filters for the file name that the entry f in
+// filterOrder stands for, if opts turns it on.
+func namedFilters(f, name string, opts *options) []lineFilter {
+	switch {
+	case f == "blame" && opts.blameLines != nil:
+		return []lineFilter{&blameFilter{prefixes: opts.blameLines}}
+	case f == "nfc" && opts.nfc:
+		return []lineFilter{nfcFilter{}}
+	case f == "trim-trailing-blank-lines" && opts.trimBlankEnds:
+		return []lineFilter{&trailingBlankFilter{}}
+	case f == "dedup-blocks" && opts.dedupBlocks > 0:
+		return []lineFilter{&blockDedupFilter{min: opts.dedupBlocks}}
+	case f == "strip-imports" && opts.stripImports && importLanguage[detectLanguage(name, nil)]:
+		return []lineFilter{&importStripFilter{lang: detectLanguage(name, nil)}}
+	case f == "compact-imports" && opts.compactImports && detectLanguage(name, nil) == langPython:
+		return []lineFilter{&pyImportFilter{}}
+	case f == "editorconfig" && opts.editorconfig:
+		return editorconfigFilters(name)
+	case f == "reindent" && opts.reindent > 0 && reindentUnsafe(name) == "":
+		return []lineFilter{&reindentFilter{level: bytes.Repeat([]byte(" "), opts.reindent), syntax: reindentLanguages[detectLanguage(name, nil)]}}
+	case f == "comment-out" && opts.commentOut:
+		prefix := detectLanguage(name, nil).comment
+		if prefix == "" {
+			prefix = "# "
+		}
+		return []lineFilter{commentFilter{prefix: []byte(prefix)}}
+	case f == "highlight" && opts.highlight != nil:
+		return []lineFilter{highlightFilter{re: opts.highlight}}
+	case f == "show-whitespace" && opts.showWhitespace:
+		return []lineFilter{&whitespaceFilter{}}
+	case f == "line-template" && opts.lineTemplate != "":
+		path := name
+		if opts.posixPaths {
+			path = filepath.ToSlash(path)
+		}
+		return []lineFilter{&lineTemplateFilter{format: opts.lineTemplate, path: path}}
+	case f == "wrap" && opts.wrap > 0:
+		return []lineFilter{wrapFilter{width: opts.wrap}}
+	case f == "max-lines" && opts.maxLines > 0:
+		return []lineFilter{&maxLinesFilter{max: opts.maxLines}}
+	case f == "html-escape" && opts.htmlEscape:
+		return []lineFilter{htmlEscapeFilter{}}
+	}
+	return nil
+}
+
+// copyLines copies src to dst through filters and returns the number of bytes
+// read from src.
+func copyLines(dst io.Writer, src io.Reader, filters []lineFilter) (int64, error) {
+	br := bufio.NewReader(src)
+	var read int64
+	c := &filterChain{w: dst, filters: filters}
+	for {
+		line, err := br.ReadBytes('\n')
+		read += int64(len(line))
+		if len(line) > 0 {
+			c.run(0, line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return read, err
+		}
+	}
+	for i := range filters {
+		c.run(i+1, filters[i].flush())
+	}
+	return read, c.err
+}
+
+// filterChain feeds the output of each filter, line by line, to the next.
+type filterChain struct {
+	w       io.Writer
+	filters []lineFilter
+	err     error
+}
+
+// run passes data through filters[i:] and writes the result.
+func (c *filterChain) run(i int, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if i == len(c.filters) {
+		if c.err == nil {
+			_, c.err = c.w.Write(data)
+		}
+		return
+	}
+	for len(data) > 0 {
+		n := bytes.IndexByte(data, '\n') + 1
+		if n == 0 {
+			n = len(data)
+		}
+		c.run(i+1, c.filters[i].filter(data[:n]))
+		data = data[n:]
+	}
+}
+
+// splitEOL splits line into its text and its line ending ("\n", "\r\n" or
+// nothing).
+func splitEOL(line []byte) (text, eol []byte) {
+	if bytes.HasSuffix(line, []byte("\r\n")) {
+		return line[:len(line)-2], line[len(line)-2:]
+	}
+	if bytes.HasSuffix(line, []byte("\n")) {
+		return line[:len(line)-1], line[len(line)-1:]
+	}
+	return line, nil
+}
+
+// whitespaceFilter makes invisible characters visible for -show-whitespace:
+// tabs become →, trailing spaces ·, and line endings ↵ (LF) or ⏎↵ (CRLF).
+type whitespaceFilter struct{}
+
+func (whitespaceFilter) filter(line []byte) []byte {
+	text, eol := splitEOL(line)
+	trimmed := bytes.TrimRight(text, " ")
+	var b bytes.Buffer
+	b.Write(bytes.ReplaceAll(trimmed, []byte("\t"), []byte("→")))
+	b.Write(bytes.Repeat([]byte("·"), len(text)-len(trimmed)))
+	switch string(eol) {
+	case "\r\n":
+		b.WriteString("⏎↵\n")
+	case "\n":
+		b.WriteString("↵\n")
+	}
+	return b.Bytes()
+}
+
+func (whitespaceFilter) flush() []byte { return nil }
+
+// anchorTemplate is the -line-template that -anchors uses: a tag short and
+// regular enough for a model to copy back exactly when citing a line.
+const anchorTemplate = "[{path}:{line}] {text}"
+
+// lineTemplateFilter prints each line through the -line-template format.
+// The line ending, if any, stays outside the template.
+type lineTemplateFilter struct {
+	format, path string
+	n            int
+}
+
+func (t *lineTemplateFilter) filter(line []byte) []byte {
+	t.n++
+	text, eol := splitEOL(line)
+	r := strings.NewReplacer("{path}", t.path, "{line}", strconv.Itoa(t.n), "{text}", string(text))
+	return append([]byte(r.Replace(t.format)), eol...)
+}
+
+func (*lineTemplateFilter) flush() []byte { return nil }
+
+// maxLinesFilter keeps the first max lines for -max-lines and counts the rest.
+type maxLinesFilter struct {
+	max, seen int
+}
+
+func (m *maxLinesFilter) filter(line []byte) []byte {
+	m.seen++
+	if m.seen > m.max {
+		return nil
+	}
+	return line
+}
+
+func (m *maxLinesFilter) flush() []byte {
+	if m.seen <= m.max {
+		return nil
+	}
+	return []byte(fmt.Sprintf("... [truncated, %d more %s]\n", m.seen-m.max, plural(m.seen-m.max, "line")))
+}
+
+// htmlEscapeFilter escapes <, >, &, ' and " for -html-escape.
+type htmlEscapeFilter struct{}
+
+func (htmlEscapeFilter) filter(line []byte) []byte {
+	return []byte(html.EscapeString(string(line)))
+}
+
+func (htmlEscapeFilter) flush() []byte { return nil }
+
+// delimiterFilter prefixes lines that would be taken for a file header or
+// footer, for -escape-delimiters. Lines that already start with the prefix
+// followed by such a line get another one, so a reader can undo the escaping
+// by removing one prefix from any line that looks like a delimiter after it.
+type delimiterFilter struct {
+	prefix      []byte
+	xml         bool
+	frontMatter bool // only --- lines are delimiters
+	merged      bool // -merge-small sub-headers, -- path --, are delimiters too
+}
+
+func (d delimiterFilter) filter(line []byte) []byte {
+	text, _ := splitEOL(line)
+	for bytes.HasPrefix(text, d.prefix) {
+		text = text[len(d.prefix):]
+	}
+	if !d.delimiter(text) {
+		return line
+	}
+	return append(append([]byte(nil), d.prefix...), line...)
+}
+
+// delimiter reports whether text, a line without its ending, looks like one
+// of the lines printed around files.
+func (d delimiterFilter) delimiter(text []byte) bool {
+	if d.xml {
+		return bytes.HasPrefix(text, []byte("<file")) || bytes.HasPrefix(text, []byte("</file"))
+	}
+	if d.frontMatter {
+		return bytes.Equal(text, []byte("---"))
+	}
+	if d.merged && len(text) >= len("-- --") && bytes.HasPrefix(text, []byte("-- ")) && bytes.HasSuffix(text, []byte(" --")) {
+		return true
+	}
+	return len(text) >= len("--- ---") && bytes.HasPrefix(text, []byte("--- ")) && bytes.HasSuffix(text, []byte(" ---"))
+}
+
+func (delimiterFilter) flush() []byte { return nil }
+
+// trailingBlankFilter drops the blank (or whitespace-only) lines at the end
+// of a file for -trim-trailing-blank-lines. Blank lines are held back until
+// a line with text follows them.
+type trailingBlankFilter struct {
+	held []byte
+}
+
+func (t *trailingBlankFilter) filter(line []byte) []byte {
+	if len(bytes.TrimSpace(line)) == 0 {
+		t.held = append(t.held, line...)
+		return nil
+	}
+	out := append(t.held, line...)
+	t.held = nil
+	return out
+}
+
+func (*trailingBlankFilter) flush() []byte { return nil }
+
+// commentFilter starts each line with prefix, a line comment marker, for
+// -comment-out. Empty lines get the marker without its trailing space.
+type commentFilter struct {
+	prefix []byte
+}
+
+func (c commentFilter) filter(line []byte) []byte {
+	text, eol := splitEOL(line)
+	prefix := c.prefix
+	if len(text) == 0 {
+		prefix = bytes.TrimRight(prefix, " ")
+	}
+	return append(append(append([]byte(nil), prefix...), text...), eol...)
+}
+
+func (commentFilter) flush() []byte { return nil }
+
+// highlightFilter wraps each match of re in ANSI reverse video for
+// -highlight. Line endings are left out of matches, and empty matches are not
+// marked.
+type highlightFilter struct {
+	re *regexp.Regexp
+}
+
+func (h highlightFilter) filter(line []byte) []byte {
+	text, eol := splitEOL(line)
+	var b bytes.Buffer
+	last := 0
+	for _, m := range h.re.FindAllIndex(text, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		b.Write(text[last:m[0]])
+		b.WriteString("\x1b[7m")
+		b.Write(text[m[0]:m[1]])
+		b.WriteString("\x1b[27m")
+		last = m[1]
+	}
+	if last == 0 {
+		return line
+	}
+	b.Write(text[last:])
+	b.Write(eol)
+	return b.Bytes()
+}
+
+func (highlightFilter) flush() []byte { return nil }
+
+// isTerminal reports whether f is a terminal, as opposed to a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
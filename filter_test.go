@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestHTMLEscape(t *testing.T) {
+	const script = "<script>alert(\"x\") & 'y'</script>\n"
+	const escaped = "&lt;script&gt;alert(&#34;x&#34;) &amp; &#39;y&#39;&lt;/script&gt;\n"
+	tests := []struct {
+		name string
+		set  func(*options)
+		want string
+	}{
+		{"plain", func(o *options) {}, "\n--- a&lt;b&gt;.html ---\n" + escaped + "\n"},
+		{"xml", func(o *options) { o.xml = true }, "\n<file path=\"a&lt;b&gt;.html\">\n" + escaped + "</file>\n"},
+		{"md", func(o *options) { o.markdown = true }, "\n### a&lt;b&gt;.html\n\n```html\n" + escaped + "```\n"},
+	}
+	chdir(t, t.TempDir())
+	writeFiles(t, ".", map[string]string{"a<b>.html": script})
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.htmlEscape = true
+		tt.set(opts)
+		if got := dumpFiles(t, opts, "a<b>.html"); got != tt.want {
+			t.Errorf("%s: printed %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLEscapeFilter(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain text\n", "plain text\n"},
+		{"<script>\n", "&lt;script&gt;\n"},
+		{"a && b", "a &amp;&amp; b"},
+		{"&amp;\n", "&amp;amp;\n"},
+	}
+	for _, tt := range tests {
+		if got := string(htmlEscapeFilter{}.filter([]byte(tt.in))); got != tt.want {
+			t.Errorf("filter(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMaxLinesFilter(t *testing.T) {
+	tests := []struct {
+		lines int
+		want  string
+	}{
+		{2, ""},
+		{3, "... [truncated, 1 more line]\n"},
+		{4, "... [truncated, 2 more lines]\n"},
+	}
+	for _, tt := range tests {
+		m := &maxLinesFilter{max: 2}
+		for range tt.lines {
+			m.filter([]byte("x\n"))
+		}
+		if got := string(m.flush()); got != tt.want {
+			t.Errorf("-max-lines 2 of %d lines: flush() = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// follow prints the file name with a header, then keeps printing what is
+// appended to it, as tail -f does, until it is interrupted. The contents are
+// printed as they are, without the line filters, since a filter can't know
+// where a growing file ends. Only the first sample is checked for binary
+// data. If the file shrinks, it is taken to have been truncated and is
+// printed again from the start.
+func (r *runner) follow(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil {
+		return err
+	} else if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", name)
+	}
+	sample, err := readSample(f, r.opts.sampleSize)
+	if err != nil {
+		return err
+	}
+	if r.binary(name, sample) {
+		return fmt.Errorf("%s is a binary file", name)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(r.out, "\n--- %s ---\n", r.displayName(name))
+	out := &lastByteWriter{w: r.out}
+	out.Write(sample)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := io.CopyBuffer(struct{ io.Writer }{out}, struct{ io.Reader }{f}, buf)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			if !sleep(ctx, pollInterval) {
+				break
+			}
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if at, err := f.Seek(0, io.SeekCurrent); err == nil && info.Size() < at {
+			fmt.Fprintf(os.Stderr, "%s: file truncated\n", name)
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	}
+	r.closeBlock(out.n > 0 && out.last != '\n', "")
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// printFrontMatter prints body as a -front-matter block for name: a YAML
+// header between --- lines, giving the size of the contents in bytes and
+// lines, and then the contents as they are. sample is as for printBlock.
+// The contents are buffered so that the header can give their size.
+func (r *runner) printFrontMatter(name string, sample []byte, body io.Reader) (int64, error) {
+	var b bytes.Buffer
+	out := &lastByteWriter{w: &b, kinds: r.lineKindsFor(name, sample)}
+	written, err := copyContents(out, body, name, r.opts)
+	r.stats.add(name, out.n, out.lineCount())
+	r.stats.addKinds(name, out.kinds)
+	if err != nil {
+		return written, err
+	}
+	if unterminated(b.Bytes()) {
+		b.WriteByte('\n')
+	}
+	start := r.offset()
+	fmt.Fprintf(r.out, "\n---\n%s---\n", r.frontMatter(name, r.fenceLanguage(name, sample), b.Bytes()))
+	r.out.Write(b.Bytes())
+	r.indexBlock(name, start)
+	return written, nil
+}
+
+// frontMatterMarker prints a -front-matter block with no contents for name,
+// noting why they were left out.
+func (r *runner) frontMatterMarker(name, note string) {
+	saved := r.note
+	r.note = note
+	fmt.Fprintf(r.out, "\n---\n%s---\n", r.frontMatter(name, "", nil))
+	r.note = saved
+}
+
+// frontMatter returns the YAML header lines for name, in language lang
+// ("" if unknown), with contents data.
+func (r *runner) frontMatter(name, lang string, data []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "path: %s\n", yamlString(r.displayName(name)))
+	if r.part != "" {
+		part := strings.TrimSuffix(strings.TrimPrefix(r.part, " (part "), ")")
+		fmt.Fprintf(&b, "part: %s\n", yamlString(part))
+	}
+	fmt.Fprintf(&b, "size: %d\nlines: %d\n", len(data), bytes.Count(data, []byte("\n")))
+	if lang != "" {
+		fmt.Fprintf(&b, "lang: %s\n", lang)
+	}
+	if code := r.gitStatusOf(name); code != "" {
+		fmt.Fprintf(&b, "git-status: %s\n", yamlString(code))
+	}
+	if r.note != "" {
+		fmt.Fprintf(&b, "note: %s\n", yamlString(r.note))
+	}
+	return b.String()
+}
+
+// plainYAML matches strings that can be written in YAML without quotes and
+// still read back as the same string.
+var plainYAML = regexp.MustCompile(`^[A-Za-z0-9_./][A-Za-z0-9_./+-]*$`)
+
+// yamlString returns s as a YAML scalar, double-quoted unless it is plain.
+// Go's escapes are a subset of YAML's, so strconv.Quote does the quoting.
+func yamlString(s string) string {
+	_, err := strconv.ParseFloat(s, 64)
+	if plainYAML.MatchString(s) && !yamlSpecial[strings.ToLower(s)] && err != nil {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// yamlSpecial holds the plain scalars that YAML reads as something other
+// than a string.
+var yamlSpecial = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"null": true, "~": true, ".nan": true, ".inf": true,
+}
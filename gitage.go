@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// A commitHistory is what -newer-commit and -tracked-only know about one
+// git work tree: the files committed to since the cutoff, and the files git
+// tracks at all, by slash-separated path from the top of the tree.
+type commitHistory struct {
+	recent, tracked map[string]bool
+}
+
+// loadCommitHistory asks git about the work tree at top: two commands, for
+// the whole tree, however many of its files are printed, or only one if
+// since is zero and the history isn't needed.
+func loadCommitHistory(top string, since time.Time) (*commitHistory, error) {
+	h := &commitHistory{recent: make(map[string]bool), tracked: make(map[string]bool)}
+	if !since.IsZero() {
+		out, err := exec.Command("git", "-C", top, "log", "--since="+since.Format(time.RFC3339), "--format=", "--name-only", "-z").Output()
+		if err != nil {
+			return nil, fmt.Errorf("git log: %v", gitError(err))
+		}
+		for _, name := range bytes.Split(out, []byte{0}) {
+			if name := bytes.TrimSpace(name); len(name) > 0 {
+				h.recent[string(name)] = true
+			}
+		}
+	}
+	out, err := exec.Command("git", "-C", top, "ls-files", "-z").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files: %v", gitError(err))
+	}
+	for _, name := range bytes.Split(out, []byte{0}) {
+		if len(name) > 0 {
+			h.tracked[string(name)] = true
+		}
+	}
+	return h, nil
+}
+
+// history returns what git knows about the work tree holding the file at
+// path, and the file's name in it, or nil if it is in no work tree.
+func (r *runner) history(path string) (*commitHistory, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", nil
+	}
+	dir, top := r.gitTop(abs)
+	if top == "" {
+		return nil, "", nil
+	}
+	rel, err := filepath.Rel(top, filepath.Join(dir, filepath.Base(abs)))
+	if err != nil {
+		return nil, "", nil
+	}
+	h, ok := r.histories[top]
+	if !ok {
+		if h, err = loadCommitHistory(top, r.opts.newerCommit); err != nil {
+			return nil, "", err
+		}
+		r.histories[top] = h
+	}
+	return h, filepath.ToSlash(rel), nil
+}
+
+// committedRecently reports whether the file at path was last committed
+// after the -newer-commit cutoff. Files git doesn't track, whether untracked
+// or outside any work tree, have no history to go by and count as recent.
+func (r *runner) committedRecently(path string) (bool, error) {
+	h, rel, err := r.history(path)
+	if h == nil {
+		return true, err
+	}
+	return h.recent[rel] || !h.tracked[rel], nil
+}
+
+// tracked reports whether git tracks the file at path, for -tracked-only.
+func (r *runner) tracked(path string) (bool, error) {
+	h, rel, err := r.history(path)
+	return h != nil && h.tracked[rel], err
+}
+
+// reportUntracked says on stderr how many files -tracked-only left out.
+func (r *runner) reportUntracked() {
+	fmt.Fprintf(os.Stderr, "Skipped %d %s git doesn't track (-tracked-only)\n", r.untracked, plural(r.untracked, "file"))
+}
+
+// reportOldCommits says on stderr how many files -newer-commit left out.
+func (r *runner) reportOldCommits() {
+	fmt.Fprintf(os.Stderr, "Skipped %d %s last committed more than %s ago (-newer-commit)\n", r.oldCommits, plural(r.oldCommits, "file"), r.opts.newerCommitAge)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// gitRepo makes a work tree under a temporary directory in which old.go was
+// last committed a year ago, new.go an hour ago, and scratch.go is untracked.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(date string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com", "GIT_COMMITTER_NAME=t",
+			"GIT_COMMITTER_EMAIL=t@example.com", "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("", "init", "-q")
+	writeFiles(t, dir, map[string]string{"old.go": "package old\n"})
+	git(time.Now().AddDate(-1, 0, 0).Format(time.RFC3339), "add", "old.go")
+	git(time.Now().AddDate(-1, 0, 0).Format(time.RFC3339), "commit", "-qm", "old")
+	writeFiles(t, dir, map[string]string{"sub/new.go": "package sub\n", "scratch.go": "package scratch\n"})
+	git(time.Now().Add(-time.Hour).Format(time.RFC3339), "add", "sub/new.go")
+	git(time.Now().Add(-time.Hour).Format(time.RFC3339), "commit", "-qm", "new")
+	return dir
+}
+
+func TestTracked(t *testing.T) {
+	dir := gitRepo(t)
+	outside := filepath.Join(t.TempDir(), "loose.go")
+	writeFiles(t, filepath.Dir(outside), map[string]string{"loose.go": "package loose\n"})
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(dir, "old.go"), true},
+		{filepath.Join(dir, "sub", "new.go"), true},
+		{filepath.Join(dir, "scratch.go"), false},
+		{outside, false},
+	}
+	r := newRunner(testOptions())
+	for _, tt := range tests {
+		got, err := r.tracked(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("tracked(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if len(r.histories) != 1 {
+		t.Errorf("asked git about %d work trees, want 1", len(r.histories))
+	}
+}
+
+func TestReportUntracked(t *testing.T) {
+	tests := []struct {
+		untracked int
+		want      string
+	}{
+		{0, ""},
+		{1, "Skipped 1 file git doesn't track (-tracked-only)\n"},
+		{2, "Skipped 2 files git doesn't track (-tracked-only)\n"},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.quiet = false
+		opts.trackedOnly = true
+		r := newRunner(opts)
+		r.stats = newDumpStats()
+		r.untracked = tt.untracked
+		got := captureStderr(t, func() {
+			if err := r.finish(); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if got != tt.want {
+			t.Errorf("untracked %d: reported %q, want %q", tt.untracked, got, tt.want)
+		}
+	}
+}
+
+func TestCommittedRecently(t *testing.T) {
+	dir := gitRepo(t)
+	outside := filepath.Join(t.TempDir(), "loose.go")
+	writeFiles(t, filepath.Dir(outside), map[string]string{"loose.go": "package loose\n"})
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(dir, "old.go"), false},
+		{filepath.Join(dir, "sub", "new.go"), true},
+		// Files git has no history for count as recent.
+		{filepath.Join(dir, "scratch.go"), true},
+		{outside, true},
+	}
+	opts := testOptions()
+	opts.newerCommit = time.Now().AddDate(0, 0, -30)
+	r := newRunner(opts)
+	for _, tt := range tests {
+		got, err := r.committedRecently(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("committedRecently(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestReportOldCommits(t *testing.T) {
+	tests := []struct {
+		old  int
+		want string
+	}{
+		{0, ""},
+		{1, "Skipped 1 file last committed more than 30d ago (-newer-commit)\n"},
+		{3, "Skipped 3 files last committed more than 30d ago (-newer-commit)\n"},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.quiet = false
+		opts.newerCommit, opts.newerCommitAge = time.Now(), "30d"
+		r := newRunner(opts)
+		r.stats = newDumpStats()
+		r.oldCommits = tt.old
+		got := captureStderr(t, func() {
+			if err := r.finish(); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if got != tt.want {
+			t.Errorf("old %d: reported %q, want %q", tt.old, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// loadGitStatus runs git status once for the repository containing the
+// current directory, and returns each changed or untracked file's status
+// code (M, A, ??, ...), keyed by absolute path.
+func loadGitStatus() (map[string]string, error) {
+	top, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-parse: %v", gitError(err))
+	}
+	root := strings.TrimSpace(string(top))
+	out, err := exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status: %v", gitError(err))
+	}
+	status := make(map[string]string)
+	entries := bytes.Split(out, []byte{0})
+	for i := 0; i < len(entries); i++ {
+		entry := string(entries[i])
+		if len(entry) < 4 {
+			continue
+		}
+		code := strings.TrimSpace(entry[:2])
+		status[filepath.Join(root, filepath.FromSlash(entry[3:]))] = code
+		if entry[0] == 'R' || entry[0] == 'C' {
+			// A rename or copy is followed by the path it came from.
+			i++
+		}
+	}
+	return status, nil
+}
+
+// gitError adds git's own message, if any, to err.
+func gitError(err error) error {
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(ee.Stderr))
+	}
+	return err
+}
+
+// gitRootPath returns path relative to the top of the git work tree that
+// holds it, for -git-root-paths, or path as it is if there is no such file or
+// it isn't in a work tree. git is asked once per directory.
+func (r *runner) gitRootPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return path
+	}
+	dir, top := r.gitTop(abs)
+	if top == "" {
+		return path
+	}
+	rel, err := filepath.Rel(top, filepath.Join(dir, filepath.Base(abs)))
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// gitTop returns the real directory holding abs, an absolute path, and the
+// top of the git work tree holding that, or "" if it isn't in one. git is
+// asked once per directory.
+func (r *runner) gitTop(abs string) (dir, top string) {
+	// git reports the real location of the work tree.
+	dir = filepath.Dir(abs)
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	top, ok := r.gitRoots[dir]
+	if !ok {
+		if out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output(); err == nil {
+			top = strings.TrimSpace(string(out))
+		}
+		if r.gitRoots == nil {
+			r.gitRoots = make(map[string]string)
+		}
+		r.gitRoots[dir] = top
+	}
+	return dir, top
+}
+
+// gitStatusOf returns the -git-status code for the file at path, or "" if it
+// is unchanged or git doesn't know about it.
+func (r *runner) gitStatusOf(path string) string {
+	if r.gitStatus == nil || path == "-" {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	if code, ok := r.gitStatus[abs]; ok {
+		return code
+	}
+	// git reports paths under the real location of the repository.
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return r.gitStatus[real]
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob reports whether name, a slash-separated path, matches pattern.
+// Each element of the pattern is matched against one element of name as
+// path.Match does, except that an element of just ** matches any number of
+// elements, including none, so src/**/*_test.go matches both
+// src/x_test.go and src/a/b/x_test.go. A malformed pattern matches nothing.
+func matchGlob(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// hasGlob reports whether s has any glob metacharacters in it.
+func hasGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// expandGlob returns the files that pattern, a command-line argument, names
+// with -resolve-globs-recursively, in the order a walk finds them. The walk
+// starts from the directory named by the elements before the first one with
+// a metacharacter in it, and skips hidden and excluded directories as -r
+// does.
+func expandGlob(pattern string, opts *options) ([]string, error) {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %v", pattern, err)
+	}
+	elems := strings.Split(pattern, "/")
+	n := 0
+	for n < len(elems)-1 && !hasGlob(elems[n]) {
+		n++
+	}
+	root := strings.Join(elems[:n], "/")
+	switch {
+	case root == "" && strings.HasPrefix(pattern, "/"):
+		root = "/"
+	case root == "":
+		root = "."
+	}
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != filepath.FromSlash(root) && strings.HasPrefix(d.Name(), ".") && !opts.all {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if p != filepath.FromSlash(root) && prunes(p, opts) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matchGlob(pattern, filepath.ToSlash(p)) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match %s", pattern)
+	}
+	return matches, nil
+}
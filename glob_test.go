@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"src/**/*_test.go", "src/x_test.go", true},
+		{"src/**/*_test.go", "src/a/b/x_test.go", true},
+		{"src/**/*_test.go", "src/a/b/x.go", false},
+		{"src/**/*_test.go", "lib/src/x_test.go", false},
+		{"**/vendor/**", "vendor/a.go", true},
+		{"**/vendor/**", "a/b/vendor/c/d.go", true},
+		{"**/vendor/**", "vendors/a.go", false},
+		{"**", "", true},
+		{"**", "any/thing/at/all", true},
+		{"a/**/**/b", "a/b", true},
+		{"a/**/**/b", "a/x/y/b", true},
+		{"a/**b", "a/xb", true}, // ** inside an element is just *
+		{"a/**b", "a/x/b", false},
+		{"docs/?.md", "docs/a.md", true},
+		{"docs/[ab].md", "docs/c.md", false},
+		{"[", "[", false}, // malformed
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpandGlob(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeFiles(t, ".", map[string]string{
+		"src/a_test.go":         "",
+		"src/a.go":              "",
+		"src/sub/b_test.go":     "",
+		"src/.hidden/c_test.go": "",
+		"src/node_modules/d.go": "",
+		"other/e_test.go":       "",
+	})
+	tests := []struct {
+		pattern string
+		all     bool
+		want    []string
+	}{
+		{"src/**/*_test.go", false, []string{"src/a_test.go", "src/sub/b_test.go"}},
+		{"src/**/*_test.go", true, []string{"src/.hidden/c_test.go", "src/a_test.go", "src/sub/b_test.go"}},
+		{"**/*_test.go", false, []string{"other/e_test.go", "src/a_test.go", "src/sub/b_test.go"}},
+		{"src/*.go", false, []string{"src/a.go", "src/a_test.go"}},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.all = tt.all
+		got, err := expandGlob(tt.pattern, opts)
+		if err != nil {
+			t.Fatalf("expandGlob(%q): %v", tt.pattern, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("expandGlob(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+	for _, pattern := range []string{"src/**/*.rs", "src/[.go"} {
+		if _, err := expandGlob(pattern, testOptions()); err == nil {
+			t.Errorf("expandGlob(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestIncludeExcludeGlobs(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeFiles(t, ".", map[string]string{"src/a_test.go": "", "src/a.go": "", "src/sub/b_test.go": "", "other/e_test.go": ""})
+	tests := []struct {
+		include, exclude []string
+		want             string
+	}{
+		{[]string{"src/**/*_test.go"}, nil, "src/a_test.go\nsrc/sub/b_test.go\n"},
+		{nil, []string{"**/sub/**"}, "other/e_test.go\nsrc/a.go\nsrc/a_test.go\n"},
+		{[]string{"**/*_test.go"}, []string{"other/**"}, "src/a_test.go\nsrc/sub/b_test.go\n"},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.recurse, opts.namesOnly = true, true
+		opts.include, opts.exclude = tt.include, tt.exclude
+		if got := dumpFiles(t, opts, "."); got != tt.want {
+			t.Errorf("-include %q -exclude %q: printed %q, want %q", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+}
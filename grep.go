@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+)
+
+// grepWindows returns the lines of data within context lines of each line
+// that re matches. Windows that overlap or touch are merged into one, so no
+// line is printed twice, and "...\n" separates the ones with lines left out
+// between them. With first set, only the first match's window is returned.
+func grepWindows(data []byte, re *regexp.Regexp, context int, first bool) []byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	var b bytes.Buffer
+	end := -1 // the end of the lines written so far, or -1 before any
+	for i, line := range lines {
+		if !re.Match(line) {
+			continue
+		}
+		start, stop := max(0, i-context), min(len(lines), i+context+1)
+		if start > end && end >= 0 {
+			b.WriteString("...\n")
+		}
+		for _, l := range lines[max(start, end):stop] {
+			b.Write(l)
+			if !bytes.HasSuffix(l, []byte("\n")) {
+				b.WriteByte('\n')
+			}
+		}
+		end = max(end, stop)
+		if first {
+			break
+		}
+	}
+	return b.Bytes()
+}
+
+// fileMatches reports whether the contents of the file at path match re.
+func fileMatches(path string, re *regexp.Regexp) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return readerMatches(f, re)
+}
+
+// readerMatches reports whether the contents of in match re, reading only
+// as far as the end of the first match.
+func readerMatches(in io.Reader, re *regexp.Regexp) (bool, error) {
+	er := &errReader{r: in}
+	ok := re.MatchReader(bufio.NewReader(er))
+	return ok, er.err
+}
+
+// An errReader remembers the first error other than io.EOF that reading r
+// returns, for callers such as regexp.MatchReader that drop errors.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
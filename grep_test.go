@@ -0,0 +1,45 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGrepWindows(t *testing.T) {
+	// Lines 1 to 10, each holding its number.
+	var lines []string
+	for _, n := range []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"} {
+		lines = append(lines, "line "+n+"\n")
+	}
+	data := strings.Join(lines, "")
+	tests := []struct {
+		name    string
+		pattern string
+		context int
+		first   bool
+		want    string
+	}{
+		{"one match", `line 5\n`, 1, false, "line 4\nline 5\nline 6\n"},
+		{"no context", `line [28]\n`, 0, false, "line 2\n...\nline 8\n"},
+		{"overlapping", `line [45]\n`, 1, false, "line 3\nline 4\nline 5\nline 6\n"},
+		{"adjacent", `line [36]\n`, 1, false, "line 2\nline 3\nline 4\nline 5\nline 6\nline 7\n"},
+		{"separate", `line [27]\n`, 1, false, "line 1\nline 2\nline 3\n...\nline 6\nline 7\nline 8\n"},
+		{"clipped at ends", `line (1|10)\n`, 2, false, "line 1\nline 2\nline 3\n...\nline 8\nline 9\nline 10\n"},
+		{"one window covers all", `line [2-9]\n`, 3, false, data},
+		{"first", `line [27]\n`, 1, true, "line 1\nline 2\nline 3\n"},
+		{"no match", `nothing`, 1, false, ""},
+	}
+	for _, tt := range tests {
+		if got := string(grepWindows([]byte(data), regexp.MustCompile(tt.pattern), tt.context, tt.first)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGrepWindowsNoFinalNewline(t *testing.T) {
+	got := string(grepWindows([]byte("a\nb\nc"), regexp.MustCompile("c"), 0, false))
+	if want := "c\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// imageFormats names the image types sniffMIME recognizes, for
+// -image-placeholders.
+var imageFormats = map[string]string{
+	"image/bmp":    "BMP",
+	"image/gif":    "GIF",
+	"image/jpeg":   "JPEG",
+	"image/png":    "PNG",
+	"image/webp":   "WebP",
+	"image/x-icon": "ICO",
+}
+
+// printImagePlaceholder prints a block for the image name in place of its
+// contents, holding a line like [image: logo.png, 240x120, PNG] for
+// -image-placeholders. It reports false if sample isn't the start of an
+// image. The size is read from the image's header where Go can decode it
+// (PNG, JPEG and GIF), and left out otherwise.
+func (r *runner) printImagePlaceholder(name string, sample []byte, body io.Reader) (bool, int64, error) {
+	format, ok := imageFormats[sniffMIME(sample)]
+	if !ok {
+		return false, 0, nil
+	}
+	fields := []string{filepath.Base(name)}
+	if cfg, _, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(sample), body)); err == nil {
+		fields = append(fields, fmt.Sprintf("%dx%d", cfg.Width, cfg.Height))
+	}
+	fields = append(fields, format)
+	line := "[image: " + strings.Join(fields, ", ") + "]\n"
+	if r.countingTokens() {
+		r.tokens += int64(r.tok.count([]byte(line)))
+	}
+	n, err := r.printBlock(name, nil, &prefiltered{Reader: bytes.NewReader([]byte(line)), n: int64(len(line))})
+	return true, n, err
+}
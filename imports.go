@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	goImportLine   = regexp.MustCompile(`^import\s+([\w.]+\s+)?"[^"]*"\s*(//.*)?$`)
+	goDeclLine     = regexp.MustCompile(`^(func|type|var|const)\b`)
+	pyImportStart  = regexp.MustCompile(`^(import\s+[\w.]|from\s+[\w.]+\s+import\b)`)
+	jsImportStart  = regexp.MustCompile(`^import(\s+type)?\s*[\w$*{'"]`)
+	jsImportEnd    = regexp.MustCompile(`(^import\s*['"][^'"]*['"]|\bfrom\s*['"][^'"]*['"])\s*;?\s*(//.*)?$`)
+	jsRequireLine  = regexp.MustCompile(`^(const|let|var)\s+[\w$\s{},:]+=\s*require\(\s*['"][^'"]*['"]\s*\)(\.[\w$]+)*\s*;?\s*(//.*)?$`)
+	importLanguage = map[language]bool{langGo: true, langPython: true, langJavaScript: true, langTypeScript: true}
+)
+
+// importStripFilter removes import statements for -strip-imports: Go
+// imports, single or in a block, Python imports, and JavaScript and
+// TypeScript imports and require calls. Each run of imports, with any blank
+// lines between them, becomes one comment saying how many were removed.
+// Only unindented statements are recognized, so imports inside a function
+// are kept, and so is anything that shares a line with other code. Go
+// files are only looked at up to their first declaration, and lines
+// inside Python's triple-quoted strings are left alone.
+type importStripFilter struct {
+	lang    language
+	removed int      // imports in the current run
+	blanks  [][]byte // blank lines since the last of them
+	open    string   // the statement still being removed: "go", "py(", "py\\" or "js"
+	quote   string   // the Python triple quote still open, if any
+	done    bool     // past the imports of a Go file
+}
+
+func (p *importStripFilter) filter(line []byte) []byte {
+	text, _ := splitEOL(line)
+	s := string(text)
+	if p.open != "" {
+		p.continueImport(s)
+		return nil
+	}
+	if p.removed > 0 && len(bytes.TrimSpace(text)) == 0 {
+		p.blanks = append(p.blanks, bytes.Clone(line))
+		return nil
+	}
+	if p.startImport(s) {
+		// The "import (" line of a Go block isn't an import by itself;
+		// each spec in the block is.
+		if p.open != "go" {
+			p.removed++
+		}
+		p.blanks = nil
+		return nil
+	}
+	if p.lang == langPython {
+		p.quote = pyOpenQuote(s, p.quote)
+	}
+	return append(p.flush(), line...)
+}
+
+// startImport reports whether text starts an import statement, noting in
+// p.open if it goes on past this line.
+func (p *importStripFilter) startImport(text string) bool {
+	switch p.lang {
+	case langGo:
+		switch {
+		case p.done:
+		case strings.TrimSpace(text) == "import (":
+			p.open = "go"
+			return true
+		case goImportLine.MatchString(text):
+			return true
+		case goDeclLine.MatchString(text):
+			p.done = true
+		}
+	case langPython:
+		if p.quote != "" || !pyImportStart.MatchString(text) || strings.Contains(text, ";") {
+			return false
+		}
+		code, _, _ := strings.Cut(text, "#")
+		code = strings.TrimSpace(code)
+		switch {
+		case strings.Contains(code, "(") && !strings.Contains(code, ")"):
+			p.open = "py("
+		case strings.HasSuffix(code, "\\"):
+			p.open = "py\\"
+		}
+		return true
+	case langJavaScript, langTypeScript:
+		if jsRequireLine.MatchString(text) {
+			return true
+		}
+		if !jsImportStart.MatchString(text) {
+			return false
+		}
+		switch {
+		case jsImportEnd.MatchString(text):
+		case strings.Contains(text, "{") && !strings.Contains(text, "}"):
+			// A list of names that goes on over the next lines.
+			p.open = "js"
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// continueImport takes text as the next line of the statement in p.open.
+func (p *importStripFilter) continueImport(text string) {
+	code := strings.TrimSpace(text)
+	switch p.open {
+	case "go":
+		switch {
+		case code == ")":
+			p.open = ""
+		case code != "" && !strings.HasPrefix(code, "//"):
+			p.removed++
+		}
+	case "py(":
+		if code, _, _ = strings.Cut(code, "#"); strings.Contains(code, ")") {
+			p.open = ""
+		}
+	case "py\\":
+		if !strings.HasSuffix(code, "\\") {
+			p.open = ""
+		}
+	case "js":
+		if jsImportEnd.MatchString(code) {
+			p.open = ""
+		}
+	}
+}
+
+func (p *importStripFilter) flush() []byte {
+	if p.removed == 0 {
+		return nil
+	}
+	n := p.removed
+	imports := "imports"
+	if n == 1 {
+		imports = "import"
+	}
+	out := fmt.Appendf(nil, "%s[%d %s removed]\n", p.lang.comment, n, imports)
+	for _, b := range p.blanks {
+		out = append(out, b...)
+	}
+	p.removed, p.blanks = 0, nil
+	return out
+}
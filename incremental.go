@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// An incrementalCache compares file contents against the manifest written by
+// a previous -incremental run, and builds the manifest for this one.
+//
+// The manifest uses the format of sha256sum: one "<hex digest>  <path>" line
+// per file, so it can also be checked with "sha256sum -c".
+type incrementalCache struct {
+	path string
+	full bool              // print everything, but still write the manifest
+	prev map[string]string // path -> digest from the previous run
+	next []string          // manifest lines for this run, in output order
+}
+
+// loadIncremental reads the manifest at path. A missing manifest is not an
+// error; every file is then new.
+func loadIncremental(path string, full bool) (*incrementalCache, error) {
+	c := &incrementalCache{path: path, full: full, prev: make(map[string]string)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		digest, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			continue
+		}
+		c.prev[name] = digest
+	}
+	return c, scanner.Err()
+}
+
+// record adds the contents of the file name to this run's manifest, and
+// reports whether they are the same as last time.
+func (c *incrementalCache) record(name string, data []byte) (unchanged bool) {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	c.next = append(c.next, digest+"  "+name)
+	return !c.full && c.prev[name] == digest
+}
+
+// save replaces the manifest with the one built during this run.
+func (c *incrementalCache) save() error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".llm-cat-manifest-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, line := range c.next {
+		fmt.Fprintln(w, line)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
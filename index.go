@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// An indexEntry is where one file's block is in the output, for -index.
+type indexEntry struct {
+	Path   string `json:"path"`
+	Start  int64  `json:"start_offset"`
+	Length int64  `json:"length"`
+}
+
+// A countingWriter counts the bytes written through it, so that -index can
+// tell where each block starts.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// offset returns how many bytes have been printed so far, if -index is set.
+func (r *runner) offset() int64 {
+	if r.counter == nil {
+		return 0
+	}
+	return r.counter.n
+}
+
+// indexBlock records that the block for name (and the part being printed,
+// with -chunk) takes up the output from start to what has been printed so
+// far.
+func (r *runner) indexBlock(name string, start int64) {
+	if r.counter == nil {
+		return
+	}
+	r.index = append(r.index, indexEntry{r.displayName(name) + r.part, start, r.counter.n - start})
+}
+
+// writeIndex writes the -index file: a JSON array if its name ends in
+// .json, and otherwise tab-separated lines under a header line.
+func (r *runner) writeIndex(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		entries := r.index
+		if entries == nil {
+			entries = []indexEntry{}
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(entries)
+	} else {
+		_, err = fmt.Fprintln(f, "path\tstart_offset\tlength")
+		for _, e := range r.index {
+			if err != nil {
+				break
+			}
+			_, err = fmt.Fprintf(f, "%s\t%d\t%d\n", e.Path, e.Start, e.Length)
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reformatsJSON reports whether name is a JSON file that -minify-json or
+// -pretty-json rewrites.
+func (r *runner) reformatsJSON(name string) bool {
+	return (r.opts.minifyJSON || r.opts.prettyJSON) && strings.EqualFold(filepath.Ext(name), ".json")
+}
+
+// reformatJSON returns data, the contents of the JSON file name, without
+// insignificant whitespace for -minify-json, or indented by two spaces for
+// -pretty-json. Invalid JSON is returned as it is, with a warning.
+func (r *runner) reformatJSON(name string, data []byte) []byte {
+	var out bytes.Buffer
+	var err error
+	if r.opts.minifyJSON {
+		err = json.Compact(&out, data)
+	} else {
+		err = json.Indent(&out, data, "", "  ")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s is not valid JSON (%v); printing it as is\n", name, err)
+		return data
+	}
+	out.WriteByte('\n')
+	return out.Bytes()
+}
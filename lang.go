@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A language is what llm-cat knows about a kind of file.
+type language struct {
+	name    string // section name for -group-by-ext
+	fence   string // info string for -md code fences
+	comment string // line comment prefix for -comment-out, if it has one
+}
+
+var (
+	langC          = language{"C", "c", "// "}
+	langCPP        = language{"C++", "cpp", "// "}
+	langCSharp     = language{"C#", "csharp", "// "}
+	langCSS        = language{"CSS", "css", ""}
+	langDiff       = language{"Diff", "diff", ""}
+	langDockerfile = language{"Dockerfile", "dockerfile", "# "}
+	langGo         = language{"Go", "go", "// "}
+	langHTML       = language{"HTML", "html", ""}
+	langJava       = language{"Java", "java", "// "}
+	langJavaScript = language{"JavaScript", "javascript", "// "}
+	langJSON       = language{"JSON", "json", ""}
+	langKotlin     = language{"Kotlin", "kotlin", "// "}
+	langLua        = language{"Lua", "lua", "-- "}
+	langMake       = language{"Makefile", "makefile", "# "}
+	langMarkdown   = language{"Markdown", "markdown", ""}
+	langPerl       = language{"Perl", "perl", "# "}
+	langPHP        = language{"PHP", "php", "// "}
+	langPython     = language{"Python", "python", "# "}
+	langRuby       = language{"Ruby", "ruby", "# "}
+	langRust       = language{"Rust", "rust", "// "}
+	langShell      = language{"Shell", "sh", "# "}
+	langSQL        = language{"SQL", "sql", "-- "}
+	langSwift      = language{"Swift", "swift", "// "}
+	langText       = language{"Text", "text", ""}
+	langTOML       = language{"TOML", "toml", "# "}
+	langTypeScript = language{"TypeScript", "typescript", "// "}
+	langXML        = language{"XML", "xml", ""}
+	langYAML       = language{"YAML", "yaml", "# "}
+
+	// langOther is used for files that can't be classified.
+	langOther = language{"Other", "", ""}
+)
+
+// exactExtLanguages maps -ext-map extensions given under -case-sensitive-ext,
+// in their own case, to languages. They take precedence over extLanguages.
+var exactExtLanguages = map[string]language{}
+
+// extLanguages maps lower-case file extensions to languages.
+var extLanguages = map[string]language{
+	".bash":  langShell,
+	".c":     langC,
+	".h":     langC,
+	".cc":    langCPP,
+	".cpp":   langCPP,
+	".hpp":   langCPP,
+	".cs":    langCSharp,
+	".css":   langCSS,
+	".diff":  langDiff,
+	".go":    langGo,
+	".htm":   langHTML,
+	".html":  langHTML,
+	".java":  langJava,
+	".js":    langJavaScript,
+	".jsx":   langJavaScript,
+	".mjs":   langJavaScript,
+	".cjs":   langJavaScript,
+	".json":  langJSON,
+	".kt":    langKotlin,
+	".lua":   langLua,
+	".md":    langMarkdown,
+	".mk":    langMake,
+	".patch": langDiff,
+	".php":   langPHP,
+	".pl":    langPerl,
+	".py":    langPython,
+	".rb":    langRuby,
+	".rs":    langRust,
+	".sh":    langShell,
+	".sql":   langSQL,
+	".swift": langSwift,
+	".toml":  langTOML,
+	".ts":    langTypeScript,
+	".tsx":   langTypeScript,
+	".txt":   langText,
+	".xml":   langXML,
+	".yaml":  langYAML,
+	".yml":   langYAML,
+	".zsh":   langShell,
+}
+
+// addExtLanguages adds the -ext-map list, such as
+// ".tmpl=go-template,.jenkinsfile=groovy", to extLanguages, replacing what
+// an extension mapped to before. An extension mapped to a language llm-cat
+// knows, by its fence or section name, is treated as that language from
+// then on; any other name becomes a language of its own, used as both. With
+// caseSensitive (-case-sensitive-ext), each extension keeps its case, so
+// .H=cpp leaves .h files alone.
+func addExtLanguages(list string, caseSensitive bool) error {
+	for _, item := range strings.Split(list, ",") {
+		ext, name, ok := strings.Cut(strings.TrimSpace(item), "=")
+		ext, name = strings.TrimSpace(ext), strings.TrimSpace(name)
+		if !ok || ext == "" || ext == "." || name == "" {
+			return fmt.Errorf("%q is not ext=language", item)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		l, ok := namedLanguage(strings.ToLower(name))
+		if !ok {
+			l = language{name: name, fence: name}
+		}
+		if caseSensitive {
+			exactExtLanguages[ext] = l
+		} else {
+			extLanguages[strings.ToLower(ext)] = l
+		}
+	}
+	return nil
+}
+
+// extLanguage returns the language path's extension maps to, if any.
+func extLanguage(path string) (language, bool) {
+	ext := filepath.Ext(path)
+	if l, ok := exactExtLanguages[ext]; ok {
+		return l, true
+	}
+	l, ok := extLanguages[strings.ToLower(ext)]
+	return l, ok
+}
+
+// fileLanguages maps well-known file names that have no extension.
+var fileLanguages = map[string]language{
+	"Dockerfile":    langDockerfile,
+	"GNUmakefile":   langMake,
+	"Gemfile":       langRuby,
+	"Makefile":      langMake,
+	"Rakefile":      langRuby,
+	"Containerfile": langDockerfile,
+	".bashrc":       langShell,
+	".profile":      langShell,
+	".zshrc":        langShell,
+}
+
+// interpreterLanguages maps #! interpreters, with any version suffix
+// removed, to languages.
+var interpreterLanguages = map[string]language{
+	"bash":    langShell,
+	"dash":    langShell,
+	"ksh":     langShell,
+	"lua":     langLua,
+	"node":    langJavaScript,
+	"perl":    langPerl,
+	"php":     langPHP,
+	"python":  langPython,
+	"ruby":    langRuby,
+	"sh":      langShell,
+	"ts-node": langTypeScript,
+	"zsh":     langShell,
+}
+
+// detectLanguage classifies a file by its extension, then by the #! line at
+// the start of head, then by its file name, then by what head holds. It
+// returns langOther if none of them match.
+func detectLanguage(path string, head []byte) language {
+	if l, ok := extLanguage(path); ok {
+		return l
+	}
+	if l, ok := shebangLanguage(head); ok {
+		return l
+	}
+	if l, ok := fileLanguages[filepath.Base(path)]; ok {
+		return l
+	}
+	if l, ok := contentLanguage(head); ok {
+		return l
+	}
+	return langOther
+}
+
+var (
+	goPackageRE   = regexp.MustCompile(`(?m)^package [A-Za-z_]\w*\s*$`)
+	javaPackageRE = regexp.MustCompile(`(?m)^package [\w.]+;`)
+	pythonRE      = regexp.MustCompile(`(?m)^(from [\w.]+ import \S|def \w+\(.*\):\s*$)`)
+)
+
+// contentLanguage guesses the language of a file from head, the start of
+// its contents, for the few languages that are easy to tell apart.
+func contentLanguage(head []byte) (language, bool) {
+	text := bytes.TrimLeft(head, " \t\r\n\ufeff")
+	lower := bytes.ToLower(text[:min(len(text), 16)])
+	switch {
+	case bytes.HasPrefix(text, []byte("<?php")):
+		return langPHP, true
+	case bytes.HasPrefix(text, []byte("<?xml")):
+		return langXML, true
+	case bytes.HasPrefix(lower, []byte("<!doctype html")), bytes.HasPrefix(lower, []byte("<html")):
+		return langHTML, true
+	case goPackageRE.Match(head):
+		return langGo, true
+	case javaPackageRE.Match(head):
+		return langJava, true
+	case pythonRE.Match(head):
+		return langPython, true
+	}
+	return language{}, false
+}
+
+// is reports whether l is one of names, each a -md fence name such as "go"
+// or "sh", or a lower-case section name such as "shell".
+func (l language) is(names []string) bool {
+	for _, n := range names {
+		if n == l.fence || n == strings.ToLower(l.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// knownLanguage reports whether name is a name that language.is can match.
+func knownLanguage(name string) bool {
+	_, ok := namedLanguage(name)
+	return ok
+}
+
+// namedLanguage returns the language that name, such as "python" or "sh",
+// is the -md fence name or lower-case section name of.
+func namedLanguage(name string) (language, bool) {
+	for _, m := range []map[string]language{exactExtLanguages, extLanguages, fileLanguages, interpreterLanguages} {
+		for _, l := range m {
+			if l.is([]string{name}) {
+				return l, true
+			}
+		}
+	}
+	return language{}, false
+}
+
+// wantLanguage reports whether l, the language detected for name, is one of
+// the -lang-filter languages, and says what name was taken for under -v.
+func (r *runner) wantLanguage(name string, l language) bool {
+	if r.opts.verbose {
+		fmt.Fprintf(os.Stderr, "Detected %s as %s\n", r.displayName(name), l.name)
+	}
+	return l.is(r.opts.langFilter)
+}
+
+// fileLanguage is like detectLanguage, but reads the start of the file at
+// path itself when the extension alone doesn't settle it.
+func fileLanguage(path string) language {
+	if l, ok := extLanguage(path); ok {
+		return l
+	}
+	var head []byte
+	if f, err := os.Open(path); err == nil {
+		buf := make([]byte, 256)
+		n, _ := io.ReadFull(f, buf)
+		f.Close()
+		head = buf[:n]
+	}
+	return detectLanguage(path, head)
+}
+
+// shebangLanguage returns the language named by a "#!" line, such as
+// "#!/bin/sh" or "#!/usr/bin/env python3".
+func shebangLanguage(head []byte) (language, bool) {
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return language{}, false
+	}
+	line := head[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return language{}, false
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		// Skip env's own options, as in "#!/usr/bin/env -S node".
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interp = f
+				break
+			}
+		}
+	}
+	// python3.12 -> python
+	interp = strings.TrimRight(interp, "0123456789.")
+	l, ok := interpreterLanguages[interp]
+	return l, ok
+}
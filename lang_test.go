@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		path, head string
+		want       language
+	}{
+		{"main.go", "", langGo},
+		{"MAIN.GO", "", langGo},
+		{"src/app.tsx", "", langTypeScript},
+		{"fix.diff", "", langDiff},
+		{"0001-fix.patch", "", langDiff},
+		{"Makefile", "", langMake},
+		{"build/Dockerfile", "", langDockerfile},
+		{"run", "#!/usr/bin/env python3\nprint(1)\n", langPython},
+		{"run", "#!/bin/bash -e\n", langShell},
+		{"serve", "#!/usr/bin/node\n", langJavaScript},
+		{"noext", "package main\n\nfunc main() {}\n", langGo},
+		{"noext", "<?xml version=\"1.0\"?>\n<a/>\n", langXML},
+		{"noext", "\n<!DOCTYPE html>\n", langHTML},
+		{"noext", "just some words\n", langOther},
+		// The extension wins over what the file holds.
+		{"notes.txt", "#!/bin/sh\n", langText},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.path, []byte(tt.head)); got != tt.want {
+			t.Errorf("detectLanguage(%q, %q) = %s, want %s", tt.path, tt.head, got.name, tt.want.name)
+		}
+	}
+}
+
+func TestAddExtLanguages(t *testing.T) {
+	saved := make(map[string]language, len(extLanguages))
+	for ext, l := range extLanguages {
+		saved[ext] = l
+	}
+	defer func() { extLanguages = saved }()
+
+	if err := addExtLanguages(".tmpl=go, jenkinsfile=groovy, .H=cpp", false); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want language
+	}{
+		{"page.tmpl", langGo},
+		{"ci.jenkinsfile", language{"groovy", "groovy", ""}},
+		{"vec.h", langCPP},
+		{"main.go", langGo},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.path, nil); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	for _, list := range []string{"", "tmpl", ".=go", ".tmpl="} {
+		if err := addExtLanguages(list, false); err == nil {
+			t.Errorf("addExtLanguages(%q) succeeded, want an error", list)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// langTotals is what -lang-stats adds up for one language.
+type langTotals struct {
+	name  string
+	bytes int64
+	files int
+}
+
+// langStats writes a table to path (or stdout if it is empty) of the bytes
+// and files that files selects in each language, as -md and -group-by-ext
+// would classify them, largest first, in place of a dump. Binary files are
+// left out, as a dump would leave them out.
+func (r *runner) langStats(files *pathList, path string) error {
+	totals := make(map[string]*langTotals)
+	walkFiles(files, r.opts, func(e fileEntry) error {
+		if e.err != nil {
+			return e.err
+		}
+		if e.link != "" || e.path == "-" || e.inline != nil {
+			return nil
+		}
+		size, sample, err := r.sizeAndSample(e.path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.path, err)
+			return nil
+		}
+		if r.binary(e.path, sample) {
+			return nil
+		}
+		name := detectLanguage(e.path, sample).name
+		t := totals[name]
+		if t == nil {
+			t = &langTotals{name: name}
+			totals[name] = t
+		}
+		t.bytes += size
+		t.files++
+		return nil
+	})
+
+	sorted := make([]*langTotals, 0, len(totals))
+	var sum langTotals
+	for _, t := range totals {
+		sorted = append(sorted, t)
+		sum.bytes += t.bytes
+		sum.files += t.files
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].bytes != sorted[j].bytes {
+			return sorted[i].bytes > sorted[j].bytes
+		}
+		return sorted[i].name < sorted[j].name
+	})
+
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Bytes\tPercent\tFiles\t\tLanguage")
+	for _, t := range sorted {
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%d\t\t%s\n", sizeCell(t.bytes, r.opts.human), percent(t.bytes, sum.bytes), t.files, t.name)
+	}
+	fmt.Fprintf(tw, "%s\t%.1f%%\t%d\t\t%s\n", sizeCell(sum.bytes, r.opts.human), percent(sum.bytes, sum.bytes), sum.files, "Total")
+	err := tw.Flush()
+	if path != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// sizeAndSample returns the size of the file at path and its first
+// -sample-size bytes.
+func (r *runner) sizeAndSample(path string) (int64, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+	sample, err := readSample(f, r.opts.sampleSize)
+	return info.Size(), sample, err
+}
+
+// percent returns part as a percentage of whole, or 0 if whole is 0.
+func percent(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(whole)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// licenseWords are the phrases, in lower case, that mark a comment block as
+// a license header for -strip-license.
+var licenseWords = []string{"copyright", "license", "licence", "spdx-license-identifier", "all rights reserved", "permission is hereby granted"}
+
+// licenseTotals add up what -strip-license removed.
+type licenseTotals struct {
+	files         int
+	bytes, tokens int64
+}
+
+// leadingLicense returns the span of data taken up by a license header: the
+// first comment block, after any #! line and blank lines, if it reads like
+// a license, and the blank lines that follow it. It returns an empty span
+// if there is no such block.
+func leadingLicense(data []byte) (start, end int) {
+	pos := 0
+	if bytes.HasPrefix(data, []byte("#!")) {
+		pos = lineEnd(data, 0)
+	}
+	for pos < len(data) && len(bytes.TrimSpace(data[pos:lineEnd(data, pos)])) == 0 {
+		pos = lineEnd(data, pos)
+	}
+	start, end = pos, pos
+	first := bytes.TrimLeft(data[pos:lineEnd(data, pos)], " \t")
+	switch {
+	case bytes.HasPrefix(first, []byte("/*")):
+		end = blockEnd(data, pos, "*/")
+	case bytes.HasPrefix(first, []byte("<!--")):
+		end = blockEnd(data, pos, "-->")
+	default:
+		prefix := commentPrefix(first)
+		for end < len(data) {
+			line := bytes.TrimLeft(data[end:lineEnd(data, end)], " \t")
+			if prefix == "" || commentPrefix(line) != prefix {
+				break
+			}
+			end = lineEnd(data, end)
+		}
+	}
+	if end == start || !mentionsLicense(data[start:end]) {
+		return 0, 0
+	}
+	for end < len(data) && len(bytes.TrimSpace(data[end:lineEnd(data, end)])) == 0 {
+		end = lineEnd(data, end)
+	}
+	return start, end
+}
+
+// lineEnd returns the offset just past the line of data that starts at pos.
+func lineEnd(data []byte, pos int) int {
+	if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(data)
+}
+
+// blockEnd returns the offset just past the line on which the block comment
+// starting at pos is closed by close, or pos if it is never closed.
+func blockEnd(data []byte, pos int, close string) int {
+	i := bytes.Index(data[pos:], []byte(close))
+	if i < 0 {
+		return pos
+	}
+	return lineEnd(data, pos+i)
+}
+
+// commentPrefix returns the line comment marker that line starts with, or ""
+// if it isn't a plain comment. Compiler directives such as //go:build and
+// preprocessor lines such as #include don't count.
+func commentPrefix(line []byte) string {
+	for _, p := range []string{"//", "#", "--", ";"} {
+		if !bytes.HasPrefix(line, []byte(p)) {
+			continue
+		}
+		rest := line[len(p):]
+		if len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\n' && rest[0] != '\r' && rest[0] != p[0] {
+			return "" // //go:build, #include, ...
+		}
+		if bytes.HasPrefix(rest, []byte(" +build")) {
+			return ""
+		}
+		return p
+	}
+	return ""
+}
+
+// mentionsLicense reports whether a comment block reads like a license.
+func mentionsLicense(block []byte) bool {
+	lower := bytes.ToLower(block)
+	for _, w := range licenseWords {
+		if bytes.Contains(lower, []byte(w)) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripLicense returns data, the contents of a file, without its license
+// header, and adds what was removed to the totals reported at the end.
+func (r *runner) stripLicense(data []byte) []byte {
+	start, end := leadingLicense(data)
+	if end == start {
+		return data
+	}
+	r.licenses.files++
+	r.licenses.bytes += int64(end - start)
+	r.licenses.tokens += int64(r.tok.count(data[start:end]))
+	return append(data[:start:start], data[end:]...)
+}
+
+// reportLicenses says on stderr how much -strip-license saved.
+func (r *runner) reportLicenses() {
+	l := r.licenses
+	fmt.Fprintf(os.Stderr, "Stripped license headers from %d files: %s, about %d tokens\n", l.files, r.size(l.bytes), l.tokens)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A lineRange is the part of a file asked for by an argument such as
+// main.go:40-60 or main.go:@50, counting lines from 1. The zero lineRange
+// is the whole file.
+type lineRange struct {
+	start, end int
+	center     int // for path:@LINE; 0 for path:START-END
+}
+
+// lineRanges holds the lineRanges of the arguments that have one, by path,
+// in the order they were given.
+type lineRanges map[string][]lineRange
+
+var lineRangeSuffix = regexp.MustCompile(`:(\d+-\d+|@\d+)$`)
+
+// parseLineRange splits arg into a file and the lines of it to print, if
+// it ends in :START-END or :@LINE and isn't itself the name of a file.
+func parseLineRange(arg string) (string, lineRange, bool, error) {
+	m := lineRangeSuffix.FindStringSubmatchIndex(arg)
+	if m == nil {
+		return arg, lineRange{}, false, nil
+	}
+	if _, err := os.Lstat(arg); err == nil {
+		return arg, lineRange{}, false, nil
+	}
+	path := arg[:m[0]]
+	lr, err := parseLines(arg[m[2]:m[3]])
+	if err != nil {
+		return "", lineRange{}, false, fmt.Errorf("%s: %v", arg, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", lineRange{}, false, err
+	}
+	if info.IsDir() {
+		return "", lineRange{}, false, fmt.Errorf("%s: a line range needs a file, not a directory", arg)
+	}
+	return path, lr, true, nil
+}
+
+// parseLines parses a line range written as START-END or @LINE.
+func parseLines(s string) (lineRange, error) {
+	if line, ok := strings.CutPrefix(s, "@"); ok {
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 1 {
+			return lineRange{}, fmt.Errorf("want @LINE with LINE >= 1")
+		}
+		return lineRange{center: n}, nil
+	}
+	from, to, _ := strings.Cut(s, "-")
+	start, err1 := strconv.Atoi(from)
+	end, err2 := strconv.Atoi(to)
+	if err1 != nil || err2 != nil || start < 1 || end < start {
+		return lineRange{}, fmt.Errorf("want START-END with 1 <= START <= END")
+	}
+	return lineRange{start: start, end: end}, nil
+}
+
+// span returns the lines of a file of n lines that lr stands for, from 0,
+// with -preview-lines lines centered on the line of a path:@LINE. Ranges
+// that run past the file are clamped to it.
+func (lr lineRange) span(n, preview int) lineSpan {
+	if lr.center == 0 {
+		return lineSpan{min(lr.start-1, n), min(lr.end, n)}
+	}
+	center := min(lr.center, n) - 1
+	start := max(0, min(center-(preview-1)/2, n-preview))
+	return lineSpan{start, min(n, start+preview)}
+}
+
+// cutLines returns the lines of data, the contents of name, that its next
+// line range asks for, and notes the range shown. A file named more than
+// once with a range goes through its ranges in turn.
+func (r *runner) cutLines(name string, data []byte) []byte {
+	ranges := r.opts.ranges[name]
+	lr := ranges[r.rangesUsed[name]%len(ranges)]
+	r.rangesUsed[name]++
+	if lr == (lineRange{}) {
+		return data
+	}
+	lines := splitLines(data)
+	w := lr.span(len(lines), r.opts.previewLines)
+	if w.start >= w.end {
+		r.addNote(fmt.Sprintf("no lines in range; %d in file", len(lines)))
+		return nil
+	}
+	var b bytes.Buffer
+	for _, l := range lines[w.start:w.end] {
+		b.WriteString(l)
+	}
+	if b := r.opts.blameLines; b != nil {
+		r.opts.blameLines = b[min(w.start, len(b)):min(w.end, len(b))]
+	}
+	r.addNote(fmt.Sprintf("lines %s of %d", hunkLines(w), len(lines)))
+	return b.Bytes()
+}
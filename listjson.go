@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// A listEntry describes one selected file in the -list-json output.
+type listEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Ext    string `json:"ext"`
+	Binary bool   `json:"binary"`
+	SHA256 string `json:"sha256,omitempty"` // with -json-hash; see emittedHash
+}
+
+// listJSON writes the files that files selects, after every filter, as a JSON
+// array to path (or stdout if it is empty), without printing any contents.
+// Binary files are listed, with binary set, though a dump would skip them;
+// so are files over -max-size. Symlinks that are not followed and special
+// files are left out, since a dump never prints them.
+func (r *runner) listJSON(files *pathList, path string) error {
+	list := []listEntry{}
+	visit := func(e fileEntry) error {
+		if e.err != nil {
+			return e.err
+		}
+		if e.link != "" {
+			return nil
+		}
+		entry, ok, err := r.describe(e.path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.path, err)
+			return nil
+		}
+		if ok {
+			list = append(list, entry)
+		}
+		return nil
+	}
+	walkFiles(files, r.opts, visit)
+
+	out, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if path == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return os.WriteFile(path, out, 0o666)
+}
+
+// describe returns the -list-json entry for path, reading its start to see
+// whether it is binary, and with -grep only as much more as it takes to find
+// a match. It reports false for files a dump would skip
+// regardless of their contents.
+func (r *runner) describe(path string) (listEntry, bool, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return listEntry{}, false, err
+		}
+		name := r.opts.stdinName
+		return listEntry{
+			Path:   name,
+			Size:   int64(len(data)),
+			Ext:    filepath.Ext(name),
+			Binary: r.binary(name, r.head(data)),
+		}, true, nil
+	}
+
+	// Stat before opening: opening a named pipe would block.
+	info, err := os.Stat(path)
+	if err != nil {
+		return listEntry{}, false, err
+	}
+	if !info.Mode().IsRegular() {
+		return listEntry{}, false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return listEntry{}, false, err
+	}
+	defer f.Close()
+	sample, err := readSample(f, r.opts.sampleSize)
+	if err != nil {
+		return listEntry{}, false, err
+	}
+	if r.opts.mime != "" && !mimeMatches(sniffMIME(sample), r.opts.mime) {
+		return listEntry{}, false, nil
+	}
+	if r.opts.langFilter != nil && !r.wantLanguage(path, detectLanguage(path, sample)) {
+		return listEntry{}, false, nil
+	}
+	if r.opts.grep != nil {
+		ok, err := readerMatches(io.MultiReader(bytes.NewReader(sample), f), r.opts.grep)
+		if err != nil || !ok {
+			return listEntry{}, false, err
+		}
+	}
+	if r.opts.skipMatching != nil {
+		ok, err := fileMatches(path, r.opts.skipMatching)
+		if err != nil || ok {
+			return listEntry{}, false, err
+		}
+	}
+	entry := listEntry{
+		Path:   path,
+		Size:   info.Size(),
+		Ext:    filepath.Ext(path),
+		Binary: r.binary(path, sample),
+	}
+	if r.opts.jsonHash {
+		if entry.SHA256, err = r.emittedHash(path, entry.Binary); err != nil {
+			return listEntry{}, false, err
+		}
+	}
+	return entry, true, nil
+}
+
+// emittedHash returns the SHA-256, in hex, of the exact bytes a dump would
+// print as the contents of path, after every transform, or "" if it would
+// print none, as for a file left out by -dedupe-content. A binary file is
+// hashed as it is, as -zip stores it, unless -base64-binary is set.
+func (r *runner) emittedHash(path string, binary bool) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if binary && !r.opts.base64Binary {
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	out := r.out
+	r.out, r.capture, r.captured = io.Discard, h, false
+	defer func() {
+		r.out, r.capture, r.note = out, nil, ""
+	}()
+	if _, err := r.printContents(path, f, 0); err != nil {
+		return "", err
+	}
+	if !r.captured {
+		return "", nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import "bytes"
+
+// binaryMagic are signatures at the start of binary formats that
+// http.DetectContentType doesn't know, or that the printable-character
+// ratio can miss in a short sample.
+var binaryMagic = [][]byte{
+	[]byte("\x7fELF"),             // ELF executables and libraries
+	[]byte("\xfe\xed\xfa\xce"),    // Mach-O, 32-bit
+	[]byte("\xfe\xed\xfa\xcf"),    // Mach-O, 64-bit
+	[]byte("\xce\xfa\xed\xfe"),    // Mach-O, 32-bit little-endian
+	[]byte("\xcf\xfa\xed\xfe"),    // Mach-O, 64-bit little-endian
+	[]byte("\xca\xfe\xba\xbe"),    // Java classes and universal Mach-O
+	[]byte("MZ"),                  // Windows executables
+	[]byte("\x89PNG\r\n\x1a\n"),   // PNG
+	[]byte("%PDF-"),               // PDF
+	[]byte("PK\x03\x04"),          // zip, jar, docx
+	[]byte("\x1f\x8b"),            // gzip
+	[]byte("BZh"),                 // bzip2
+	[]byte("\xfd7zXZ\x00"),        // xz
+	[]byte("\x28\xb5\x2f\xfd"),    // zstd
+	[]byte("7z\xbc\xaf\x27\x1c"),  // 7-Zip
+	[]byte("SQLite format 3\x00"), // SQLite databases
+	[]byte("\x00asm"),             // WebAssembly
+}
+
+// textMagic are signatures at the start of text files whose contents can
+// look binary to the ratio heuristic, such as UTF-8 text mostly in a
+// non-Latin script after a byte order mark.
+var textMagic = [][]byte{
+	[]byte("\xef\xbb\xbf"), // UTF-8 byte order mark
+	[]byte("#!"),           // scripts
+	[]byte("<?xml"),
+	[]byte("%!PS"), // PostScript
+	[]byte("{\\rtf"),
+}
+
+// magicBinary reports whether sample starts with a signature that settles
+// whether it is binary, and if so which. A text signature only counts if
+// sample has no NUL bytes, which text never holds; otherwise a
+// self-extracting archive would pass as a shell script.
+func magicBinary(sample []byte) (binary, known bool) {
+	for _, magic := range binaryMagic {
+		if bytes.HasPrefix(sample, magic) {
+			// "MZ" and "BZh" are also how some text starts.
+			if len(magic) < 4 && bytes.IndexByte(sample, 0) < 0 {
+				continue
+			}
+			return true, true
+		}
+	}
+	for _, magic := range textMagic {
+		if bytes.HasPrefix(sample, magic) && bytes.IndexByte(sample, 0) < 0 {
+			return false, true
+		}
+	}
+	return false, false
+}
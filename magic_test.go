@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMagicBinary(t *testing.T) {
+	tests := []struct {
+		name          string
+		sample        string
+		binary, known bool
+	}{
+		{"ELF", "\x7fELF\x02\x01\x01\x00", true, true},
+		{"Mach-O 32", "\xfe\xed\xfa\xce\x00\x00", true, true},
+		{"Mach-O 64", "\xfe\xed\xfa\xcf\x00\x00", true, true},
+		{"Mach-O 32 LE", "\xce\xfa\xed\xfe\x00\x00", true, true},
+		{"Mach-O 64 LE", "\xcf\xfa\xed\xfe\x00\x00", true, true},
+		{"Java class", "\xca\xfe\xba\xbe\x00\x00\x00\x34", true, true},
+		{"Windows exe", "MZ\x90\x00\x03\x00", true, true},
+		{"PNG", "\x89PNG\r\n\x1a\n\x00\x00", true, true},
+		{"PDF", "%PDF-1.7\n", true, true},
+		{"zip", "PK\x03\x04\x14\x00", true, true},
+		{"gzip", "\x1f\x8b\x08\x00", true, true},
+		{"bzip2", "BZh91AY&SY\x00", true, true},
+		{"xz", "\xfd7zXZ\x00\x00", true, true},
+		{"zstd", "\x28\xb5\x2f\xfd\x00", true, true},
+		{"7-Zip", "7z\xbc\xaf\x27\x1c\x00", true, true},
+		{"SQLite", "SQLite format 3\x00\x10\x00", true, true},
+		{"WebAssembly", "\x00asm\x01\x00\x00\x00", true, true},
+		{"BOM", "\xef\xbb\xbfhello", false, true},
+		{"script", "#!/bin/sh\necho hi\n", false, true},
+		{"XML", "<?xml version=\"1.0\"?>", false, true},
+		{"PostScript", "%!PS-Adobe-3.0\n", false, true},
+		{"RTF", "{\\rtf1\\ansi", false, true},
+		// Short binary signatures that are also how some text starts.
+		{"MZ text", "MZ is a postal code prefix\n", false, false},
+		{"BZh text", "BZh. is not a word\n", false, false},
+		// A text signature doesn't count with a NUL after it, as in a
+		// self-extracting archive.
+		{"script with NUL", "#!/bin/sh\nexit\n\x00\x01PK", false, false},
+		{"plain", "package main\n", false, false},
+	}
+	for _, tt := range tests {
+		binary, known := magicBinary([]byte(tt.sample))
+		if binary != tt.binary || known != tt.known {
+			t.Errorf("%s: magicBinary = %v, %v; want %v, %v", tt.name, binary, known, tt.binary, tt.known)
+		}
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   bool
+	}{
+		{"empty", "", false},
+		{"text", "hello, world\n", false},
+		{"PDF", "%PDF-1.4\n" + strings.Repeat("plain text\n", 100), true},
+		// Mostly non-Latin UTF-8 after a BOM, which the ratio alone
+		// would call binary.
+		{"BOM", "\xef\xbb\xbf" + strings.Repeat("жук", 100), false},
+		{"controls", strings.Repeat("\x01\x02\x03a", 100), true},
+	}
+	for _, tt := range tests {
+		if got := isBinary([]byte(tt.sample)); got != tt.want {
+			t.Errorf("%s: isBinary = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,128 +1,3238 @@
 package main
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/xml"
 	"flag"
 	"fmt"
+	"html"
 	"io"
+	"math"
+	"math/rand"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 const defaultMaxSize = 10 << 20 // 10 MiB
 
+// mmapThreshold is the smallest file that -mmap maps into memory; below it,
+// the setup cost outweighs the savings over a plain read.
+const mmapThreshold = 1 << 20 // 1 MiB
+
+// options holds the settings that control which files are selected and how
+// they are printed.
+type options struct {
+	recurse        bool
+	depth          int  // files at most this many levels below each argument; 0 for no limit
+	all            bool // walk into hidden files and directories
+	extension      string
+	caseExt        bool            // match -ext with its case, so .H isn't .h
+	pathRegex      *regexp.Regexp  // select only files whose cleaned, slash-separated path matches
+	newerThan      time.Time       // select only files modified after this; zero for any time
+	newerCommit    time.Time       // print only files last committed after this; zero for any time
+	newerCommitAge string          // the -newer-commit duration, for reporting
+	trackedOnly    bool            // print only files git tracks
+	entropyMin     float64         // entropy per character that makes -fail-on-secret flag a string; 0 for none
+	secretMinLen   int             // the shortest string entropyMin applies to
+	recent         string          // the -recent duration, to report how many files it matched
+	grep           *regexp.Regexp  // select only files whose contents match
+	skipMatching   *regexp.Regexp  // leave out files whose contents match
+	notebooks      bool            // print Jupyter notebooks as their code cells
+	nbMarkdown     bool            // and their markdown cells
+	nbOutput       bool            // and the text of their outputs
+	pdfText        bool            // print PDFs as the text pdftotext extracts from them
+	grepContext    int             // with grep, print only this many lines around each match (-1 = whole file)
+	firstMatch     bool            // with grepContext, print only the first match
+	exclude        []string        // glob patterns for files and directories to leave out
+	include        []string        // if set, glob patterns one of which a file must match (from -lang and -include)
+	junkDirs       map[string]bool // names of directories to prune, for -skip-common-junk
+	skipVendored   bool            // prune directories that look like third-party code
+	skipSubmodules bool            // prune the work trees of git submodules
+	submoduleNotes bool            // note in each header which submodule the file is in, if any
+	excludeTests   bool            // leave out test files
+	onlyTests      bool            // select only test files
+	namesOnly      bool
+	quiet          bool     // don't report skipped files on stderr
+	mergeNotices   bool     // report skipped files in the output instead of on stderr
+	errorMarkers   bool     // print a marker for each file that can't be read
+	dedupe         bool     // print files identical to an earlier one as a marker
+	verbose        bool     // list the paths in the -report-unreadable summary, and what -skip-vendored prunes
+	skipErrors     bool     // keep walking past files and directories that can't be read
+	reportSkips    bool     // summarize skipped files by reason at the end
+	skipReport     bool     // make that summary one "reason<TAB>path" line per file
+	summarizeSkips bool     // print one count of skipped files at the end in place of a line each
+	binaryMarker   bool     // with namesOnly, mark binary files
+	showMIME       bool     // with namesOnly, show each file's sniffed media type
+	showMode       bool     // note each file's permissions, and whether it is executable, in its header
+	minifyJSON     bool     // print .json files without insignificant whitespace
+	prettyJSON     bool     // print .json files indented by two spaces
+	stripLicense   bool     // drop a license comment block at the start of each file
+	nfc            bool     // put contents in Unicode normalization form C
+	trimBlankEnds  bool     // drop blank lines at the end of each file
+	langFilter     []string // only files detected as one of these languages; see language.is
+	mime           string   // comma-separated glob patterns for the media types to select
+	lenientBinary  bool     // treat valid UTF-8 without NUL bytes as text
+	skipBinaryChk  bool     // treat every file as text, without examining it
+	binaryCheckExt []string // if set, examine only files with these lowercased extensions for binary data
+	sampleSize     int      // bytes at the start of each file to examine for binary data; 0 for all
+	jsonHash       bool     // give a sha256 of each file's printed contents in -list-json
+	skipMinified   bool     // leave out minified JavaScript, CSS and HTML
+	outputEncoding string   // charset to write the dump in; see encoding.go
+	replaceUnmap   bool     // write ? for characters outputEncoding lacks, instead of failing
+	base64Binary   bool     // print binary files base64-encoded instead of skipping them
+	imagePlaces    bool     // print a line describing each image instead of skipping it
+	maxSize        int64
+	totalMax       int64              // content bytes allowed across all files
+	model          string             // target model, whose context window is checked at the end
+	dropOver       float64            // skip files bigger than this fraction of totalMax
+	maxTokens      int64              // tokens allowed across all files, as counted by the tokenizer
+	maxTotalLines  int64              // lines allowed across all files (0 = unlimited)
+	maxFileTokens  int                // tokens to print from each file (0 = all)
+	countTokens    bool               // report the number of tokens printed
+	countHeader    bool               // start the dump with a line giving the files, lines and tokens in it
+	treeJSON       bool               // start the dump with a JSON tree of the files in it
+	treeEmptyDirs  bool               // list the directories walked in the -tree-json tree even with no files printed
+	numbered       bool               // number each file's header, as [3/17], and separate the blocks
+	smartHeader    bool               // leave out the header if only one file is printed
+	headerTokens   bool               // note each file's token count in its header
+	warnTokens     int                // warn about each file of more than this many tokens (0 = never)
+	stats          bool               // report totals by extension at the end
+	human          bool               // show sizes in messages and tables in IEC units, as 3.2 KiB
+	statFormat     *template.Template // use this for the -stats report instead of a table
+	statsStdout    bool               // write the -stats report to stdout, not stderr
+	countDepth     int                // directory levels -count-by-dir breaks totals down to
+	perDirMax      int64              // cumulative content bytes allowed per directory when recursing
+	maxDirFiles    int                // files printed per directory when recursing; 0 for no limit
+	maxPerExt      int                // files printed per extension; 0 for no limit
+	extMax         map[string]int64   // content bytes allowed across the files with each lower-case extension
+	splitOutput    int64              // roll the -o file into numbered parts of this many bytes; 0 for one file
+	groupByExt     bool               // print files in sections by file type
+	docsFirst      bool               // print top-level READMEs, licenses and Markdown before other files
+	readmeFirst    bool               // print each directory's READMEs before the rest of it
+	dirContext     bool               // print the start of each directory's README before its first file
+	dirContextMax  int                // lines of it to print
+	order          []string           // glob patterns for files to print first, in this order
+	allowFIFO      bool               // read named pipes instead of skipping them
+	ignoreSymlinks bool               // skip symlinks entirely, even when named as arguments
+	derefFiles     bool               // print the target of a symlink to a file found while recursing
+	symlinkLoops   bool               // report symlinks found while recursing that point back into the walk
+	requireUTF8    bool               // skip files that are not valid UTF-8
+	markdown       bool               // print contents as Markdown code blocks
+	xml            bool               // print contents in <file path="..."> elements
+	frontMatter    bool               // start each file with a YAML header between --- lines
+	mmap           bool               // memory-map large regular files instead of reading them
+	bufferSize     int                // bytes to copy at a time (0 = io.Copy's choice)
+	retry          int                // times to retry a failed read that may be transient
+	stdinName      string             // header name for contents read from -
+	posixPaths     bool               // show paths with forward slashes on every OS
+	gitRootPaths   bool               // show paths relative to the top of their git work tree
+	ioWorkers      int                // files read at once; see pipeline.go
+	fastWalk       int                // directories read at once while walking; 0 to use filepath.Walk
+	statPrefetch   int                // files stat'ed at once ahead of processing; 0 for one at a time
+	cpuWorkers     int                // files filtered and tokenized at once
+	exec           []string           // command and arguments to run per file; {} is replaced by the path
+	outputDir      string             // write each file under this directory instead of printing it
+	zipPath        string             // write the selected files into this zip archive instead of printing them
+	chatJSON       bool               // write the dump as a chat API messages array
+	chatMax        int64              // with chatJSON, start a new user message after this many bytes; 0 for one
+	chatSystem     string             // with chatJSON, the system message to start with
+	indexPath      string             // write where each file's block is in the output to this file
+	chunk          bool               // print files over maxSize in parts instead of skipping them
+	mergeSmall     int                // print runs of files smaller than this many bytes in one block
+	gitStatus      bool               // mark changed files in their headers with their git status
+	blame          bool               // start each line with its author and commit, from git blame
+	blameMax       int                // files to blame at most
+	blameLines     []string           // the -blame prefixes of the lines of the file being printed
+	diffAgainst    string             // print only files that differ from their counterparts under this directory
+	diff           bool               // with diffAgainst, print differing files as unified diffs
+	changedSince   string             // print only the changed parts of files that differ from this git ref
+	changedContext int                // with changedSince, lines to show around each change
+	sample         int                // print only this many files, chosen at random (0 = all)
+	sizePercentile float64            // skip files larger than this percentile of the selected files' sizes (0 = none)
+	ranges         lineRanges         // lines to print of the files named as path:START-END or path:@LINE
+	previewLines   int                // lines to print around the line of a path:@LINE
+	lang           string             // language for code fences and front matter; "" to detect it
+	seed           int64              // seed for choosing the -sample
+
+	// Content filters; see buildFilters.
+	showWhitespace bool           // render tabs, trailing spaces and line endings visibly
+	lineTemplate   string         // format for each printed line, with {path}, {line} and {text}
+	stripImports   bool           // replace Go, Python and JavaScript imports with a count
+	compactImports bool           // merge runs of top-level Python imports
+	dedupBlocks    int            // collapse blocks of at least this many lines repeated back to back (0 = off)
+	reindent       int            // spaces per indentation level (0 = leave as is)
+	commentOut     bool           // prefix each line with the language's line comment marker
+	editorconfig   bool           // apply each file's .editorconfig whitespace settings
+	transforms     []string       // if set, the content transforms to run, in order (from -transforms)
+	htmlEscape     bool           // escape contents and headers for embedding in HTML
+	escapePrefix   string         // prefix for content lines that look like file delimiters; "" to leave them
+	maxLines       int            // lines to print from each file (0 = all)
+	highlight      *regexp.Regexp // wrap matches in reverse video; nil unless printing to a terminal
+	wrap           int            // break lines longer than this many columns; 0 for no limit
+}
+
+// A fileEntry is a file selected for output.
+type fileEntry struct {
+	path   string
+	size   int64
+	walked bool         // found while recursing into a directory argument
+	top    bool         // an argument, or directly inside a directory argument
+	err    error        // why path couldn't be walked or processed
+	link   string       // target, if this is a symlink that is not followed
+	inline []byte       // -mixed-stdin text to print under path, which is made up
+	input  *inputObject // the -ndjson-input object this came from, if any
+	info   os.FileInfo  // what stat said about path when it was found, if known
+}
+
+// A runner prints selected files and keeps the state that spans them.
+type runner struct {
+	opts      *options
+	out       io.Writer // stdout, or the -o file
+	tok       tokenizer
+	tokens    int64             // tokens printed so far, if counted
+	incr      *incrementalCache // nil unless -incremental is set
+	total     int64             // content bytes printed so far, for -total-max
+	dirBytes  map[string]int64  // content bytes printed per directory, for -per-dir-max
+	dirFiles  map[string]int    // files printed and skipped by -max-files-per-dir, by directory
+	dirSkips  map[string]int
+	extFiles  map[string]int // files printed and skipped by -max-per-ext, by lowercased extension
+	extSkips  map[string]int
+	extBytes  map[string]int64             // content bytes printed by lowercased extension, for -max-bytes-ext
+	lineSkips int                          // files skipped once -max-total-lines was reached
+	execFails int                          // files the -exec command failed for
+	seen      map[[sha256.Size]byte]string // first file printed with each content, for -dedupe-content
+
+	before, after string // -prepend and -append text, or the -prompt-template around {files}
+	template      bool   // before and after come from -prompt-template
+	part          string // " (part i/n)" while -chunk prints a file in parts
+	note          string // a note on the file being printed, for its header
+	stats         *dumpStats
+	queue         chan *prepared    // files the pipeline is preparing, in print order
+	pre           *prepared         // the pipeline's work on the file being printed
+	events        *eventLog         // nil unless -events is set
+	files         int               // files printed, for -events
+	skips         []skipRecord      // files skipped, for -events and -report-unreadable
+	gitStatus     map[string]string // -git-status codes by absolute path
+	gitRoots      map[string]string // -git-root-paths work tree tops by directory; "" if outside one
+	changes       *changeSet        // nil unless -changed-since is set
+	counter       *countingWriter   // counts what is printed, if -index is set
+	index         []indexEntry      // where each block printed so far is, for -index
+	licenses      licenseTotals     // what -strip-license removed
+	small         []smallFile       // -merge-small files waiting to be printed together
+	zip           *zip.Writer       // the -zip archive being written, if any
+	chat          *chatWriter       // the -chat-json messages being written, if any
+	capture       io.Writer         // if set, gets file contents in place of printBlock, for -json-hash
+	captured      bool              // whether anything was sent to capture
+	split         *splitWriter      // the -split-output parts being written, if any
+	measuring     bool              // dumping only to measure for -fail-over-budget, so limits cut nothing off
+	linkTarget    string            // what the symlink being printed points to, with -deref-files
+	numbered      int               // files given a -numbered header so far
+	numberedName  string            // the last of them
+	blocks        int               // blocks and markers printed so far, for -smart-header
+	blamed        int               // files printed with -blame so far, and any after -blame-max
+	tree          []treeFile        // files printed so far, for -tree-json
+	treeDirs      []string          // directories walked, for -tree-json-empty-dirs
+	contextDir    string            // the directory of the file being printed, with -dir-context
+	dirContexts   map[string]bool   // directories with a -dir-context block printed so far
+	rangesUsed    map[string]int    // line ranges of each file printed so far
+
+	// -newer-commit's and -tracked-only's view of each work tree, by its
+	// top, and how many files each has left out.
+	histories  map[string]*commitHistory
+	oldCommits int
+	untracked  int
+}
+
+func newRunner(opts *options) *runner {
+	return &runner{opts: opts, out: os.Stdout, tok: heuristicTokenizer{}, dirBytes: make(map[string]int64), dirFiles: make(map[string]int), dirSkips: make(map[string]int), extFiles: make(map[string]int), extSkips: make(map[string]int), extBytes: make(map[string]int64), seen: make(map[[sha256.Size]byte]string), histories: make(map[string]*commitHistory), rangesUsed: make(map[string]int)}
+}
+
+// countingTokens reports whether the tokens in each file have to be counted.
+func (r *runner) countingTokens() bool {
+	return r.opts.countTokens || r.opts.maxTokens > 0 || r.opts.maxFileTokens > 0 || r.opts.countHeader
+}
+
+// tokenTotal returns the number of tokens printed so far, estimating it from
+// the byte count if the tokens weren't counted.
+func (r *runner) tokenTotal() int64 {
+	if r.countingTokens() {
+		return r.tokens
+	}
+	return estimateTokens(r.total)
+}
+
 func main() {
+	var exclude stringList
+	flag.Var(&exclude, "exclude", "Skip files and directories matching this glob `pattern` (may be repeated)")
+	var include stringList
+	flag.Var(&include, "include", "Only process files matching this glob `pattern`, where ** matches any number of directories (may be repeated)")
+	var junkDirs stringList
+	flag.Var(&junkDirs, "junk-dir", "Also prune directories with this `name` when recursing (may be repeated)")
 	var (
-		recurse   = flag.Bool("r", false, "Recursively process directories")
-		extension = flag.String("ext", "", "Only process files with this extension (e.g., .go, .txt)")
-		namesOnly = flag.Bool("n", false, "Only print file names, not their contents")
-		maxSize   = flag.Int64("max-size", defaultMaxSize, "Maximum number of bytes to output (0 = unlimited)")
-		help      = flag.Bool("h", false, "Show help")
+		recurse      = flag.Bool("r", false, "Recursively process directories")
+		depth        = flag.Int("depth", 0, "With -r, select files at most `N` levels below each argument, 1 being only those directly in it (0 = no limit); src@N recurses src to N levels")
+		all          = flag.Bool("a", false, "When recursing, include hidden files and directories (names starting with .)")
+		extension    = flag.String("ext", "", "Only process files with this extension (e.g., .go, .txt)")
+		caseExt      = flag.Bool("case-sensitive-ext", false, "Match -ext exactly, so .H and .h are different extensions")
+		lang         = flag.String("lang", "", "Only process files for these comma-separated `presets`: go, web, python, rust, c (overridden by -ext)")
+		skipSubmods  = flag.Bool("skip-submodules", false, "Prune the work trees of git submodules listed in .gitmodules")
+		submodHeads  = flag.Bool("submodule-headers", false, "Note in each file's header which git submodule it is in, as --- lib/x/a.go (submodule lib/x) ---")
+		skipVendor   = flag.Bool("skip-vendored", false, "Prune directories that look like third-party code: node_modules, site-packages, Go and Composer vendor/, big trees with their own license")
+		skipJunk     = flag.Bool("skip-common-junk", false, "Prune dependency, build and cache directories (node_modules, target, dist, __pycache__, ...)")
+		excludeFrom  = flag.String("exclude-from", "", "Also skip files and directories matching the .gitignore-style patterns listed in `file`")
+		globArgs     = flag.Bool("resolve-globs-recursively", false, "Expand glob arguments such as 'src/**/*_test.go' here, with ** matching any number of directories")
+		exclTests    = flag.Bool("exclude-tests", false, "Skip test files (*_test.go, test_*.py, *.spec.ts, tests/, ...)")
+		onlyTests    = flag.Bool("only-tests", false, "Print only test files, as recognized by -exclude-tests")
+		grep         = flag.String("grep", "", "Only print files whose contents match this `regexp`")
+		notebooks    = flag.Bool("render-notebooks", false, "Print Jupyter .ipynb notebooks as their code cells, in order, instead of as JSON")
+		pdfText      = flag.Bool("pdf-text", false, "Print .pdf files as their text, extracted with pdftotext, instead of skipping them as binary")
+		nbMarkdown   = flag.Bool("include-nb-markdown", false, "With -render-notebooks, print markdown cells too, as comments")
+		nbOutput     = flag.Bool("include-nb-output", false, "With -render-notebooks, print the text outputs of cells too, as comments")
+		skipMatching = flag.String("skip-matching", "", "Skip files whose contents match this `regexp`, such as '@generated|DO NOT EDIT'")
+		grepContext  = flag.Int("grep-context", 0, "With -grep, print only the matching lines and N lines around each")
+		firstMatch   = flag.Bool("first-match", false, "With -grep, print only the first match in each file (and its -grep-context)")
+		quiet        = flag.Bool("q", false, "Don't report skipped files on stderr")
+		errorMarks   = flag.Bool("error-placeholders", false, "Print files that can't be read as --- path (unreadable: permission denied) --- instead of leaving them out")
+		mergeNotes   = flag.Bool("merge-notices", false, "Report skipped files in the output, as --- path (skipped: reason) ---, instead of on stderr")
+		verbose      = flag.Bool("v", false, "List each skipped file in the -report-unreadable summary, and report the language -lang-filter detects")
+		langFilter   = flag.String("lang-filter", "", "Only process files detected (by extension, #! line or contents) as one of these comma-separated `languages`, e.g. go,python")
+		skipErrors   = flag.Bool("skip-errors", false, "Keep walking past files and directories that can't be read")
+		reportSkips  = flag.Bool("report-unreadable", false, "Summarize skipped and unreadable files by reason on stderr at the end")
+		skipReport   = flag.Bool("skip-report", false, "Write the -report-unreadable summary as one \"reason<TAB>path\" line per file")
+		summarize    = flag.Bool("summarize-skips", false, "Report skipped files with one count per reason at the end instead of a line each (still a line each under -v)")
+		namesOnly    = flag.Bool("n", false, "Only print file names, not their contents")
+		showMIME     = flag.Bool("show-mime", false, "With -n, show each file's media type as sniffed from its contents, as [text/plain]")
+		showMode     = flag.Bool("show-mode", false, "Note each file's permission bits in its header, as --- deploy.sh (0755, executable) ---")
+		minifyJSON   = flag.Bool("minify-json", false, "Print .json files compactly, without indentation (invalid JSON is printed as is)")
+		prettyJSON   = flag.Bool("pretty-json", false, "Print .json files indented by two spaces (invalid JSON is printed as is)")
+		stripLicense = flag.Bool("strip-license", false, "Drop a leading comment block that reads like a license (copyright, SPDX, ...) from each file, and report the savings")
+		trimBlank    = flag.Bool("trim-trailing-blank-lines", false, "Drop blank lines at the end of each file, so that its last line of text ends it")
+		dedupBlocks  = flag.Int("dedup-blocks", 0, "Collapse a block of at least `N` lines repeated back to back in a file into one copy and a [repeated block x K] line (0 = off)")
+		nfc          = flag.Bool("nfc", false, "Put contents in Unicode normalization form C, composing accented letters (e followed by U+0301 to é)")
+		mime         = flag.String("mime", "", "Only process files whose sniffed media type matches one of these comma-separated `patterns`, e.g. text/*")
+		binMarker    = flag.Bool("binary-marker", false, "With -n, mark binary files with [binary] (reads the start of each file)")
+		maxSize      = flag.Int64("max-size", defaultMaxSize, "Maximum number of bytes to output (0 = unlimited)")
+		totalMax     = flag.Int64("total-max", 0, "Maximum number of bytes to output across all files (0 = unlimited)")
+		model        = flag.String("model", "", "Size -total-max to this model's context window (e.g., gpt-4o, claude-3-5-sonnet)")
+		dropOver     = flag.Float64("drop-over", 0, "Skip any file larger than this fraction of -total-max (e.g., 0.5)")
+		maxFileToks  = flag.Int("max-tokens-per-file", 0, "Truncate each file after N tokens, noting how many were dropped (0 = unlimited)")
+		maxTokens    = flag.Int64("max-total-tokens", 0, "Maximum number of tokens to output across all files (0 = unlimited)")
+		maxTotalLns  = flag.Int64("max-total-lines", 0, "Maximum number of lines to output across all files, cutting off the file that reaches it (0 = unlimited)")
+		human        = flag.Bool("human", false, "Show sizes in messages, -stats and size tables in IEC units (3.2 KiB, 10.0 MiB) instead of bytes")
+		stats        = flag.Bool("stats", false, "Report file, line, byte and token totals by extension on stderr")
+		statFormat   = flag.String("stat-format", "", "Write the -stats report with this Go `template` (fields .FileCount, .TotalBytes, .TotalLines, .TotalTokens, .CodeLines, .CommentLines, .BlankLines, .ByExt)")
+		statsStdout  = flag.Bool("stats-stdout", false, "Write the -stats report to stdout instead of stderr")
+		countToks    = flag.Bool("count-tokens", false, "Report the number of tokens printed on stderr")
+		numbered     = flag.Bool("numbered", false, "Start each file's header with its number and the total, as in [3/17] path, and put a ==== line between files")
+		smartHeader  = flag.Bool("smart-header", false, "Leave out the --- path --- header when only one file is printed (holds the output until done)")
+		treeJSON     = flag.Bool("tree-json", false, "Start the dump with a JSON object of the directory tree of the files printed, with their sizes (holds the output until done)")
+		treeEmpty    = flag.Bool("tree-json-empty-dirs", false, "With -tree-json, also list the directories walked that no printed file is in, as empty objects")
+		countHeader  = flag.Bool("count-header", false, "Start the dump with a line like # 17 files, 4,203 lines, ~9,800 tokens (holds the output until done)")
+		headerToks   = flag.Bool("header-tokens", false, "Note each file's token count, by -tokenizer, in its header")
+		warnTokens   = flag.Int("warn-tokens", 0, "Warn on stderr about each file of more than `N` tokens, by -tokenizer, without changing the dump (0 = never)")
+		tokName      = flag.String("tokenizer", "", "Count tokens exactly with this BPE encoding (cl100k or o200k) instead of estimating")
+		noTokCache   = flag.Bool("no-token-cache", false, "Count every file's tokens with -tokenizer, instead of reusing counts of unchanged files from earlier runs")
+		splitOutput  = flag.Int64("split-output", 0, "With -o, write numbered parts (out.001, out.002, ...) of at most `SIZE` bytes, split between files")
+		maxPerExt    = flag.Int("max-per-ext", 0, "Print at most `N` files with each extension, skipping the rest (0 = unlimited)")
+		maxBytesExt  = flag.String("max-bytes-ext", "", "Maximum bytes to output across the files with each extension, as `.ext=bytes,...`")
+		perDirMax    = flag.Int64("per-dir-max", 0, "Maximum number of bytes to output per directory when recursing (0 = unlimited)")
+		maxDirFiles  = flag.Int("max-files-per-dir", 0, "Print at most `N` files from each directory when recursing, skipping the rest (0 = unlimited)")
+		pathStyle    = flag.String("path-style", "posix", "Show paths with forward slashes (posix) or the OS's separators (native)")
+		nativeSeps   = flag.Bool("native-separators", false, "Show paths with the OS's separators, as -path-style native does")
+		stdinName    = flag.String("stdin-name", "<stdin>", "Name to show in the header for contents read from - (stdin)")
+		groupByExt   = flag.Bool("group-by-ext", false, "Print files in sections grouped by language")
+		extMap       = flag.String("ext-map", "", "Classify files with these extensions as these languages, as `.ext=language,...`, for -md, -group-by-ext and the rest")
+		orderFile    = flag.String("order-file", "", "Print files matching the glob patterns listed in `file`, one per line, first and in that order")
+		docsFirst    = flag.Bool("docs-first", false, "Print top-level READMEs, Markdown files and LICENSE before everything else")
+		readmeFirst  = flag.Bool("readme-first-per-dir", false, "Print each directory's README before the other files in it and below it")
+		dirContext   = flag.Bool("dir-context", false, "When recursing, print the start of each directory's README as a context block before its first file")
+		dirCtxLines  = flag.Int("dir-context-lines", 10, "Print at most `N` lines of each README with -dir-context")
+		xmlOut       = flag.Bool("xml", false, "Print each file as a <file path=\"...\"> element, for prompts that use XML tags")
+		frontMatter  = flag.Bool("front-matter", false, "Start each file with a YAML header (path, size, lines, lang) between --- lines")
+		markdown     = flag.Bool("md", false, "Print each file as a Markdown code block tagged with its language")
+		fastWalk     = flag.Int("fast-walk", 0, "When recursing, read up to `N` directories at once, for slow filesystems (0 = one at a time); the order is unchanged")
+		statPrefetch = flag.Int("concurrent-stat-prefetch", 0, "Stat up to `N` files at once ahead of processing them, for slow filesystems (0 = one at a time); the order is unchanged")
+		ioWorkers    = flag.Int("io-workers", 4, "Number of files to read at once (lower it on network filesystems)")
+		cpuWorkers   = flag.Int("cpu-workers", runtime.NumCPU(), "Number of files to filter and tokenize at once")
+		retry        = flag.Int("retry", 0, "Retry a failed read up to `N` times, with a growing pause, unless the file is missing or unreadable")
+		bufferSize   = flag.Int("buffer-size", 0, "Copy file contents `bytes` at a time (0 = let the copy choose)")
+		useMmap      = flag.Bool("mmap", false, "Memory-map large files instead of reading them (faster for big files)")
+		maxLines     = flag.Int("max-lines", 0, "Print only the first N lines of each file (0 = all)")
+		stripImports = flag.Bool("strip-imports", false, "Replace import statements in Go, Python, JavaScript and TypeScript files with a comment counting them")
+		compactImps  = flag.Bool("compact-imports", false, "Merge runs of top-level imports in Python files (import a, b; from m import a, b)")
+		editorCfg    = flag.Bool("editorconfig", false, "Normalize whitespace as each file's .editorconfig says (trailing whitespace, final newline, indentation, line endings)")
+		commentOut   = flag.Bool("comment-out", false, "Prefix each line with the file's line comment marker (// for Go, # for Python, ...; # if unknown)")
+		reindent     = flag.Int("reindent", 0, "Re-indent files in brace languages with N spaces per level (0 = leave as is)")
+		wrap         = flag.Int("wrap", 0, "Break lines longer than `N` columns (0 = never); by default, the terminal's width when printing to one")
+		highlight    = flag.String("highlight", "", "Show matches of this `regexp` in reverse video, when printing to a terminal")
+		newerThan    = flag.String("newer-than", "", "Only process files modified within this `duration`, e.g. 90m, 12h or 7d")
+		trackedOnly  = flag.Bool("tracked-only", false, "Only process files that git tracks, as git ls-files lists them")
+		newerCommit  = flag.String("newer-commit", "", "Only process files last committed to git within this `duration`, e.g. 30d (untracked files count as new)")
+		recent       = flag.String("recent", "", "Shorthand for -r -skip-common-junk -newer-than `duration` over . (or the arguments), reporting how many files matched")
+		pathRegex    = flag.String("path-regex", "", "Only process files whose cleaned, slash-separated path matches this `regexp`, e.g. '^src/(api|core)/'")
+		color        = flag.String("color", "auto", "Use -highlight's escape codes: auto (on a terminal), always or never")
+		transforms   = flag.String("transforms", "", "Run exactly these content transforms, in this `order`, e.g. nfc,reindent=2,max-lines=100 (overrides their own flags)")
+		htmlEscape   = flag.Bool("html-escape", false, "HTML-escape file contents and header paths (<, >, &, ' and \")")
+		escapeDelim  = flag.Bool("escape-delimiters", false, "Prefix content lines that look like file headers or footers with -escape-prefix")
+		escapePre    = flag.String("escape-prefix", "\u200b", "Prefix for -escape-delimiters")
+		lineTmpl     = flag.String("line-template", "", "Print each content line in this `format`, with {path}, {line} and {text} replaced")
+		anchors      = flag.Bool("anchors", false, "Start each content line with a [path:line] anchor a model can cite, as -line-template '"+anchorTemplate+"' does")
+		showWS       = flag.Bool("show-whitespace", false, "Show tabs as →, trailing spaces as · and line endings as ↵ (LF) or ⏎↵ (CRLF)")
+		sample       = flag.Int("sample", 0, "Print only N of the selected files, chosen at random (0 = all)")
+		sizePctile   = flag.Float64("skip-above-percentile", 0, "Skip the selected files larger than the `P`th percentile of their sizes (0 = none)")
+		previewLines = flag.Int("preview-lines", 10, "Lines to print around the line of a path:@LINE argument")
+		seed         = flag.Int64("seed", 0, "Seed for choosing the -sample, to get the same files again (default: random)")
+		diffAgainst  = flag.String("diff-against", "", "Print only the files that differ from the same paths under `DIR`, and note files only in one tree")
+		diff         = flag.Bool("diff", false, "With -diff-against, print each differing file as a unified diff")
+		changedSince = flag.String("changed-since", "", "Print only the changed lines of files that differ from git `REF`, with -changed-context lines around them")
+		changedCtx   = flag.Int("changed-context", 3, "With -changed-since, how many unchanged lines to show around each change")
+		gitRootPaths = flag.Bool("git-root-paths", false, "Show paths relative to the top of the git work tree each file is in, wherever llm-cat is run from")
+		gitStatus    = flag.Bool("git-status", false, "Mark each changed file's header with its git status, as --- path [M] ---")
+		blame        = flag.Bool("blame", false, "Start each line with the author and commit that last changed it, from git blame")
+		blameMax     = flag.Int("blame-max", 20, "With -blame, blame at most `N` files and print the rest as usual, since git blame is slow")
+		chunk        = flag.Bool("chunk", false, "Print files larger than -max-size as numbered parts instead of skipping them")
+		mergeSmall   = flag.Int("merge-small", 0, "Print runs of files smaller than `N` bytes (at most 8192) in one block, with a short sub-header for each")
+		outputDir    = flag.String("output-dir", "", "Write each file's (filtered) contents to `DIR`/<path> instead of printing them")
+		zipPath      = flag.String("zip", "", "Write the selected (filtered) files into a zip archive at `PATH` instead of printing them")
+		indexPath    = flag.String("index", "", "Also write the byte offset and length of each file's block in the output to `PATH` (JSON if it ends in .json, else TSV)")
+		ignoreLinks  = flag.Bool("ignore-symlinks", false, "Skip symlinks, including ones named as arguments")
+		derefFiles   = flag.Bool("deref-files", false, "Print what symlinks to files found while recursing point to, under a --- link -> target --- header")
+		symlinkLoops = flag.Bool("report-symlink-loops", false, "Report on stderr symlinks found while recursing that loop back to a directory above them")
+		base64Bin    = flag.Bool("base64-binary", false, "Print binary files base64-encoded, with their size in the header, instead of skipping them")
+		imagePlaces  = flag.Bool("image-placeholders", false, "Print a line like [image: logo.png, 240x120, PNG] for each image instead of skipping it")
+		outEncoding  = flag.String("output-encoding", "utf-8", "Write the dump in this charset: utf-8, latin1, windows-1252 or ascii")
+		onUnmappable = flag.String("on-unmappable", "replace", "What to do with characters -output-encoding can't represent: replace (with ?) or error")
+		skipMinified = flag.Bool("skip-minified", false, "Skip minified files: names containing .min., and JavaScript, CSS and HTML averaging over 300 bytes a line")
+		skipBinChk   = flag.Bool("skip-binary-check", false, "Treat every file as text without examining it, for trees known to hold no binaries")
+		binChkExt    = flag.String("binary-check-ext", "", "Check only files with these comma-separated `extensions` (e.g. .png,.jar,.bin) for binary data, and treat the rest as text")
+		lenientBin   = flag.Bool("lenient-binary", false, "Treat files without NUL bytes that are valid UTF-8 as text, however many control characters they hold")
+		sampleSz     = flag.Int("sample-size", sampleSize, "Examine the first `N` bytes of each file to tell whether it is binary (0 = the whole file, which is slower)")
+		requireUTF8  = flag.Bool("require-utf8", false, "Skip files that are not entirely valid UTF-8")
+		allowFIFO    = flag.Bool("allow-fifo", false, "Read named pipes (with a timeout) instead of skipping them")
+		prepend      = flag.String("prepend", "", "Text, or a file containing it, to print before the first file")
+		promptTmpl   = flag.String("prompt-template", "", "Text, or a file containing it, to print the files in at its {files} placeholder, with {count} and {tokens} filled in")
+		appendText   = flag.String("append", "", "Text, or a file containing it, to print after the last file")
+		chatJSON     = flag.Bool("chat-json", false, "Write the dump as a chat API messages array, [{\"role\":\"user\",\"content\":\"...\"}]")
+		chatMax      = flag.Int64("chat-max", 0, "With -chat-json, split the dump into user messages of at most `SIZE` bytes, between files")
+		system       = flag.String("system", "", "With -chat-json, start with a system message of this text, or the contents of this file")
+		trace        = flag.String("trace", "", "Print this JS/TS `entry` file and every local file it imports, transitively")
+		incremental  = flag.String("incremental", "", "Print only a marker for files unchanged since the run that wrote this manifest `file`, then update it")
+		dedupe       = flag.Bool("dedupe-content", false, "Print a file whose contents match an earlier file's as --- path (identical to other-path) ---")
+		full         = flag.Bool("full", false, "With -incremental, print every file in full but still update the manifest")
+		execCmd      = flag.String("exec", "", "Run this command once per selected file, replacing {} with the path")
+		outFile      = flag.String("o", "", "Write the output to `file` instead of stdout")
+		watchFiles   = flag.Bool("watch", false, "Dump again whenever a selected file changes, until interrupted")
+		editOutput   = flag.Bool("edit", false, "Write the output to a temporary file, open it in $EDITOR, and print what is saved (to stdout or -o)")
+		followFile   = flag.Bool("follow", false, "Print one file, then what is appended to it as it grows, like tail -f")
+		countByDir   = flag.Bool("count-by-dir", false, "Print a table of bytes, tokens and files per directory, largest first, instead of the contents")
+		countDepth   = flag.Int("count-depth", 1, "With -count-by-dir, how many directory levels below each argument to break totals down to")
+		saveSel      = flag.String("save-selection", "", "Write the names of the selected files to `file`, one per line, instead of their contents")
+		selDiff      = flag.String("selection-diff", "", "Print the files added to (+) and removed from (-) the selection since -save-selection wrote `file`, instead of their contents")
+		skeleton     = flag.Bool("skeleton", false, "Experimental: print the files with numbered lines, then their headers and line numbers with empty slots for a model to write edits in")
+		langStats    = flag.Bool("lang-stats", false, "Print a table of bytes, share and files per language, largest first, instead of the contents")
+		eolReport    = flag.Bool("eol-report", false, "Print each selected file's line-ending style and whether it ends in a newline, instead of its contents")
+		eventsFile   = flag.String("events", "", "Write progress as JSON lines (start, skip, done, summary) to `file`, e.g. /dev/fd/3")
+		bufferInput  = flag.Bool("buffer-input", false, "Read the whole list of paths from stdin before printing anything, instead of as it arrives")
+		ndjsonInput  = flag.Bool("ndjson-input", false, "Read stdin as JSON objects, one per line, each with a path or a name and contents, and optional lines, lang and transforms")
+		mixedStdin   = flag.Bool("mixed-stdin", false, "Read paths from stdin only from lines starting with @, and print runs of other lines as text under a <note N> header")
+		requireIn    = flag.Bool("require-input", false, "Exit with an error if no files are given as arguments or on stdin, instead of printing nothing")
+		stdinTimeout = flag.Duration("stdin-timeout", 0, "With no file arguments, give up with a hint if nothing arrives on stdin within this `duration` (0 = wait forever)")
+		failBudget   = flag.Bool("fail-over-budget", false, "With -total-max, -model or -max-total-tokens, exit with an error, printing nothing, if the output wouldn't fit instead of truncating it")
+		entropyMin   = flag.Float64("entropy-threshold", 4.5, "With -fail-on-secret, flag runs of token characters with at least this many `bits` of entropy per character (0 = don't)")
+		secretMinLen = flag.Int("min-secret-length", 20, "With -fail-on-secret, the shortest run of token characters -entropy-threshold considers")
+		failSecret   = flag.Bool("fail-on-secret", false, "Check the selected files for credentials (private keys, API tokens, ...) first, and exit with an error, printing nothing, if any are found")
+		listJSON     = flag.Bool("list-json", false, "Print the selected files as a JSON array of {path, size, ext, binary} instead of their contents")
+		exact        = flag.Bool("exact", false, "Print the selected files byte for byte, binary ones too, in a length-prefixed framing that -split reads back")
+		splitDir     = flag.String("split", "", "Read an -exact dump from stdin, or the one file argument, and write its files under `DIR`")
+		jsonHash     = flag.Bool("json-hash", false, "With -list-json, add the sha256 of the contents each file would be printed with")
+		help         = flag.Bool("h", false, "Show help")
 	)
-	flag.Parse()
+	args, err := expandArgfiles(os.Args[1:], 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading @file: %v\n", err)
+		os.Exit(2)
+	}
+	flag.CommandLine.Parse(args)
+
+	if *help {
+		showHelp()
+		return
+	}
+
+	opts := &options{
+		recurse:        *recurse,
+		depth:          *depth,
+		all:            *all,
+		extension:      *extension,
+		caseExt:        *caseExt,
+		exclude:        exclude,
+		grepContext:    -1,
+		firstMatch:     *firstMatch,
+		excludeTests:   *exclTests,
+		onlyTests:      *onlyTests,
+		namesOnly:      *namesOnly,
+		quiet:          *quiet,
+		mergeNotices:   *mergeNotes,
+		errorMarkers:   *errorMarks,
+		dedupe:         *dedupe,
+		verbose:        *verbose,
+		skipErrors:     *skipErrors,
+		skipVendored:   *skipVendor,
+		skipSubmodules: *skipSubmods,
+		submoduleNotes: *submodHeads,
+		reportSkips:    *reportSkips || *skipReport,
+		skipReport:     *skipReport,
+		summarizeSkips: *summarize,
+		binaryMarker:   *binMarker,
+		showMIME:       *showMIME,
+		showMode:       *showMode,
+		minifyJSON:     *minifyJSON,
+		prettyJSON:     *prettyJSON,
+		stripLicense:   *stripLicense,
+		nfc:            *nfc,
+		trimBlankEnds:  *trimBlank,
+		mime:           *mime,
+		notebooks:      *notebooks,
+		pdfText:        *pdfText,
+		nbMarkdown:     *nbMarkdown,
+		nbOutput:       *nbOutput,
+		lenientBinary:  *lenientBin,
+		skipBinaryChk:  *skipBinChk,
+		base64Binary:   *base64Bin,
+		imagePlaces:    *imagePlaces,
+		maxSize:        *maxSize,
+		totalMax:       *totalMax,
+		model:          *model,
+		dropOver:       *dropOver,
+		maxTokens:      *maxTokens,
+		maxTotalLines:  *maxTotalLns,
+		maxFileTokens:  *maxFileToks,
+		countTokens:    *countToks,
+		countHeader:    *countHeader,
+		treeJSON:       *treeJSON,
+		treeEmptyDirs:  *treeEmpty,
+		numbered:       *numbered,
+		smartHeader:    *smartHeader,
+		headerTokens:   *headerToks,
+		warnTokens:     *warnTokens,
+		stats:          *stats || *statFormat != "",
+		human:          *human,
+		trackedOnly:    *trackedOnly,
+		entropyMin:     *entropyMin,
+		secretMinLen:   *secretMinLen,
+		statsStdout:    *statsStdout,
+		countDepth:     *countDepth,
+		perDirMax:      *perDirMax,
+		maxDirFiles:    *maxDirFiles,
+		maxPerExt:      *maxPerExt,
+		splitOutput:    *splitOutput,
+		groupByExt:     *groupByExt,
+		docsFirst:      *docsFirst,
+		readmeFirst:    *readmeFirst,
+		dirContext:     *dirContext,
+		dirContextMax:  *dirCtxLines,
+		allowFIFO:      *allowFIFO,
+		ignoreSymlinks: *ignoreLinks,
+		symlinkLoops:   *symlinkLoops,
+		derefFiles:     *derefFiles,
+		requireUTF8:    *requireUTF8,
+		markdown:       *markdown,
+		xml:            *xmlOut,
+		frontMatter:    *frontMatter,
+		mmap:           *useMmap,
+		bufferSize:     *bufferSize,
+		retry:          *retry,
+		ioWorkers:      *ioWorkers,
+		fastWalk:       *fastWalk,
+		statPrefetch:   *statPrefetch,
+		cpuWorkers:     *cpuWorkers,
+		chunk:          *chunk,
+		gitStatus:      *gitStatus,
+		blame:          *blame,
+		blameMax:       *blameMax,
+		gitRootPaths:   *gitRootPaths,
+		diffAgainst:    *diffAgainst,
+		diff:           *diff,
+		sample:         *sample,
+		sizePercentile: *sizePctile,
+		seed:           *seed,
+		previewLines:   *previewLines,
+
+		showWhitespace: *showWS,
+		lineTemplate:   *lineTmpl,
+		compactImports: *compactImps,
+		stripImports:   *stripImports,
+		reindent:       *reindent,
+		dedupBlocks:    *dedupBlocks,
+		commentOut:     *commentOut,
+		editorconfig:   *editorCfg,
+		htmlEscape:     *htmlEscape,
+		stdinName:      *stdinName,
+		outputDir:      *outputDir,
+		zipPath:        *zipPath,
+		chatJSON:       *chatJSON,
+		chatMax:        *chatMax,
+		indexPath:      *indexPath,
+		mergeSmall:     *mergeSmall,
+		sampleSize:     *sampleSz,
+		jsonHash:       *jsonHash,
+		skipMinified:   *skipMinified,
+		changedSince:   *changedSince,
+		changedContext: *changedCtx,
+		maxLines:       *maxLines,
+	}
+	if *statFormat != "" {
+		tmpl, err := template.New("stat-format").Parse(*statFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad -stat-format: %v\n", err)
+			os.Exit(2)
+		}
+		opts.statFormat = tmpl
+	}
+	if *anchors {
+		if *lineTmpl != "" {
+			fmt.Fprintln(os.Stderr, "Error: -anchors can't be combined with -line-template")
+			os.Exit(2)
+		}
+		opts.lineTemplate = anchorTemplate
+	}
+	if opts.pdfText {
+		if _, err := exec.LookPath(pdfToText); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -pdf-text requires "+pdfToText+", from poppler-utils, on the PATH")
+			os.Exit(2)
+		}
+	}
+	if (*nbMarkdown || *nbOutput) && !*notebooks {
+		fmt.Fprintln(os.Stderr, "Error: -include-nb-markdown and -include-nb-output require -render-notebooks")
+		os.Exit(2)
+	}
+	if *skipMatching != "" {
+		re, err := regexp.Compile(*skipMatching)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad -skip-matching pattern: %v\n", err)
+			os.Exit(2)
+		}
+		opts.skipMatching = re
+	}
+	if *grep != "" {
+		re, err := regexp.Compile(*grep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad -grep pattern: %v\n", err)
+			os.Exit(2)
+		}
+		opts.grep = re
+		if flagSet("grep-context") || opts.firstMatch {
+			opts.grepContext = max(*grepContext, 0)
+		}
+	} else if flagSet("grep-context") || opts.firstMatch {
+		fmt.Fprintln(os.Stderr, "Error: -grep-context and -first-match require -grep")
+		os.Exit(2)
+	}
+	if name, err := lookupEncoding(*outEncoding); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	} else {
+		opts.outputEncoding = name
+	}
+	if opts.outputEncoding != "utf-8" && (*outputDir != "" || *zipPath != "") {
+		fmt.Fprintln(os.Stderr, "Error: -output-encoding can't be used with -output-dir or -zip")
+		os.Exit(2)
+	}
+	switch *onUnmappable {
+	case "replace":
+		opts.replaceUnmap = true
+	case "error":
+	default:
+		fmt.Fprintln(os.Stderr, "Error: -on-unmappable must be replace or error")
+		os.Exit(2)
+	}
+	if *binChkExt != "" {
+		if opts.skipBinaryChk {
+			fmt.Fprintln(os.Stderr, "Error: -skip-binary-check and -binary-check-ext can't be combined")
+			os.Exit(2)
+		}
+		for _, ext := range strings.Split(*binChkExt, ",") {
+			if ext = strings.ToLower(strings.TrimSpace(ext)); ext != "" {
+				if !strings.HasPrefix(ext, ".") {
+					ext = "." + ext
+				}
+				opts.binaryCheckExt = append(opts.binaryCheckExt, ext)
+			}
+		}
+	}
+	if *pathRegex != "" {
+		re, err := regexp.Compile(*pathRegex)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad -path-regex pattern: %v\n", err)
+			os.Exit(2)
+		}
+		opts.pathRegex = re
+	}
+	if *recent != "" {
+		if *newerThan != "" {
+			fmt.Fprintln(os.Stderr, "Error: -recent is -newer-than with -r; give only one of them")
+			os.Exit(2)
+		}
+		*newerThan = *recent
+		opts.recurse, opts.recent = true, *recent
+		*skipJunk = true
+	}
+	if *newerThan != "" {
+		age, err := parseAge(*newerThan)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad -newer-than duration: %v\n", err)
+			os.Exit(2)
+		}
+		opts.newerThan = time.Now().Add(-age)
+	}
+	if *newerCommit != "" {
+		age, err := parseAge(*newerCommit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad -newer-commit duration: %v\n", err)
+			os.Exit(2)
+		}
+		opts.newerCommit, opts.newerCommitAge = time.Now().Add(-age), *newerCommit
+	}
+	if opts.previewLines < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -preview-lines must be at least 1")
+		os.Exit(2)
+	}
+	if opts.dirContextMax < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -dir-context-lines must be at least 1")
+		os.Exit(2)
+	}
+	if opts.dirContext && (opts.xml || opts.frontMatter) {
+		fmt.Fprintln(os.Stderr, "Error: -dir-context can't be combined with -xml or -front-matter")
+		os.Exit(2)
+	}
+	if opts.warnTokens < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -warn-tokens must not be negative")
+		os.Exit(2)
+	}
+	if opts.maxDirFiles < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -max-files-per-dir must not be negative")
+		os.Exit(2)
+	}
+	if opts.maxTotalLines < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -max-total-lines must not be negative")
+		os.Exit(2)
+	}
+	if opts.dedupBlocks < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -dedup-blocks must not be negative")
+		os.Exit(2)
+	}
+	switch {
+	case *wrap < 0:
+		fmt.Fprintln(os.Stderr, "Error: -wrap must not be negative")
+		os.Exit(2)
+	case flagSet("wrap"):
+		opts.wrap = *wrap
+	case *outFile == "" && *outputDir == "" && *zipPath == "" && isTerminal(os.Stdout):
+		opts.wrap = terminalWidth(os.Stdout)
+	}
+	if *transforms != "" {
+		if err := applyTransforms(*transforms, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -transforms: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *highlight != "" {
+		re, err := regexp.Compile(*highlight)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad -highlight pattern: %v\n", err)
+			os.Exit(2)
+		}
+		switch *color {
+		case "auto":
+			if *outFile == "" && *outputDir == "" && *zipPath == "" && isTerminal(os.Stdout) {
+				opts.highlight = re
+			}
+		case "always":
+			if *outputDir == "" && *zipPath == "" {
+				opts.highlight = re
+			}
+		case "never":
+		default:
+			fmt.Fprintln(os.Stderr, "Error: -color must be auto, always or never")
+			os.Exit(2)
+		}
+	}
+	if *skipJunk || len(junkDirs) > 0 {
+		opts.junkDirs = make(map[string]bool)
+		if *skipJunk {
+			for _, name := range junkDirNames {
+				opts.junkDirs[name] = true
+			}
+		}
+		for _, name := range junkDirs {
+			opts.junkDirs[name] = true
+		}
+	}
+	if *maxBytesExt != "" {
+		limits, err := parseExtSizes(*maxBytesExt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -max-bytes-ext: %v\n", err)
+			os.Exit(2)
+		}
+		opts.extMax = limits
+	}
+	if *excludeFrom != "" {
+		patterns, err := loadExcludes(*excludeFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading -exclude-from: %v\n", err)
+			os.Exit(1)
+		}
+		opts.exclude = append(opts.exclude, patterns...)
+	}
+	if *orderFile != "" {
+		patterns, err := loadPatterns(*orderFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading -order-file: %v\n", err)
+			os.Exit(1)
+		}
+		opts.order = patterns
+	}
+	if *lang != "" && *extension == "" {
+		patterns, err := expandLangs(*lang)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		opts.include = patterns
+	}
+	opts.include = append(opts.include, include...)
+	if opts.sizePercentile < 0 || opts.sizePercentile > 100 {
+		fmt.Fprintln(os.Stderr, "Error: -skip-above-percentile must be between 0 and 100")
+		os.Exit(2)
+	}
+	if opts.sample < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -sample must not be negative")
+		os.Exit(2)
+	}
+	if opts.sample > 0 && !flagSet("seed") {
+		opts.seed = time.Now().UnixNano()
+	}
+	if *nativeSeps {
+		if flagSet("path-style") && *pathStyle != "native" {
+			fmt.Fprintln(os.Stderr, "Error: -native-separators can't be combined with -path-style posix")
+			os.Exit(2)
+		}
+		*pathStyle = "native"
+	}
+	switch *pathStyle {
+	case "native":
+	case "posix":
+		opts.posixPaths = true
+	default:
+		fmt.Fprintln(os.Stderr, "Error: -path-style must be native or posix")
+		os.Exit(2)
+	}
+	if opts.diff && opts.diffAgainst == "" {
+		fmt.Fprintln(os.Stderr, "Error: -diff requires -diff-against")
+		os.Exit(2)
+	}
+	if opts.countDepth < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -count-depth must not be negative")
+		os.Exit(2)
+	}
+	if opts.retry < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -retry must not be negative")
+		os.Exit(2)
+	}
+	if *extMap != "" {
+		if err := addExtLanguages(*extMap, opts.caseExt); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -ext-map: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *langFilter != "" {
+		for _, name := range strings.Split(*langFilter, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !knownLanguage(name) {
+				fmt.Fprintf(os.Stderr, "Error: unknown -lang-filter language %q\n", name)
+				os.Exit(2)
+			}
+			opts.langFilter = append(opts.langFilter, name)
+		}
+	}
+	if opts.numbered && (opts.xml || opts.frontMatter || opts.mergeSmall > 0 || opts.indexPath != "" || opts.splitOutput > 0) {
+		fmt.Fprintln(os.Stderr, "Error: -numbered can't be combined with -xml, -front-matter, -merge-small, -index or -split-output")
+		os.Exit(2)
+	}
+	if flagSet("blame-max") && !opts.blame {
+		fmt.Fprintln(os.Stderr, "Error: -blame-max requires -blame")
+		os.Exit(2)
+	}
+	if opts.blameMax < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -blame-max must be at least 1")
+		os.Exit(2)
+	}
+	// -blame matches lines to git's by their place in the file.
+	if opts.blame && (opts.grepContext >= 0 || opts.stripLicense || opts.diffAgainst != "" || opts.changedSince != "") {
+		fmt.Fprintln(os.Stderr, "Error: -blame can't be combined with -grep-context, -first-match, -strip-license, -diff-against or -changed-since")
+		os.Exit(2)
+	}
+	if opts.smartHeader && (opts.xml || opts.frontMatter || opts.numbered || opts.mergeSmall > 0 || opts.indexPath != "" || opts.splitOutput > 0) {
+		fmt.Fprintln(os.Stderr, "Error: -smart-header can't be combined with -xml, -front-matter, -numbered, -merge-small, -index or -split-output")
+		os.Exit(2)
+	}
+	if opts.countHeader && opts.indexPath != "" {
+		fmt.Fprintln(os.Stderr, "Error: -count-header can't be used with -index")
+		os.Exit(2)
+	}
+	if opts.treeEmptyDirs && !opts.treeJSON {
+		fmt.Fprintln(os.Stderr, "Error: -tree-json-empty-dirs requires -tree-json")
+		os.Exit(2)
+	}
+	if opts.treeJSON && (opts.indexPath != "" || opts.splitOutput > 0) {
+		fmt.Fprintln(os.Stderr, "Error: -tree-json can't be combined with -index or -split-output")
+		os.Exit(2)
+	}
+	if opts.splitOutput < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -split-output must not be negative")
+		os.Exit(2)
+	}
+	if opts.splitOutput > 0 {
+		if *outFile == "" {
+			fmt.Fprintln(os.Stderr, "Error: -split-output requires -o")
+			os.Exit(2)
+		}
+		if opts.indexPath != "" || opts.countHeader {
+			fmt.Fprintln(os.Stderr, "Error: -split-output can't be combined with -index or -count-header")
+			os.Exit(2)
+		}
+	}
+	if (opts.chatMax != 0 || *system != "") && !opts.chatJSON {
+		fmt.Fprintln(os.Stderr, "Error: -chat-max and -system require -chat-json")
+		os.Exit(2)
+	}
+	if opts.chatMax < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -chat-max must not be negative")
+		os.Exit(2)
+	}
+	if opts.chatJSON && (opts.outputEncoding != "utf-8" || opts.indexPath != "" || opts.splitOutput > 0 || opts.outputDir != "" || opts.zipPath != "") {
+		fmt.Fprintln(os.Stderr, "Error: -chat-json can't be combined with -output-encoding, -index, -split-output, -output-dir or -zip")
+		os.Exit(2)
+	}
+	if *exact && (opts.xml || opts.markdown || opts.frontMatter || opts.chatJSON || opts.zipPath != "" || opts.outputDir != "" || opts.splitOutput > 0 || opts.indexPath != "" || *watchFiles) {
+		fmt.Fprintln(os.Stderr, "Error: -exact can't be combined with -xml, -md, -front-matter, -chat-json, -zip, -output-dir, -split-output, -index or -watch")
+		os.Exit(2)
+	}
+	if *exact {
+		for _, name := range exactConflicts {
+			if flagSet(name) {
+				fmt.Fprintf(os.Stderr, "Error: -exact prints files as they are, so it can't be combined with -%s\n", name)
+				os.Exit(2)
+			}
+		}
+	}
+	if opts.jsonHash && !*listJSON {
+		fmt.Fprintln(os.Stderr, "Error: -json-hash requires -list-json")
+		os.Exit(2)
+	}
+	if flagSet("changed-context") && opts.changedSince == "" {
+		fmt.Fprintln(os.Stderr, "Error: -changed-context requires -changed-since")
+		os.Exit(2)
+	}
+	if opts.changedContext < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -changed-context must not be negative")
+		os.Exit(2)
+	}
+	if opts.minifyJSON && opts.prettyJSON {
+		fmt.Fprintln(os.Stderr, "Error: -minify-json and -pretty-json can't be combined")
+		os.Exit(2)
+	}
+	if opts.sampleSize < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -sample-size must not be negative")
+		os.Exit(2)
+	}
+	if opts.mergeSmall < 0 || opts.sampleSize > 0 && opts.mergeSmall > opts.sampleSize {
+		fmt.Fprintln(os.Stderr, "Error: -merge-small must be between 0 and -sample-size")
+		os.Exit(2)
+	}
+	if opts.depth < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -depth must not be negative")
+		os.Exit(2)
+	}
+	if opts.bufferSize < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -buffer-size must not be negative")
+		os.Exit(2)
+	}
+	if opts.fastWalk < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -fast-walk must not be negative")
+		os.Exit(2)
+	}
+	if opts.statPrefetch < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -concurrent-stat-prefetch must not be negative")
+		os.Exit(2)
+	}
+	if opts.ioWorkers < 1 || opts.cpuWorkers < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -io-workers and -cpu-workers must be at least 1")
+		os.Exit(2)
+	}
+	if opts.xml && opts.markdown {
+		fmt.Fprintln(os.Stderr, "Error: -xml and -md can't be combined")
+		os.Exit(2)
+	}
+	if opts.frontMatter && (opts.xml || opts.markdown || opts.mergeSmall > 0) {
+		fmt.Fprintln(os.Stderr, "Error: -front-matter can't be combined with -xml, -md or -merge-small")
+		os.Exit(2)
+	}
+	if opts.excludeTests && opts.onlyTests {
+		fmt.Fprintln(os.Stderr, "Error: -exclude-tests and -only-tests can't be combined")
+		os.Exit(2)
+	}
+	if opts.model != "" {
+		window, ok := modelContextTokens[opts.model]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: unknown model %q (known models: %s)\n", opts.model, strings.Join(knownModels(), ", "))
+			os.Exit(2)
+		}
+		if !flagSet("total-max") {
+			opts.totalMax = window * bytesPerToken
+		}
+	}
+	if opts.dropOver < 0 || opts.dropOver > 1 {
+		fmt.Fprintln(os.Stderr, "Error: -drop-over must be between 0 and 1")
+		os.Exit(2)
+	}
+	if opts.dropOver > 0 && opts.totalMax == 0 {
+		fmt.Fprintln(os.Stderr, "Error: -drop-over requires -total-max or -model")
+		os.Exit(2)
+	}
+	if opts.chunk && (opts.maxSize == 0 || opts.outputDir != "" || opts.zipPath != "") {
+		fmt.Fprintln(os.Stderr, "Error: -chunk requires -max-size and can't be combined with -output-dir or -zip")
+		os.Exit(2)
+	}
+	if *escapeDelim {
+		if *escapePre == "" {
+			fmt.Fprintln(os.Stderr, "Error: -escape-prefix can't be empty")
+			os.Exit(2)
+		}
+		opts.escapePrefix = *escapePre
+	}
+	if opts.indexPath != "" && (opts.outputDir != "" || opts.zipPath != "") {
+		fmt.Fprintln(os.Stderr, "Error: -index can't be combined with -output-dir or -zip")
+		os.Exit(2)
+	}
+	if opts.zipPath != "" && (opts.outputDir != "" || *outFile != "") {
+		fmt.Fprintln(os.Stderr, "Error: -zip can't be combined with -output-dir or -o")
+		os.Exit(2)
+	}
+	if *execCmd != "" {
+		opts.exec = strings.Fields(*execCmd)
+		if len(opts.exec) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: -exec requires a command")
+			os.Exit(2)
+		}
+	}
+
+	if *splitDir != "" {
+		in := io.Reader(os.Stdin)
+		switch flag.NArg() {
+		case 0:
+		case 1:
+			f, err := os.Open(flag.Arg(0))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			in = f
+		default:
+			fmt.Fprintln(os.Stderr, "Error: -split reads one -exact dump, from stdin or the one file argument")
+			os.Exit(2)
+		}
+		if err := splitExact(in, *splitDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -split: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	files := &pathList{paths: flag.Args()}
+	if *globArgs {
+		var paths []string
+		for _, p := range files.paths {
+			if p == "-" || !hasGlob(p) {
+				paths = append(paths, p)
+				continue
+			}
+			matches, err := expandGlob(p, opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			paths = append(paths, matches...)
+		}
+		files.paths = paths
+	}
+	ranges, ranged := make(lineRanges), false
+	for i, p := range files.paths {
+		path, lr, ok, err := parseLineRange(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		// A file named without a range as well is printed whole there.
+		files.paths[i], ranges[path] = path, append(ranges[path], lr)
+		ranged = ranged || ok
+	}
+	if ranged {
+		opts.ranges = ranges
+	}
+	if opts.recent != "" && len(files.paths) == 0 && !*mixedStdin {
+		files.paths = []string{"."}
+	}
+	if *trace != "" {
+		deps, err := traceDeps(*trace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error tracing %s: %v\n", *trace, err)
+			os.Exit(1)
+		}
+		files.paths = append(files.paths, deps...)
+	}
+	if *ndjsonInput {
+		if len(files.paths) > 0 || *mixedStdin {
+			fmt.Fprintln(os.Stderr, "Error: -ndjson-input reads its list from stdin, so it takes no file arguments or -mixed-stdin")
+			os.Exit(2)
+		}
+		if *watchFiles || *countByDir || *langStats || *skeleton || *eolReport || *listJSON || *saveSel != "" || *selDiff != "" {
+			fmt.Fprintln(os.Stderr, "Error: -ndjson-input can't be combined with -watch, -count-by-dir, -lang-stats, -skeleton, -eol-report, -list-json or the selection flags")
+			os.Exit(2)
+		}
+		files.objects = make(map[string]*inputObject)
+	}
+	if *mixedStdin {
+		if len(files.paths) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: -mixed-stdin reads its list from stdin, so it takes no file arguments")
+			os.Exit(2)
+		}
+		if *watchFiles || *countByDir || *langStats || *skeleton || *eolReport || *listJSON || *saveSel != "" || *selDiff != "" {
+			fmt.Fprintln(os.Stderr, "Error: -mixed-stdin can't be combined with -watch, -count-by-dir, -lang-stats, -skeleton, -eol-report, -list-json or the selection flags")
+			os.Exit(2)
+		}
+		files.mixed, files.notes = true, make(map[string][]byte)
+	}
+	if len(files.paths) == 0 {
+		var in io.Reader = os.Stdin
+		if *stdinTimeout > 0 {
+			var ok bool
+			if in, ok = waitForInput(os.Stdin, *stdinTimeout); !ok {
+				fmt.Fprintf(os.Stderr, "Error: nothing on stdin after %v; pass files as arguments or pipe in a list of paths, as in git ls-files | llm-cat\n", *stdinTimeout)
+				os.Exit(2)
+			}
+		}
+		files.stdin = bufio.NewScanner(in)
+		if files.objects != nil {
+			// An object can carry a whole file's contents.
+			files.stdin.Buffer(nil, math.MaxInt32)
+		}
+		// -watch walks the list again for every dump, -diff-against walks
+		// it again to find files only in the other tree, and
+		// -fail-on-secret and -fail-over-budget walk it once before the
+		// dump.
+		if *bufferInput || *watchFiles || *diffAgainst != "" || *failSecret || *failBudget {
+			if err := files.buffer(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *requireIn {
+			// Wait for the first path, but keep streaming the rest.
+			if err := files.peek(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if *requireIn && len(files.paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no files given as arguments or on stdin (-require-input)")
+		os.Exit(1)
+	}
+
+	if *watchFiles {
+		for _, f := range files.paths {
+			if f == "-" {
+				fmt.Fprintln(os.Stderr, "Error: -watch can't re-read - (stdin)")
+				os.Exit(2)
+			}
+		}
+		if *incremental != "" {
+			fmt.Fprintln(os.Stderr, "Error: -watch can't be combined with -incremental")
+			os.Exit(2)
+		}
+	}
+
+	if *failBudget {
+		if opts.totalMax == 0 && opts.maxTokens == 0 {
+			fmt.Fprintln(os.Stderr, "Error: -fail-over-budget requires -total-max, -model or -max-total-tokens")
+			os.Exit(2)
+		}
+		if *watchFiles || *incremental != "" || opts.exec != nil {
+			fmt.Fprintln(os.Stderr, "Error: -fail-over-budget can't be combined with -watch, -incremental or -exec")
+			os.Exit(2)
+		}
+		for _, f := range files.paths {
+			if f == "-" {
+				fmt.Fprintln(os.Stderr, "Error: -fail-over-budget can't measure - (stdin)")
+				os.Exit(2)
+			}
+		}
+	}
+	if *editOutput {
+		if strings.TrimSpace(os.Getenv("EDITOR")) == "" {
+			fmt.Fprintln(os.Stderr, "Error: -edit needs $EDITOR to be set to an editor command, e.g. EDITOR=vim")
+			os.Exit(2)
+		}
+		if *watchFiles || *followFile || opts.outputDir != "" || opts.zipPath != "" || opts.splitOutput > 0 || opts.indexPath != "" {
+			fmt.Fprintln(os.Stderr, "Error: -edit can't be combined with -watch, -follow, -output-dir, -zip, -split-output or -index")
+			os.Exit(2)
+		}
+	}
+	if *followFile {
+		if len(files.paths) != 1 || files.paths[0] == "-" {
+			fmt.Fprintln(os.Stderr, "Error: -follow takes exactly one file")
+			os.Exit(2)
+		}
+		if *watchFiles || opts.xml || opts.markdown || opts.frontMatter || *outFile != "" || opts.outputDir != "" || opts.zipPath != "" {
+			fmt.Fprintln(os.Stderr, "Error: -follow prints to stdout in the plain format, and can't be combined with -watch, -xml, -md, -front-matter, -o, -output-dir or -zip")
+			os.Exit(2)
+		}
+	}
+
+	r := newRunner(opts)
+	if opts.changedSince != "" {
+		changes, err := loadChangeSet(opts.changedSince)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -changed-since: %v\n", err)
+			os.Exit(1)
+		}
+		r.changes = changes
+	}
+	for _, t := range []struct {
+		flag string
+		arg  string
+		dst  *string
+	}{{"prepend", *prepend, &r.before}, {"append", *appendText, &r.after}, {"system", *system, &opts.chatSystem}} {
+		if t.arg == "" {
+			continue
+		}
+		text, err := loadText(t.arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading -%s file: %v\n", t.flag, err)
+			os.Exit(1)
+		}
+		*t.dst = text
+	}
+	opts.chatSystem = strings.TrimSuffix(opts.chatSystem, "\n")
+	if *promptTmpl != "" {
+		if *prepend != "" || *appendText != "" {
+			fmt.Fprintln(os.Stderr, "Error: -prompt-template can't be combined with -prepend or -append")
+			os.Exit(2)
+		}
+		text, err := loadText(*promptTmpl)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading -prompt-template file: %v\n", err)
+			os.Exit(1)
+		}
+		if r.before, r.after, err = parsePromptTemplate(text); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad -prompt-template: %v\n", err)
+			os.Exit(2)
+		}
+		if promptTotals(r.before) && opts.indexPath != "" && !opts.countHeader {
+			fmt.Fprintln(os.Stderr, "Error: -index can't be used with {count} or {tokens} before {files} in -prompt-template")
+			os.Exit(2)
+		}
+		if promptTotals(r.before) && opts.splitOutput > 0 {
+			fmt.Fprintln(os.Stderr, "Error: -split-output can't be used with {count} or {tokens} before {files} in -prompt-template")
+			os.Exit(2)
+		}
+		r.template = true
+	}
+
+	if *tokName != "" {
+		bpe, err := loadBPE(*tokName, tokenizerDir())
+		switch {
+		case err == nil:
+			r.tok = bpe
+			if !*noTokCache {
+				path := tokenCachePath(*tokName)
+				if bpe.cache, err = loadTokenCache(path); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: reading token cache %s: %v\n", path, err)
+				}
+			}
+		case os.IsNotExist(err):
+			fmt.Fprintf(os.Stderr, "Warning: no data for tokenizer %s (%v); estimating tokens instead\n", *tokName, err)
+		default:
+			fmt.Fprintf(os.Stderr, "Error loading tokenizer: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *incremental != "" {
+		incr, err := loadIncremental(*incremental, *full)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading manifest: %v\n", err)
+			os.Exit(1)
+		}
+		r.incr = incr
+	}
+	if *failSecret {
+		if opts.entropyMin < 0 || opts.secretMinLen < 1 {
+			fmt.Fprintln(os.Stderr, "Error: -entropy-threshold must not be negative, and -min-secret-length must be at least 1")
+			os.Exit(2)
+		}
+		for _, f := range files.paths {
+			if f == "-" {
+				fmt.Fprintln(os.Stderr, "Error: -fail-on-secret can't check - (stdin)")
+				os.Exit(2)
+			}
+		}
+		if n := r.checkSecrets(files); n > 0 {
+			fmt.Fprintf(os.Stderr, "Error: possible secrets in %d file(s); nothing was printed\n", n)
+			os.Exit(1)
+		}
+	}
+	if *failBudget {
+		if err := r.checkBudget(files); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v; nothing was printed\n", err)
+			os.Exit(1)
+		}
+	}
+	if *countByDir {
+		if err := r.countByDir(files, *outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *skeleton {
+		if err := r.skeleton(files, *outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *langStats {
+		if err := r.langStats(files, *outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *eolReport {
+		if err := r.eolReport(files, *outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *saveSel != "" || *selDiff != "" {
+		if err := r.compareSelection(files, *selDiff, *saveSel, *outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *listJSON {
+		if err := r.listJSON(files, *outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *exact {
+		if err := r.exactDump(files, *outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *eventsFile != "" {
+		f, err := os.Create(*eventsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r.events = newEventLog(f)
+	}
+	if *watchFiles {
+		r.watch(files, *outFile)
+		return
+	}
+	if *followFile {
+		r.out = os.Stdout
+		if err := r.follow(files.paths[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -follow: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if opts.zipPath != "" {
+		if err := r.zipTo(files, opts.zipPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *editOutput {
+		if err := r.edit(files, *outFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := r.dumpTo(files, *outFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// dumpTo prints files to the file at path, replacing its contents, or to
+// stdout if path is empty, and then reports on the run.
+func (r *runner) dumpTo(files *pathList, path string) error {
+	var f *os.File
+	r.out = os.Stdout
+	if path != "" && r.opts.splitOutput > 0 {
+		r.split = &splitWriter{path: path, size: r.opts.splitOutput}
+		r.out = r.split
+	} else if path != "" {
+		var err error
+		if f, err = os.Create(path); err != nil {
+			return err
+		}
+		r.out = f
+	}
+	if r.opts.indexPath != "" {
+		r.counter = &countingWriter{w: r.out}
+		r.out = r.counter
+	}
+	var enc *encodingWriter
+	if name := r.opts.outputEncoding; name != "utf-8" {
+		enc = &encodingWriter{w: r.out, name: name, encode: outputEncodings[name], replace: r.opts.replaceUnmap}
+		r.out = enc
+	}
+	if r.opts.chatJSON {
+		r.chat = &chatWriter{w: r.out, system: r.opts.chatSystem, size: r.opts.chatMax}
+		r.out = r.chat
+	}
+	r.dump(files)
+	if c := r.chat; c != nil {
+		r.chat = nil
+		if err := c.close(); err != nil {
+			if f != nil {
+				f.Close()
+			}
+			return err
+		}
+	}
+	if enc != nil {
+		if err := enc.close(); err != nil {
+			if f != nil {
+				f.Close()
+			}
+			return fmt.Errorf("-output-encoding: %v", err)
+		}
+	}
+	if f != nil {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	if s := r.split; s != nil {
+		r.split = nil
+		if err := s.close(); err != nil {
+			return err
+		}
+		if !r.opts.quiet {
+			s.reportParts(r.size)
+		}
+	}
+	if r.opts.indexPath != "" {
+		if err := r.writeIndex(r.opts.indexPath); err != nil {
+			return fmt.Errorf("writing index: %v", err)
+		}
+	}
+	return r.finish()
+}
+
+// dump prints files, starting over from nothing printed.
+func (r *runner) dump(files *pathList) {
+	opts := r.opts
+	r.total, r.tokens = 0, 0
+	clear(r.dirBytes)
+	clear(r.dirFiles)
+	clear(r.dirSkips)
+	clear(r.extFiles)
+	clear(r.extSkips)
+	clear(r.extBytes)
+	clear(r.seen)
+	r.stats = newDumpStats()
+	r.files, r.skips = 0, nil
+	r.oldCommits, r.untracked = 0, 0
+	r.lineSkips = 0
+	r.numbered, r.numberedName = 0, ""
+	r.blocks, r.tree, r.treeDirs = 0, nil, nil
+	r.blamed = 0
+	clear(r.rangesUsed)
+	clear(r.dirContexts)
+	r.index = nil
+	r.licenses = licenseTotals{}
+	if opts.gitStatus {
+		status, err := loadGitStatus()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -git-status: %v\n", err)
+		}
+		r.gitStatus = status
+	}
+
+	if opts.countHeader || opts.treeJSON || opts.numbered || opts.smartHeader || r.template && promptTotals(r.before) {
+		// What comes before the files, or each file's number or
+		// header, needs the totals, so hold the files back until they
+		// are known.
+		out := r.out
+		var held bytes.Buffer
+		r.out = &held
+		defer func() {
+			r.out = out
+			fmt.Fprint(out, r.expandPrompt(r.before))
+			if opts.countHeader {
+				fmt.Fprintln(out, r.countHeader())
+			}
+			if opts.treeJSON {
+				if opts.treeEmptyDirs {
+					r.treeDirs = walkedDirs(files, opts)
+				}
+				fmt.Fprintf(out, "%s\n", r.treeJSON())
+			}
+			switch {
+			case opts.numbered:
+				r.fillNumbered(&held)
+			case opts.smartHeader:
+				r.fillSmartHeaders(&held)
+			default:
+				held.WriteTo(out)
+			}
+			fmt.Fprint(out, r.expandPrompt(r.after))
+		}()
+	} else {
+		fmt.Fprint(r.out, r.before)
+		defer func() { fmt.Fprint(r.out, r.expandPrompt(r.after)) }()
+	}
+	r.small = nil
+	defer r.flushSmall()
+	if opts.diffAgainst != "" {
+		defer r.noteOnlyInOther(files)
+	}
+	collect := opts.groupByExt || opts.docsFirst || opts.readmeFirst || opts.sample > 0 || opts.sizePercentile > 0 || len(opts.order) > 0
+	if !collect && !r.pipelined() {
+		walkFiles(files, opts, r.emit)
+		return
+	}
+
+	// Grouping, reordering and sampling need the whole selection before
+	// anything is printed; otherwise, the pipeline reads ahead of printing
+	// while the walk goes on.
+	var selected []fileEntry
+	var headings map[int]string
+	if collect {
+		walkFiles(files, opts, func(e fileEntry) error {
+			selected = append(selected, e)
+			return nil
+		})
+		if opts.sizePercentile > 0 {
+			selected = r.dropAbovePercentile(selected)
+		}
+		if opts.sample > 0 {
+			selected = r.sampleFiles(selected)
+		}
+		if opts.readmeFirst {
+			selected = readmeFirst(selected)
+		}
+		if opts.docsFirst {
+			selected = docsFirst(selected)
+		}
+		if len(opts.order) > 0 {
+			selected = orderFiles(selected, opts.order)
+		}
+		if opts.groupByExt {
+			selected, headings = r.groupByLanguage(selected)
+		}
+	}
+	walked := make(chan fileEntry)
+	go func() {
+		defer close(walked)
+		if collect {
+			for _, e := range selected {
+				walked <- e
+			}
+			return
+		}
+		walkFiles(files, opts, func(e fileEntry) error {
+			walked <- e
+			return nil
+		})
+	}()
+	var entries <-chan fileEntry = walked
+	if r.pipelined() {
+		entries = r.startPipeline(entries)
+		defer func() { r.queue = nil }()
+	}
+	i := 0
+	for e := range entries {
+		if h, ok := headings[i]; ok && opts.exec == nil {
+			r.flushSmall()
+			fmt.Fprintf(r.out, "\n## %s\n", h)
+		}
+		if err := r.emit(e); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", e.path, err)
+		}
+		i++
+	}
+}
+
+// finish saves the -incremental manifest and the token cache and reports
+// the token count, for a dump that has just completed.
+func (r *runner) finish() error {
+	opts := r.opts
+	if r.incr != nil {
+		if err := r.incr.save(); err != nil {
+			return fmt.Errorf("writing manifest: %v", err)
+		}
+	}
+	if bpe, ok := r.tok.(*bpeTokenizer); ok && bpe.cache != nil {
+		// Counting again next time is slower, but no worse.
+		if err := bpe.cache.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: writing token cache: %v\n", err)
+		}
+	}
+
+	if opts.countTokens {
+		fmt.Fprintf(os.Stderr, "Tokens: %d (%s)\n", r.tokenTotal(), r.tok)
+	}
+	if opts.stripLicense && !opts.quiet {
+		r.reportLicenses()
+	}
+	if len(r.extSkips) > 0 && !opts.quiet {
+		r.reportExtSkips()
+	}
+	if r.lineSkips > 0 && !opts.quiet {
+		fmt.Fprintf(os.Stderr, "Skipped %d %s after -max-total-lines %d was reached\n", r.lineSkips, plural(r.lineSkips, "file"), opts.maxTotalLines)
+	}
+	if r.untracked > 0 && !opts.quiet {
+		r.reportUntracked()
+	}
+	if r.oldCommits > 0 && !opts.quiet {
+		r.reportOldCommits()
+	}
+	if opts.recent != "" && !opts.quiet {
+		fmt.Fprintf(os.Stderr, "%d %s changed in the last %s\n", r.files, plural(r.files, "file"), opts.recent)
+	}
+	if opts.model != "" {
+		window := modelContextTokens[opts.model]
+		if tokens := r.tokenTotal(); tokens > window {
+			fmt.Fprintf(os.Stderr, "Warning: output is about %d tokens, more than the %d-token context window of %s\n", tokens, window, opts.model)
+		}
+	}
+	r.events.summary(r.files, len(r.skips), r.total, r.tokenTotal())
+	if opts.reportSkips && !opts.quiet {
+		r.reportSkips(os.Stderr)
+	}
+	if opts.summarizeSkips && !opts.quiet {
+		r.summarizeSkips()
+	}
+	if opts.stats {
+		r.stats.TotalTokens = r.tokenTotal()
+		w := os.Stderr
+		if opts.statsStdout {
+			w = os.Stdout
+		}
+		if err := r.stats.write(w, opts.statFormat, r.tok, opts.human); err != nil {
+			return fmt.Errorf("writing stats: %v", err)
+		}
+	}
+	if r.execFails > 0 {
+		// Like find -exec and xargs, finish the run but report failure.
+		return fmt.Errorf("-exec command failed for %d %s", r.execFails, plural(r.execFails, "file"))
+	}
+	return nil
+}
+
+// loadText returns the contents of the file named arg, or arg itself if no
+// such file exists, with a trailing newline added if it lacks one.
+func loadText(arg string) (string, error) {
+	text := arg
+	if info, err := os.Stat(arg); err == nil && info.Mode().IsRegular() {
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return "", err
+		}
+		text = string(data)
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text, nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// walkFiles calls processPath for each of the command-line files, and visit
+// directly for - (stdin) and -mixed-stdin text. If an argument can't be
+// processed, visit gets an entry with the error, so that it is counted like
+// any other failure, and the error is reported.
+func walkFiles(files *pathList, opts *options, visit func(fileEntry) error) {
+	walk := func(f string, info os.FileInfo) {
+		if text, ok := files.notes[f]; ok {
+			if err := visit(fileEntry{path: f, inline: text, top: true}); err != nil {
+				fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", f, err)
+			}
+			return
+		}
+		if f == "-" {
+			if err := visit(fileEntry{path: f, top: true}); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			}
+			return
+		}
+		if obj, ok := files.objects[f]; ok {
+			if err := visitObject(obj, opts, visit); err != nil {
+				if verr := visit(fileEntry{path: obj.Path, err: err, input: obj}); verr != nil {
+					fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", obj.Path, verr)
+				}
+			}
+			return
+		}
+		if err := processPath(f, info, opts, visit); err != nil {
+			if verr := visit(fileEntry{path: f, err: err}); verr != nil {
+				fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", f, verr)
+			}
+		}
+	}
+	var err error
+	if opts.statPrefetch > 0 {
+		err = prefetchStats(files, opts.statPrefetch, walk)
+	} else {
+		err = files.each(func(f string) { walk(f, nil) })
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+	}
+}
+
+// A pathList is the paths to dump: the command-line arguments or, if there
+// are none, the lines of stdin. Lines are read from stdin as the walk needs
+// them, so that a huge list starts printing at once and is never held in
+// memory whole, and so can only be walked once unless it is buffered.
+type pathList struct {
+	paths []string
+	stdin *bufio.Scanner // if set, read more paths from here, one per line
+
+	// With -mixed-stdin, only lines starting with @ are paths, and each run
+	// of other lines is text to print as it is. Each run is kept here under
+	// a made-up name, which stands in for it in the list.
+	mixed bool
+	notes map[string][]byte
+
+	// With -ndjson-input, each line is an object, kept here under a
+	// made-up name in the same way.
+	objects map[string]*inputObject
+}
+
+// each calls f for each path in l.
+func (l *pathList) each(f func(string)) error {
+	for _, p := range l.paths {
+		f(p)
+	}
+	if l.stdin == nil {
+		return nil
+	}
+	return l.scan(f)
+}
+
+// scan calls f for each path read from stdin.
+func (l *pathList) scan(f func(string)) error {
+	if l.objects != nil {
+		return l.scanObjects(f)
+	}
+	if !l.mixed {
+		for l.stdin.Scan() {
+			if p := strings.TrimSpace(l.stdin.Text()); p != "" {
+				f(p)
+			}
+		}
+		return l.stdin.Err()
+	}
+	var text []byte
+	flush := func() {
+		if len(bytes.TrimSpace(text)) > 0 {
+			name := fmt.Sprintf("<note %d>", len(l.notes)+1)
+			l.notes[name] = text
+			f(name)
+		}
+		text = nil
+	}
+	for l.stdin.Scan() {
+		line := l.stdin.Text()
+		p, ok := strings.CutPrefix(line, "@")
+		if !ok || strings.HasPrefix(p, "@") {
+			// @@ starts a line of text that begins with @.
+			text = append(append(text, strings.TrimPrefix(line, "@")...), '\n')
+			continue
+		}
+		flush()
+		if p = strings.TrimSpace(p); p != "" {
+			f(p)
+		}
+	}
+	flush()
+	return l.stdin.Err()
+}
+
+// peek reads the next path from stdin, if there is one, into l.paths.
+func (l *pathList) peek() error {
+	if l.stdin == nil {
+		return nil
+	}
+	if l.mixed {
+		// A run of text doesn't end until the next path.
+		return l.buffer()
+	}
+	for l.stdin.Scan() {
+		if p := strings.TrimSpace(l.stdin.Text()); p != "" {
+			l.paths = append(l.paths, p)
+			return nil
+		}
+	}
+	return l.stdin.Err()
+}
+
+// buffer reads the rest of the paths from stdin into l.paths, so that l can
+// be walked more than once.
+func (l *pathList) buffer() error {
+	if l.stdin == nil {
+		return nil
+	}
+	err := l.scan(func(p string) { l.paths = append(l.paths, p) })
+	l.stdin = nil
+	return err
+}
+
+// processPath selects path, or the files beneath it when recursing, and calls
+// visit for each one that passes the filters. A path may end in @N to recurse
+// into it N levels deep whatever -r and -depth say. info is what stat said
+// about path, if known; a path that stats as it is has no suffix.
+func processPath(path string, info os.FileInfo, opts *options, visit func(fileEntry) error) error {
+	if info == nil {
+		if path, depth, ok := splitDepth(path); ok {
+			return walkPath(path, nil, depth, true, opts, visit)
+		}
+	}
+	return walkPath(path, info, opts.depth, opts.recurse, opts, visit)
+}
+
+// splitDepth splits a depth suffix, as in src@2, off arg, returning the path
+// and the depth. It reports false if arg has no suffix, or if it names a file
+// that exists as it is.
+func splitDepth(arg string) (string, int, bool) {
+	i := strings.LastIndexByte(arg, '@')
+	if i <= 0 {
+		return arg, 0, false
+	}
+	depth, err := strconv.Atoi(arg[i+1:])
+	if err != nil || depth < 1 {
+		return arg, 0, false
+	}
+	if _, err := os.Lstat(arg); err == nil {
+		return arg, 0, false
+	}
+	return arg[:i], depth, true
+}
+
+// walkPath is processPath for a path without a depth suffix. Directories are
+// walked if recurse is set, for files at most depth levels below path (1 is
+// only the files directly in it, 0 means no limit). info is what stat said
+// about path, or nil to stat it now.
+func walkPath(path string, info os.FileInfo, depth int, recurse bool, opts *options, visit func(fileEntry) error) error {
+	if opts.ignoreSymlinks {
+		if li, err := os.Lstat(path); err == nil && li.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+	}
+	if info == nil {
+		var err error
+		if info, err = statFile(path); err != nil {
+			return err
+		}
+	}
+
+	if info.IsDir() {
+		if !recurse {
+			return fmt.Errorf("'%s' is a directory (use -r to recurse)", path)
+		}
+		// Symlinks named on the command line are followed, like any other
+		// argument. filepath.Walk lstats its root, though, so a root that
+		// is a symlink to a directory must end in a separator to be
+		// walked at all.
+		root := path
+		if li, err := os.Lstat(path); err == nil && li.Mode()&os.ModeSymlink != 0 {
+			root += string(filepath.Separator)
+		}
+		if opts.skipErrors {
+			// Report files that can't be read, and carry on.
+			next := visit
+			visit = func(e fileEntry) error {
+				if err := next(e); err != nil {
+					fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", e.path, err)
+				}
+				return nil
+			}
+		}
+		walk := filepath.Walk
+		if opts.fastWalk > 0 || opts.statPrefetch > 0 {
+			walk = func(root string, fn filepath.WalkFunc) error {
+				return fastWalk(root, opts.fastWalk, opts.statPrefetch, fn)
+			}
+		}
+		return walk(root, func(p string, i os.FileInfo, err error) error {
+			if err != nil {
+				if opts.skipErrors && p != root {
+					return visit(fileEntry{path: p, walked: true, err: err})
+				}
+				return err
+			}
+			// Hidden files and directories are only walked with -a;
+			// ones named as arguments are always used.
+			hidden := p != root && strings.HasPrefix(i.Name(), ".") && !opts.all
+			if i.IsDir() {
+				if p != root && (hidden || prunes(p, opts) || tooDeep(root, p, depth)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if opts.symlinkLoops && !hidden && i.Mode()&os.ModeSymlink != 0 {
+				// Reported whatever else is done with the link, which
+				// is never followed.
+				if to, ok := symlinkLoop(p); ok {
+					target, _ := os.Readlink(p)
+					fmt.Fprintf(os.Stderr, "Symlink loop: %s -> %s leads back to %s\n", p, target, to)
+				}
+			}
+			if hidden || !selects(p, opts) || !newEnough(i, opts) {
+				return nil
+			}
+			if i.Mode()&os.ModeSymlink != 0 {
+				// Symlinks found while walking are never followed,
+				// which also keeps loops and links to huge trees
+				// out of the walk.
+				if opts.ignoreSymlinks {
+					return nil
+				}
+				target, err := os.Readlink(p)
+				if err != nil {
+					return err
+				}
+				return visit(fileEntry{path: p, link: target, walked: true})
+			}
+			top := filepath.Dir(p) == filepath.Clean(root)
+			// i is from lstat, which says the same as stat for
+			// anything but a symlink.
+			return visit(fileEntry{path: p, size: i.Size(), walked: true, top: top, info: i})
+		})
+	}
+
+	if selects(path, opts) && newEnough(info, opts) {
+		return visit(fileEntry{path: path, size: info.Size(), top: true, info: info})
+	}
+	return nil
+}
+
+// tooDeep reports whether the files in dir, found while walking root, are
+// more than depth levels below it. A depth of 0 means no limit.
+func tooDeep(root, dir string, depth int) bool {
+	if depth == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	return strings.Count(rel, string(filepath.Separator))+1 >= depth
+}
+
+// emit prints a single selected file, reporting -events for it.
+func (r *runner) emit(e fileEntry) error {
+	name := e.path
+	if name == "-" {
+		name = r.opts.stdinName
+	}
+	r.events.start(name)
+	if r.queue != nil {
+		r.pre = <-r.queue
+		defer func() { r.pre = nil }()
+	}
+	defer func() { r.note = "" }()
+	skipped := len(r.skips)
+	n, err := r.emitFile(e)
+	if r.split != nil {
+		r.split.boundary() // an error is returned again by close
+	}
+	if r.chat != nil {
+		r.chat.boundary()
+	}
+	switch {
+	case err != nil:
+		reason := errorCategory(err)
+		switch {
+		case r.opts.errorMarkers:
+			// The file is still listed, so the output shows every
+			// file there is.
+			r.marker(name, "unreadable: "+errorText(err))
+		case r.opts.mergeNotices && !r.opts.quiet:
+			r.inlineNotice(name, reason)
+		}
+		r.skips = append(r.skips, skipRecord{name, reason})
+		r.events.skip(name, reason, err)
+	case len(r.skips) == skipped:
+		r.files++
+		r.events.done(name, n)
+		if r.opts.treeJSON {
+			r.tree = append(r.tree, treeFile{name, n})
+		}
+	}
+	return err
+}
+
+// skip reports on stderr that path is being left out, as "Skipping " and
+// the formatted message (or inline, with -merge-notices), and as a -events
+// skip event with reason. With an empty format, only the event is reported.
+func (r *runner) skip(path, reason, format string, args ...any) {
+	switch {
+	case format == "" || r.opts.quiet:
+	case r.opts.mergeNotices:
+		r.inlineNotice(path, reason)
+	case r.opts.summarizeSkips && !r.opts.verbose:
+	default:
+		fmt.Fprintf(os.Stderr, "Skipping "+format+"\n", args...)
+	}
+	r.skips = append(r.skips, skipRecord{path, reason})
+	r.events.skip(path, reason, nil)
+}
+
+// inlineNotice prints a -merge-notices marker for a skipped file in the
+// output, where its block would have been.
+func (r *runner) inlineNotice(path, reason string) {
+	r.marker(path, "skipped: "+reason)
+}
+
+// emitFile prints a single selected file, applying the limits that depend on
+// what has already been printed, and returns the number of content bytes
+// printed.
+func (r *runner) emitFile(e fileEntry) (int64, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.input != nil {
+		defer r.useObject(e.input, e.path)()
+	}
+	if e.link != "" && r.opts.derefFiles {
+		info, err := os.Stat(e.path)
+		switch {
+		case err != nil:
+			r.skip(e.path, "symlink", "broken symlink %s -> %s", e.path, e.link)
+			return 0, nil
+		case info.Mode().IsRegular():
+			r.linkTarget = e.link
+			defer func() { r.linkTarget = "" }()
+			if r.opts.xml || r.opts.frontMatter {
+				r.addNote("symlink to " + e.link)
+			}
+			e.link, e.size, e.info = "", info.Size(), info
+		}
+	}
+	if e.link != "" {
+		if r.opts.namesOnly {
+			fmt.Fprintf(r.out, "--- %s -> %s (symlink, not followed) ---\n", r.displayName(e.path), e.link)
+		} else {
+			r.skip(e.path, "symlink", "symlink %s -> %s (not followed)", e.path, e.link)
+		}
+		return 0, nil
+	}
+	if r.opts.trackedOnly && e.path != "-" && e.inline == nil {
+		tracked, err := r.tracked(e.path)
+		if err != nil {
+			return 0, err
+		}
+		if !tracked {
+			r.untracked++
+			r.skip(e.path, "untracked", "")
+			return 0, nil
+		}
+	}
+	if !r.opts.newerCommit.IsZero() && e.path != "-" && e.inline == nil {
+		recent, err := r.committedRecently(e.path)
+		if err != nil {
+			return 0, err
+		}
+		if !recent {
+			r.oldCommits++
+			r.skip(e.path, "old-commit", "")
+			return 0, nil
+		}
+	}
+	limit := r.opts.maxSize
+	if r.opts.totalMax > 0 && !r.measuring {
+		remaining := r.opts.totalMax - r.total
+		if remaining <= 0 {
+			r.skip(e.path, "total-limit", "%s (total limit %s reached)", e.path, r.size(r.opts.totalMax))
+			return 0, nil
+		}
+		if limit == 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+
+	if r.opts.dropOver > 0 {
+		if limit := int64(r.opts.dropOver * float64(r.opts.totalMax)); e.size > limit {
+			r.skip(e.path, "drop-over", "%s (size %s is over %g of the total limit)", e.path, r.size(e.size), r.opts.dropOver)
+			return 0, nil
+		}
+	}
+
+	if r.opts.maxTokens > 0 && r.tokens >= r.opts.maxTokens && !r.measuring {
+		r.skip(e.path, "token-limit", "%s (token limit %d reached)", e.path, r.opts.maxTokens)
+		return 0, nil
+	}
+
+	if r.opts.maxTotalLines > 0 && !r.measuring {
+		remaining := r.opts.maxTotalLines - r.stats.TotalLines
+		if remaining <= 0 {
+			// Reported as a count at the end, rather than file by file.
+			r.skip(e.path, "line-limit", "")
+			r.lineSkips++
+			return 0, nil
+		}
+		// The file gets what is left of the lines, as if by -max-lines.
+		if r.opts.maxLines == 0 || remaining < int64(r.opts.maxLines) {
+			saved := r.opts
+			capped := *saved
+			capped.maxLines = int(remaining)
+			r.opts = &capped
+			defer func() { r.opts = saved }()
+		}
+	}
+
+	var dir string
+	if e.walked {
+		dir = filepath.Dir(e.path)
+		if r.opts.dirContext {
+			r.contextDir = dir
+			defer func() { r.contextDir = "" }()
+		}
+		if r.opts.perDirMax > 0 && r.dirBytes[dir]+e.size > r.opts.perDirMax {
+			r.skip(e.path, "per-dir-limit", "%s (would bring directory %s from %s to %s, over -per-dir-max %s)", e.path, dir, r.size(r.dirBytes[dir]), r.size(r.dirBytes[dir]+e.size), r.size(r.opts.perDirMax))
+			return 0, nil
+		}
+		if r.opts.maxDirFiles > 0 && r.dirFiles[dir] >= r.opts.maxDirFiles {
+			// Only the first file left out of a directory gets a
+			// note, unless -v is set.
+			if r.dirSkips[dir] == 0 {
+				r.skip(e.path, "dir-file-limit", "%s and the rest of %s (-max-files-per-dir %d reached)", e.path, dir, r.opts.maxDirFiles)
+			} else if r.opts.verbose {
+				r.skip(e.path, "dir-file-limit", "%s (-max-files-per-dir %d reached)", e.path, r.opts.maxDirFiles)
+			} else {
+				r.skip(e.path, "dir-file-limit", "")
+			}
+			r.dirSkips[dir]++
+			return 0, nil
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(e.path))
+	if r.opts.maxPerExt > 0 && r.extFiles[ext] >= r.opts.maxPerExt {
+		// Reported as a count at the end, rather than file by file.
+		r.skip(e.path, "ext-limit", "")
+		r.extSkips[ext]++
+		return 0, nil
+	}
+	if limit, ok := r.opts.extMax[ext]; ok && r.extBytes[ext]+e.size > limit {
+		r.skip(e.path, "ext-bytes-limit", "%s (extension %s reached -max-bytes-ext limit %s)", e.path, ext, r.size(limit))
+		return 0, nil
+	}
+
+	skipped := len(r.skips)
+	var n int64
+	var err error
+	switch {
+	case e.inline != nil:
+		n, err = r.printContents(e.path, noteReader{bytes.NewReader(e.inline)}, limit)
+	case e.path == "-":
+		n, err = r.handleStdin(limit)
+	default:
+		n, err = r.handleFile(e.path, e.info, limit)
+	}
+	r.total += n
+	if e.walked {
+		r.dirBytes[dir] += n
+	}
+	r.extBytes[ext] += n
+	if err == nil && len(r.skips) == skipped {
+		r.extFiles[ext]++
+		if e.walked {
+			r.dirFiles[dir]++
+		}
+	}
+	return n, err
+}
+
+// groupByLanguage reorders files into one section per language, in the order
+// each language first appears, keeping their relative order within a
+// section. It also returns the heading for each section, by the index of its
+// first file.
+func (r *runner) groupByLanguage(files []fileEntry) ([]fileEntry, map[int]string) {
+	var order []string
+	groups := make(map[string][]fileEntry)
+	for _, e := range files {
+		name := e.path
+		if name == "-" {
+			name = r.opts.stdinName
+		}
+		var g string
+		if e.path == "-" {
+			// Stdin can't be read twice, so go by the name alone.
+			g = detectLanguage(name, nil).name
+		} else {
+			g = fileLanguage(name).name
+		}
+		if _, ok := groups[g]; !ok {
+			order = append(order, g)
+		}
+		groups[g] = append(groups[g], e)
+	}
+
+	sorted := make([]fileEntry, 0, len(files))
+	headings := make(map[int]string)
+	for _, g := range order {
+		headings[len(sorted)] = g
+		sorted = append(sorted, groups[g]...)
+	}
+	return sorted, headings
+}
+
+// docsFirst moves the top-level documentation in files (READMEs, licenses
+// and Markdown files, named as arguments or directly inside a directory
+// argument) ahead of everything else, keeping the order within each part.
+func docsFirst(files []fileEntry) []fileEntry {
+	var docs, rest []fileEntry
+	for _, e := range files {
+		if e.top && e.path != "-" && isDoc(e.path) {
+			docs = append(docs, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	return append(docs, rest...)
+}
+
+// readmeFirst moves each README in files ahead of the other files in its
+// directory, including those in its subdirectories, so that it is read
+// first on the way down. Nothing moves from one directory to another, and
+// the order is otherwise kept.
+func readmeFirst(files []fileEntry) []fileEntry {
+	in := func(e fileEntry, dir string) bool {
+		return e.path != "-" && e.inline == nil && (dir == "." || strings.HasPrefix(e.path, dir+string(filepath.Separator)))
+	}
+	// ahead[i] holds the READMEs to move ahead of files[i].
+	ahead := make(map[int][]fileEntry)
+	moved := make(map[int]bool)
+	for i, e := range files {
+		if e.err != nil || !in(e, filepath.Dir(e.path)) || !strings.HasPrefix(strings.ToUpper(filepath.Base(e.path)), "README") {
+			continue
+		}
+		dir := filepath.Dir(e.path)
+		first := i
+		for first > 0 && in(files[first-1], dir) {
+			first--
+		}
+		if first < i {
+			ahead[first] = append(ahead[first], e)
+			moved[i] = true
+		}
+	}
+	if len(moved) == 0 {
+		return files
+	}
+	depth := func(e fileEntry) int { return strings.Count(e.path, string(filepath.Separator)) }
+	sorted := make([]fileEntry, 0, len(files))
+	for i, e := range files {
+		// A directory's README goes ahead of those of its subdirectories.
+		slices.SortStableFunc(ahead[i], func(a, b fileEntry) int { return depth(a) - depth(b) })
+		sorted = append(sorted, ahead[i]...)
+		if !moved[i] {
+			sorted = append(sorted, e)
+		}
+	}
+	return sorted
+}
+
+// sampleFiles returns -sample files chosen at random from files, in their
+// original order. Entries for paths that couldn't be walked are kept, so
+// that they are still reported.
+func (r *runner) sampleFiles(files []fileEntry) []fileEntry {
+	var candidates []int
+	for i, e := range files {
+		if e.err == nil {
+			candidates = append(candidates, i)
+		}
+	}
+	n := r.opts.sample
+	if n >= len(candidates) {
+		return files
+	}
+	if !r.opts.quiet {
+		fmt.Fprintf(os.Stderr, "Sampling %d of %d files (-seed %d)\n", n, len(candidates), r.opts.seed)
+	}
+	rng := rand.New(rand.NewSource(r.opts.seed))
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	drop := make(map[int]bool)
+	for _, i := range candidates[n:] {
+		drop[i] = true
+	}
+	var sampled []fileEntry
+	for i, e := range files {
+		if !drop[i] {
+			sampled = append(sampled, e)
+		}
+	}
+	return sampled
+}
 
-	if *help {
-		showHelp()
-		return
+// orderFiles moves the files that match one of patterns ahead of the rest:
+// first those matching the first pattern, then the second, and so on, each
+// file going with the first pattern it matches. Order within each part is
+// kept. Patterns that match nothing are reported.
+func orderFiles(files []fileEntry, patterns []string) []fileEntry {
+	parts := make([][]fileEntry, len(patterns)+1)
+	for _, e := range files {
+		i := 0
+		for i < len(patterns) && (e.path == "-" || !matchesAny(e.path, patterns[i:i+1])) {
+			i++
+		}
+		parts[i] = append(parts[i], e)
+	}
+	ordered := make([]fileEntry, 0, len(files))
+	for i, part := range parts {
+		if i < len(patterns) && len(part) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: -order-file pattern %q matched no files\n", patterns[i])
+		}
+		ordered = append(ordered, part...)
+	}
+	return ordered
+}
+
+// modeNote describes mode for -show-mode, as "0755, executable".
+func modeNote(mode os.FileMode) string {
+	note := fmt.Sprintf("%04o", mode.Perm())
+	if mode&0o111 != 0 {
+		note += ", executable"
+	}
+	return note
+}
+
+// fenceLanguage returns the language to name in the code fence or front
+// matter for name: the one an -ndjson-input object gives, or the one
+// detected from its name and sample.
+func (r *runner) fenceLanguage(name string, sample []byte) string {
+	if r.opts.lang != "" {
+		return r.opts.lang
+	}
+	return detectLanguage(name, sample).fence
+}
+
+// addNote adds note to the notes on the file being printed.
+func (r *runner) addNote(note string) {
+	if r.note != "" {
+		r.note += "; "
+	}
+	r.note += note
+}
+
+// countHeader returns the -count-header line for the files printed.
+func (r *runner) countHeader() string {
+	count := func(n int64, word string) string {
+		return thousands(int(n)) + " " + plural(int(n), word)
+	}
+	approx := "~"
+	if _, exact := r.tok.(*bpeTokenizer); exact {
+		approx = ""
+	}
+	return fmt.Sprintf("# %s, %s, %s%s", count(int64(r.files), "file"), count(r.stats.TotalLines, "line"), approx, count(r.tokenTotal(), "token"))
+}
+
+// plural returns word, with an s added unless n is 1.
+func plural(n int, word string) string {
+	if n != 1 {
+		word += "s"
+	}
+	return word
+}
+
+// thousands formats n with commas between groups of three digits.
+func thousands(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0 && s[i-1] != '-'; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// isDoc reports whether path names a README, a license or a Markdown file.
+func isDoc(path string) bool {
+	base := strings.ToUpper(filepath.Base(path))
+	for _, prefix := range []string{"README", "LICENSE", "LICENCE"} {
+		if strings.HasPrefix(base, prefix) {
+			return true
+		}
 	}
+	return filepath.Ext(base) == ".MD"
+}
 
-	files := flag.Args()
-	if len(files) == 0 {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			if f := strings.TrimSpace(scanner.Text()); f != "" {
-				files = append(files, f)
+// handleFile prints a single file, copying no more than limit bytes of it
+// (0 = unlimited), and returns the number of content bytes written. info is
+// what stat said about path while walking, or nil to stat it now.
+func (r *runner) handleFile(path string, info os.FileInfo, limit int64) (int64, error) {
+	opts := r.opts
+	if r.changes != nil {
+		if _, ok := r.changes.lookup(path); !ok {
+			r.skip(path, "unchanged", "")
+			return 0, nil
+		}
+	}
+	listing := opts.namesOnly || opts.exec != nil
+	if opts.grep != nil && listing {
+		ok, err := fileMatches(path, opts.grep)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			r.skip(path, "no-match", "")
+			return 0, nil
+		}
+	}
+	if opts.skipMatching != nil && listing {
+		ok, err := fileMatches(path, opts.skipMatching)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			r.skip(path, "skip-matching", "%s (matches -skip-matching)", path)
+			return 0, nil
+		}
+	}
+	if listing {
+		line := r.displayName(path)
+		if opts.mime != "" || opts.showMIME {
+			mime := fileMIME(path)
+			if opts.mime != "" && !mimeMatches(mime, opts.mime) {
+				r.skip(path, "no-match", "")
+				return 0, nil
+			}
+			if opts.showMIME && mime != "" {
+				line += "  [" + mime + "]"
 			}
 		}
-		if err := scanner.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
-			os.Exit(1)
+		if opts.langFilter != nil && !r.wantLanguage(path, fileLanguage(path)) {
+			r.skip(path, "no-match", "")
+			return 0, nil
+		}
+		if opts.exec != nil {
+			// -exec runs only on the files a dump would have printed.
+			if skipped, err := r.execSkipped(path); err != nil || skipped {
+				return 0, err
+			}
+			if err := runExec(path, opts.exec, r.out); err != nil {
+				fmt.Fprintf(os.Stderr, "Command failed for %s: %v\n", path, err)
+				r.execFails++
+			}
+			return 0, nil
+		}
+		if opts.binaryMarker && r.binaryFile(path) {
+			line += "  [binary]"
+		}
+		fmt.Fprintln(r.out, line)
+		return 0, nil
+	}
+
+	if info == nil {
+		var err error
+		if info, err = os.Stat(path); err != nil {
+			return 0, err
+		}
+	}
+	if !info.Mode().IsRegular() {
+		// Pipes, devices and sockets can block forever or never end.
+		if info.Mode()&os.ModeNamedPipe != 0 && opts.allowFIFO {
+			return r.handleFIFO(path, limit)
+		}
+		r.skip(path, "special-file", "%s (%s, not a regular file)", path, fileKind(info.Mode()))
+		return 0, nil
+	}
+	if opts.showMode {
+		r.addNote(modeNote(info.Mode()))
+	}
+	if opts.submoduleNotes {
+		if sub := submoduleOf(path, opts.verbose); sub != "" {
+			r.addNote("submodule " + r.displayName(sub))
+		}
+	}
+	if opts.maxSize > 0 && info.Size() > opts.maxSize {
+		if opts.chunk {
+			return r.printChunks(path)
+		}
+		r.skip(path, "too-large", "%s (size %s exceeds limit %s)", path, r.size(info.Size()), r.size(opts.maxSize))
+		return 0, nil
+	}
+
+	if opts.blame {
+		defer r.useBlame(path)()
+	}
+	if p := r.pre; p != nil && p.wait() {
+		return r.printContents(path, p, limit)
+	}
+	if opts.retry > 0 {
+		// Read the whole file first, so that a failed read can be tried
+		// again before any of it is printed.
+		data, err := r.readFile(path)
+		if err != nil {
+			return 0, err
 		}
+		return r.printContents(path, bytes.NewReader(data), limit)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
 	}
+	defer file.Close()
 
-	for _, f := range files {
-		if err := processPath(f, *recurse, *extension, *namesOnly, *maxSize); err != nil {
-			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", f, err)
+	if opts.mmap && info.Size() >= mmapThreshold {
+		size := info.Size()
+		if limit > 0 {
+			size = min(size, limit)
+		}
+		if data, unmap, err := mmapFile(file, size); err == nil {
+			defer unmap()
+			if opts.verbose {
+				fmt.Fprintf(os.Stderr, "Mapped %s\n", r.displayName(path))
+			}
+			return r.printMapped(path, file, data, limit)
 		}
+		// Otherwise fall back to reading the file normally.
 	}
+	return r.printContents(path, file, limit)
 }
 
-func processPath(path string, recurse bool, extension string, namesOnly bool, maxSize int64) error {
-	info, err := os.Stat(path)
+// A mapping reads the memory-mapped start of file. Its bytes.Reader lets the
+// binary check sample the mapping and io.Copy write the rest of it in a
+// single call.
+type mapping struct {
+	*bytes.Reader
+	file *os.File
+}
+
+// grown reports whether m's file is now longer than the part of it mapped.
+func (m *mapping) grown() bool {
+	info, err := m.file.Stat()
+	return err == nil && info.Size() > m.Size()
+}
+
+// printMapped prints data, the first bytes of file mapped into memory, as
+// printContents does. If file is truncated meanwhile, reading a mapped page
+// past its new end faults; that is reported as an error instead of crashing
+// the process.
+func (r *runner) printMapped(path string, file *os.File, data []byte, limit int64) (written int64, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if e := recover(); e != nil {
+			if _, fault := e.(interface{ Addr() uintptr }); !fault {
+				panic(e)
+			}
+			err = fmt.Errorf("%s was truncated while it was being read", path)
+		}
+	}()
+	return r.printContents(path, &mapping{Reader: bytes.NewReader(data), file: file}, limit)
+}
+
+// A noteReader reads -mixed-stdin text.
+type noteReader struct{ *bytes.Reader }
+
+// fromFile reports whether in reads a file, rather than stdin or -mixed-stdin
+// text.
+func fromFile(in io.Reader) bool {
+	_, note := in.(noteReader)
+	return in != io.Reader(os.Stdin) && !note
+}
+
+// handleStdin prints the contents of standard input as if it were a file
+// named -stdin-name.
+func (r *runner) handleStdin(limit int64) (int64, error) {
+	return r.printContents(r.opts.stdinName, os.Stdin, limit)
+}
+
+// printContents prints in under a header for name, unless it looks binary.
+// No more than limit bytes are copied (0 = unlimited), even if in turns out to
+// be longer than a size check made earlier suggested. It returns the number
+// of content bytes written.
+func (r *runner) printContents(name string, in io.Reader, limit int64) (int64, error) {
+	opts := r.opts
+	// Work the pipeline did on the whole file only applies if all of it is
+	// going to be printed.
+	pre, _ := in.(*prepared)
+	if pre != nil && limit > 0 && int64(len(pre.data)) > limit {
+		pre = nil
+	}
+	src := in
+	// A mapping is never longer than limit, and a LimitReader would hide
+	// its WriteTo method.
+	if m, ok := in.(*mapping); limit > 0 && !(ok && m.Size() <= limit) {
+		src = io.LimitReader(in, limit)
+	}
+
+	sample, err := readSample(src, opts.sampleSize)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if opts.mime != "" && !mimeMatches(sniffMIME(sample), opts.mime) {
+		r.skip(name, "no-match", "")
+		return 0, nil
+	}
+	if opts.langFilter != nil && !r.wantLanguage(name, detectLanguage(name, sample)) {
+		r.skip(name, "no-match", "")
+		return 0, nil
+	}
+	// Outputs embedded in a notebook can make it look binary, but they
+	// aren't printed.
+	if r.binary(name, sample) && !r.rendersNotebook(name) && !r.rendersPDF(name) {
+		if r.zip != nil {
+			// Archives hold binary files as they are.
+			return r.writeZip(name, io.MultiReader(bytes.NewReader(sample), src), true)
+		}
+		if opts.imagePlaces && opts.outputDir == "" {
+			if ok, n, err := r.printImagePlaceholder(name, sample, src); ok {
+				return n, err
+			}
+		}
+		if opts.base64Binary && opts.outputDir == "" {
+			return r.printBase64(name, sample, src)
+		}
+		r.skip(name, "binary", "binary file %s", name)
+		return 0, nil
+	}
+	if opts.skipMinified && !r.rendersPDF(name) && minified(name, sample) {
+		r.skip(name, "minified", "minified file %s", name)
+		return 0, nil
 	}
 
-	if info.IsDir() {
-		if !recurse {
-			return fmt.Errorf("'%s' is a directory (use -r to recurse)", path)
+	// Continue with the bytes we already sampled so that unseekable
+	// input works too.
+	body := io.MultiReader(bytes.NewReader(sample), src)
+	// Stdin and -mixed-stdin text have no counterpart under -diff-against
+	// or in git.
+	diffing := opts.diffAgainst != "" && fromFile(in)
+	changing := r.changes != nil && fromFile(in)
+	ranged := opts.ranges[name] != nil && in != io.Reader(os.Stdin)
+	if ranged || opts.requireUTF8 || r.incr != nil || r.countingTokens() || opts.grep != nil || opts.skipMatching != nil || opts.dedupe || diffing || changing || r.reformatsJSON(name) || r.rendersNotebook(name) || r.rendersPDF(name) || opts.stripLicense || opts.headerTokens || opts.warnTokens > 0 {
+		// The whole file has to be seen before any of it is printed.
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return 0, err
+		}
+		if opts.requireUTF8 {
+			cut := limit > 0 && int64(len(data)) == limit
+			if off := invalidUTF8(data, cut); off >= 0 {
+				r.skip(name, "invalid-utf8", "%s (not valid UTF-8 at byte %d)", name, off)
+				return 0, nil
+			}
+		}
+		if r.rendersNotebook(name) {
+			var restore func()
+			data, restore = r.renderNotebook(name, data)
+			defer restore()
+			pre = nil
+		}
+		if r.rendersPDF(name) {
+			if data, err = pdfText(data); err != nil {
+				return 0, err
+			}
+			if len(bytes.TrimSpace(data)) == 0 {
+				r.skip(name, "no-text", "%s (no text in the PDF; it may be scanned)", name)
+				return 0, nil
+			}
+			pre = nil
+		}
+		if opts.skipMatching != nil && opts.skipMatching.Match(data) {
+			r.skip(name, "skip-matching", "%s (matches -skip-matching)", name)
+			return 0, nil
+		}
+		if ranged {
+			data = r.cutLines(name, data)
+			pre = nil
+		}
+		if opts.grep != nil {
+			if !opts.grep.Match(data) {
+				r.skip(name, "no-match", "")
+				return 0, nil
+			}
+			if opts.grepContext >= 0 {
+				data = grepWindows(data, opts.grep, opts.grepContext, opts.firstMatch)
+				pre = nil
+			}
 		}
-		return filepath.Walk(path, func(p string, i os.FileInfo, err error) error {
+		if diffing {
+			out, differs, err := r.compare(name, data)
 			if err != nil {
-				return err
+				return 0, err
 			}
-			if !i.IsDir() && matchesExtension(p, extension) {
-				return handleFile(p, namesOnly, maxSize)
+			if !differs {
+				r.skip(name, "identical", "")
+				return 0, nil
 			}
-			return nil
-		})
+			if opts.diff {
+				pre = nil
+			}
+			data = out
+		}
+		if changing {
+			out, changed, err := r.changedContext(name, data)
+			if err != nil {
+				return 0, err
+			}
+			if !changed {
+				r.skip(name, "unchanged", "")
+				return 0, nil
+			}
+			data = out
+			pre = nil
+		}
+		if r.incr != nil && r.incr.record(name+r.part, data) && opts.outputDir == "" && r.zip == nil {
+			r.marker(name, "unchanged")
+			return 0, nil
+		}
+		if opts.dedupe {
+			sum := sha256.Sum256(data)
+			if first, ok := r.seen[sum]; ok {
+				r.marker(name, "identical to "+first)
+				return 0, nil
+			}
+			r.seen[sum] = r.displayName(name) + r.part
+		}
+		if r.reformatsJSON(name) && !(diffing && opts.diff) {
+			data = r.reformatJSON(name, data)
+			pre = nil
+		}
+		if opts.stripLicense && !(diffing && opts.diff) && !changing {
+			if stripped := r.stripLicense(data); len(stripped) != len(data) {
+				data = stripped
+				pre = nil
+			}
+		}
+		if opts.maxFileTokens > 0 {
+			total := r.tok.count(data)
+			if pre != nil {
+				total = pre.tokens
+			}
+			if total > opts.maxFileTokens {
+				n, kept := r.tok.cut(data, opts.maxFileTokens)
+				data = data[:n:n]
+				if n > 0 && data[n-1] != '\n' {
+					data = append(data, '\n')
+				}
+				data = fmt.Appendf(data, "... [truncated, %d more tokens]\n", total-kept)
+				pre = nil
+			}
+		}
+		switch remaining := opts.maxTokens - r.tokens; {
+		case pre != nil && (opts.maxTokens == 0 || r.measuring || int64(pre.tokens) <= remaining):
+			r.tokens += int64(pre.tokens)
+		case opts.maxTokens > 0 && !r.measuring:
+			n, tokens := r.tok.cut(data, int(remaining))
+			if n < len(data) {
+				fmt.Fprintf(os.Stderr, "Truncated %s at %s (token limit %d)\n", name, r.size(int64(n)), opts.maxTokens)
+				data = data[:n]
+				pre = nil
+			}
+			r.tokens += int64(tokens)
+		case opts.countTokens || r.measuring:
+			r.tokens += int64(r.tok.count(data))
+		}
+		if opts.headerTokens || opts.warnTokens > 0 {
+			n := r.tok.count(data)
+			if opts.headerTokens {
+				r.addNote(thousands(n) + " tokens")
+			}
+			if opts.warnTokens > 0 && n > opts.warnTokens && !opts.quiet && !r.measuring {
+				fmt.Fprintf(os.Stderr, "Warning: %s is %s tokens, over -warn-tokens %d\n", name, thousands(n), opts.warnTokens)
+			}
+		}
+		body = bytes.NewReader(data)
+	}
+	if pre != nil && pre.filtered != nil {
+		body = &prefiltered{Reader: bytes.NewReader(pre.filtered), n: int64(len(pre.data))}
+	}
+	if opts.reindent > 0 {
+		if why := reindentUnsafe(name); why != "" {
+			fmt.Fprintf(os.Stderr, "Not reindenting %s: %s\n", name, why)
+		}
+	}
+	var written int64
+	switch {
+	case r.capture != nil:
+		written, err = r.printBlock(name, sample, body)
+	case r.zip != nil:
+		written, err = r.writeZip(name, body, false)
+	case opts.outputDir != "":
+		written, err = writeMirror(name, body, opts)
+	default:
+		written, err = r.printBlock(name, sample, body)
+	}
+	if err != nil {
+		return written, err
+	}
+	if limit > 0 && written == limit && pre == nil {
+		// The file may have grown since it was stat'ed, or it is a stream
+		// with no known size; see if anything was left unprinted.
+		grown := false
+		if m, ok := in.(*mapping); ok {
+			grown = m.grown()
+		} else if n, _ := in.Read(make([]byte, 1)); n > 0 {
+			grown = true
+		}
+		if grown {
+			fmt.Fprintf(os.Stderr, "Truncated %s at %s (limit %s)\n", name, r.size(written), r.size(limit))
+		}
 	}
+	return written, nil
+}
 
-	if matchesExtension(path, extension) {
-		return handleFile(path, namesOnly, maxSize)
+// displayName returns name as it should appear in the output, relative to
+// its git work tree under -git-root-paths and with forward slashes unless
+// -path-style is native.
+func (r *runner) displayName(name string) string {
+	if r.opts.gitRootPaths {
+		name = r.gitRootPath(name)
 	}
-	return nil
+	if r.opts.posixPaths {
+		return filepath.ToSlash(name)
+	}
+	return name
 }
 
-func handleFile(path string, namesOnly bool, maxSize int64) error {
-	if namesOnly {
-		fmt.Println(path)
-		return nil
+// marker prints a note in place of the contents of name, such as why they
+// were left out.
+func (r *runner) marker(name, note string) {
+	r.flushSmall()
+	if r.opts.xml {
+		fmt.Fprintf(r.out, "\n<file %s note=\"%s\"/>\n", r.xmlAttrs(name), xmlEscape(note))
+		return
+	}
+	if r.opts.frontMatter {
+		r.frontMatterMarker(name, note)
+		return
+	}
+	r.blocks++
+	fmt.Fprintf(r.out, "\n--- %s%s (%s) ---\n", r.displayName(name), r.part, note)
+}
+
+// xmlAttrs returns the attributes identifying name (and the part being
+// printed, with -chunk) in -xml output.
+func (r *runner) xmlAttrs(name string) string {
+	attrs := fmt.Sprintf("path=\"%s\"", xmlEscape(r.displayName(name)))
+	if r.part != "" {
+		part := strings.TrimSuffix(strings.TrimPrefix(r.part, " (part "), ")")
+		attrs += fmt.Sprintf(" part=\"%s\"", part)
+	}
+	if code := r.gitStatusOf(name); code != "" {
+		attrs += fmt.Sprintf(" git-status=\"%s\"", xmlEscape(code))
+	}
+	if r.note != "" {
+		attrs += fmt.Sprintf(" note=\"%s\"", xmlEscape(r.note))
 	}
+	return attrs
+}
 
-	info, err := os.Stat(path)
+// xmlEscape escapes s for use in an XML attribute value. Newlines, tabs and
+// carriage returns become character references, so that a path containing
+// them survives attribute value normalization.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// printBlock prints body between the delimiters for name. sample is the
+// start of body, used to pick the language and code fence for -md; it is nil
+// if body isn't the file's contents as they are.
+func (r *runner) printBlock(name string, sample []byte, body io.Reader) (int64, error) {
+	opts := r.opts
+	if r.capture != nil {
+		r.captured = true
+		return copyContents(r.capture, body, name, opts)
+	}
+	if opts.frontMatter {
+		return r.printFrontMatter(name, sample, body)
+	}
+	r.printDirContext(name)
+	label := r.displayName(name) + r.part
+	if opts.numbered {
+		label = r.numberBlock(name) + label
+	}
+	if r.linkTarget != "" {
+		label += " -> " + r.linkTarget
+	}
+	if r.note != "" {
+		label += " (" + r.note + ")"
+	}
+	if code := r.gitStatusOf(name); code != "" {
+		label += " [" + code + "]"
+	}
+	if opts.htmlEscape {
+		label = html.EscapeString(label)
+	}
+	if r.mergeable(sample) {
+		return r.hold(name, label, sample, body)
+	}
+	r.flushSmall()
+	start := r.offset()
+	r.blocks++
+	var fence string
+	switch {
+	case opts.xml:
+		fmt.Fprintf(r.out, "\n<file %s>\n", r.xmlAttrs(name))
+	case opts.markdown:
+		fence = codeFence(sample)
+		fmt.Fprintf(r.out, "%s%s%s\n", r.smartHeader("\n### "+label+"\n\n"), fence, r.fenceLanguage(name, sample))
+	default:
+		fmt.Fprint(r.out, r.smartHeader("\n--- "+label+" ---\n"))
+	}
+	out := &lastByteWriter{w: r.out, kinds: r.lineKindsFor(name, sample)}
+	written, err := copyContents(out, body, name, opts)
+	r.stats.add(name, out.n, out.lineCount())
+	r.stats.addKinds(name, out.kinds)
 	if err != nil {
-		return err
+		return written, err
 	}
-	if maxSize > 0 && info.Size() > maxSize {
-		fmt.Fprintf(os.Stderr, "Skipping %s (size %d bytes exceeds limit %d)\n", path, info.Size(), maxSize)
-		return nil
+	r.closeBlock(written > 0 && out.last != '\n', fence)
+	r.indexBlock(name, start)
+	return written, nil
+}
+
+// closeBlock prints the end of a block opened by printBlock, first ending
+// its last line if it is unterminated and the format needs it.
+func (r *runner) closeBlock(unterminated bool, fence string) {
+	switch {
+	case r.opts.xml:
+		if unterminated {
+			fmt.Fprintln(r.out)
+		}
+		fmt.Fprintln(r.out, "</file>")
+	case r.opts.markdown:
+		if unterminated {
+			fmt.Fprintln(r.out)
+		}
+		fmt.Fprintln(r.out, fence)
+	default:
+		fmt.Fprint(r.out, r.smartHeader("\n"))
 	}
+}
 
-	file, err := os.Open(path)
+// copyContents copies body to dst through any filters opts asks for, and
+// returns the number of bytes read from body.
+func copyContents(dst io.Writer, body io.Reader, name string, opts *options) (int64, error) {
+	if p, ok := body.(*prefiltered); ok {
+		_, err := io.Copy(dst, p)
+		return p.n, err
+	}
+	if filters := buildFilters(name, opts); len(filters) > 0 {
+		return copyLines(dst, body, filters)
+	}
+	if opts.bufferSize > 0 {
+		body = &chunkReader{r: body, buf: make([]byte, opts.bufferSize)}
+	}
+	// Leave io.Copy free to use a WriterTo or ReaderFrom: a mapped file
+	// goes out in one write, and file-to-file copies stay in the kernel.
+	return io.Copy(dst, body)
+}
+
+// A chunkReader reads from r len(buf) bytes at a time for -buffer-size. Its
+// WriteTo method makes io.Copy use buf rather than a buffer of dst's
+// choosing, without hiding anything dst can do with the data.
+type chunkReader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	return c.r.Read(p[:min(len(p), len(c.buf))])
+}
+
+func (c *chunkReader) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for {
+		n, err := c.r.Read(c.buf)
+		if n > 0 {
+			m, werr := w.Write(c.buf[:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// invalidUTF8 returns the offset of the first invalid UTF-8 sequence in data,
+// or -1 if there is none. If truncated is set, data was cut off at a size
+// limit, so an incomplete sequence at the very end is not counted.
+func invalidUTF8(data []byte, truncated bool) int {
+	for i := 0; i < len(data); {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			if truncated && !utf8.FullRune(data[i:]) {
+				return -1
+			}
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// codeFence returns a Markdown code fence long enough not to be closed by a
+// run of backticks in sample.
+func codeFence(sample []byte) string {
+	longest, run := 0, 0
+	for _, b := range sample {
+		if b == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// lastByteWriter remembers the last byte written through it, and counts the
+// bytes and lines.
+type lastByteWriter struct {
+	w     io.Writer
+	last  byte
+	n     int64
+	lines int64      // complete lines only; see lineCount
+	kinds *lineKinds // if set, counts the lines by kind too
+}
+
+func (l *lastByteWriter) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	if n > 0 {
+		l.last = p[n-1]
+		l.n += int64(n)
+		l.lines += int64(bytes.Count(p[:n], []byte("\n")))
+		if l.kinds != nil {
+			l.kinds.count(p[:n])
+		}
+	}
+	return n, err
+}
+
+// lineCount returns the number of lines written, including a last one with
+// no newline.
+func (l *lastByteWriter) lineCount() int64 {
+	if l.n > 0 && l.last != '\n' {
+		return l.lines + 1
+	}
+	return l.lines
+}
+
+// runExec runs the -exec command for path, with its output going to out.
+// Every {} in the arguments is replaced by path; if there is none, path is
+// appended as the last argument. The caller reports failures, which do not
+// stop the traversal, as with find -exec.
+func runExec(path string, command []string, out io.Writer) error {
+	args := make([]string, 0, len(command)+1)
+	substituted := false
+	for _, a := range command {
+		if strings.Contains(a, "{}") {
+			a = strings.ReplaceAll(a, "{}", path)
+			substituted = true
+		}
+		args = append(args, a)
+	}
+	if !substituted {
+		args = append(args, path)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// binaryFile reports whether path is a regular file that a dump would skip
+// as binary.
+func (r *runner) binaryFile(path string) bool {
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	f, err := os.Open(path)
 	if err != nil {
-		return err
+		return false
 	}
-	defer file.Close()
+	defer f.Close()
+	sample, err := readSample(f, r.opts.sampleSize)
+	return err == nil && r.binary(path, sample)
+}
 
-	// Detect binary by sampling first 8 kB
-	const sampleSize = 8 << 10
-	sample := make([]byte, sampleSize)
-	n, _ := io.ReadFull(file, sample)
-	if isBinary(sample[:n]) {
-		fmt.Fprintf(os.Stderr, "Skipping binary file %s\n", path)
-		return nil
+// execSkipped reports whether a dump would have skipped path for its size or
+// contents, and records why. The name filters, -grep and the like have
+// already been applied.
+func (r *runner) execSkipped(path string) (bool, error) {
+	opts := r.opts
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() {
+		r.skip(path, "special-file", "%s (%s, not a regular file)", path, fileKind(info.Mode()))
+		return true, nil
+	}
+	if opts.maxSize > 0 && info.Size() > opts.maxSize {
+		r.skip(path, "too-large", "%s (size %s exceeds limit %s)", path, r.size(info.Size()), r.size(opts.maxSize))
+		return true, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	data, err := readSample(f, opts.sampleSize)
+	if err != nil {
+		return false, err
 	}
-	// Rewind after sampling
-	if _, err := file.Seek(0, io.SeekStart); err != nil {
-		return err
+	if r.binary(path, data) {
+		r.skip(path, "binary", "binary file %s", path)
+		return true, nil
 	}
+	if opts.skipMinified && minified(path, data) {
+		r.skip(path, "minified", "minified file %s", path)
+		return true, nil
+	}
+	if opts.requireUTF8 {
+		rest, err := io.ReadAll(f)
+		if err != nil {
+			return false, err
+		}
+		if off := invalidUTF8(append(data, rest...), false); off >= 0 {
+			r.skip(path, "invalid-utf8", "%s (not valid UTF-8 at byte %d)", path, off)
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-	// Print delimiter and contents
-	fmt.Printf("\n--- %s ---\n", path)
-	if _, err := io.Copy(os.Stdout, file); err != nil {
-		return err
+// head returns the start of data that -sample-size says to examine.
+func (r *runner) head(data []byte) []byte {
+	if r.opts.sampleSize == 0 {
+		return data
 	}
-	fmt.Println()
-	return nil
+	return data[:min(len(data), r.opts.sampleSize)]
+}
+
+// binary reports whether sample, the start of the file name, looks like
+// binary data: either it sniffs as a binary type such as an image, or too
+// much of it is unprintable. With -lenient-binary, text without NUL bytes that
+// is valid UTF-8 never does, however many control characters it holds. With
+// -skip-binary-check nothing does, and with -binary-check-ext only files with
+// one of those extensions are examined.
+func (r *runner) binary(name string, sample []byte) bool {
+	if r.opts.skipBinaryChk {
+		return false
+	}
+	if len(r.opts.binaryCheckExt) > 0 && !slices.Contains(r.opts.binaryCheckExt, strings.ToLower(filepath.Ext(name))) {
+		return false
+	}
+	if binaryMIME(sniffMIME(sample)) {
+		return true
+	}
+	if r.opts.lenientBinary && bytes.IndexByte(sample, 0) < 0 && invalidUTF8(sample, len(sample) == r.opts.sampleSize) < 0 {
+		return false
+	}
+	return isBinary(sample)
+}
+
+// sampleSize is how much of the start of each file isBinary examines,
+// unless -sample-size says otherwise.
+const sampleSize = 8 << 10
+
+// readSample reads the first size bytes of in, or all of it if size is 0,
+// for isBinary.
+func readSample(in io.Reader, size int) ([]byte, error) {
+	if size == 0 {
+		return io.ReadAll(in)
+	}
+	sample := make([]byte, size)
+	n, err := io.ReadFull(in, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return sample[:n], nil
 }
 
+// isBinary reports whether data, the start of a file, is binary: it has
+// the signature of a known binary format, or, without the signature of a
+// known text format, more than 10% of it is unprintable.
 func isBinary(data []byte) bool {
 	if len(data) == 0 {
 		return false
 	}
+	if binary, known := magicBinary(data); known {
+		return binary
+	}
 	nonPrintable := 0
 	for _, b := range data {
 		r := rune(b)
@@ -137,13 +3247,16 @@ func isBinary(data []byte) bool {
 	return nonPrintable*10 > len(data)
 }
 
-func matchesExtension(path, extension string) bool {
+func matchesExtension(path, extension string, exact bool) bool {
 	if extension == "" {
 		return true
 	}
 	if !strings.HasPrefix(extension, ".") {
 		extension = "." + extension
 	}
+	if exact {
+		return strings.HasSuffix(path, extension)
+	}
 	return strings.HasSuffix(strings.ToLower(path), strings.ToLower(extension))
 }
 
@@ -152,21 +3265,199 @@ func showHelp() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  llm-cat [flags] [files...]")
+	fmt.Println("  command | llm-cat [flags] -")
 	fmt.Println("  command | xargs llm-cat [flags]")
 	fmt.Println("  find . -name '*.go' | llm-cat")
 	fmt.Println()
 	fmt.Println("Flags:")
-	fmt.Println("  -r               Recursively process directories")
-	fmt.Println("  -ext string      Only process files with this extension")
-	fmt.Println("  -n               Only print file names, not contents")
-	fmt.Println("  -max-size bytes  Maximum bytes to show (default 10485760, 0 = unlimited)")
-	fmt.Println("  -h               Show this help message")
+	fmt.Println("  -r                    Recursively process directories")
+	fmt.Println("  -depth N              With -r, select files at most N levels deep (0 = no limit); src@N for one argument")
+	fmt.Println("  -a                    Include hidden files and directories (.git, .env, ...) when recursing")
+	fmt.Println("  -ext string           Only process files with this extension")
+	fmt.Println("  -case-sensitive-ext   Match -ext with its case (.H is not .h)")
+	fmt.Println("  -lang presets         Only process files for go, web, python, rust and/or c (e.g. go,web)")
+	fmt.Println("  -lang-filter langs    Only process files detected as these languages (e.g. go,python)")
+	fmt.Println("  -grep regexp          Only print files whose contents match regexp")
+	fmt.Println("  -skip-matching regexp Skip files whose contents match regexp")
+	fmt.Println("  -render-notebooks     Print .ipynb notebooks as their code cells instead of JSON")
+	fmt.Println("  -pdf-text             Print .pdf files as their text, extracted with pdftotext")
+	fmt.Println("  -include-nb-markdown  With -render-notebooks, also print markdown cells as comments")
+	fmt.Println("  -include-nb-output    With -render-notebooks, also print text outputs as comments")
+	fmt.Println("  -grep-context N       With -grep, print only matching lines and N lines around each")
+	fmt.Println("  -first-match          With -grep, print only the first match in each file")
+	fmt.Println("  -include pattern      Only process files matching a glob, with ** for any depth (may be repeated)")
+	fmt.Println("  -exclude pattern      Skip files and directories matching a glob (may be repeated)")
+	fmt.Println("  -exclude-from file    Also skip what the .gitignore-style patterns in file match")
+	fmt.Println("  -newer-than duration  Only process files modified within duration (90m, 12h, 7d, 2w)")
+	fmt.Println("  -newer-commit duration  Only process files last committed to git within duration")
+	fmt.Println("  -tracked-only         Only process files git tracks (git ls-files)")
+	fmt.Println("  -recent duration     Like -r -skip-common-junk -newer-than duration over .; counts matches")
+	fmt.Println("  -path-regex regexp    Only process files whose path (e.g. src/api/x.go) matches this regexp")
+	fmt.Println("  -skip-common-junk     Prune node_modules, target, dist, __pycache__ and similar directories")
+	fmt.Println("  -skip-vendored        Prune directories that look like third-party code (-v lists them)")
+	fmt.Println("  -skip-submodules      Prune git submodules listed in .gitmodules (-v lists them)")
+	fmt.Println("  -submodule-headers    Note in each header which git submodule the file is in")
+	fmt.Println("  -junk-dir name        Also prune directories with this name (may be repeated)")
+	fmt.Println("  -resolve-globs-recursively  Expand glob arguments here, so 'src/**/*_test.go' reaches every level")
+	fmt.Println("  -exclude-tests        Skip test files (*_test.go, test_*.py, *.spec.ts, tests/, ...)")
+	fmt.Println("  -only-tests           Print only test files")
+	fmt.Println("  -n                    Only print file names, not contents")
+	fmt.Println("  -q                    Don't report skipped files on stderr")
+	fmt.Println("  -error-placeholders   Print files that can't be read as --- path (unreadable: why) ---")
+	fmt.Println("  -merge-notices        Report skipped files inline as --- path (skipped: reason) ---")
+	fmt.Println("  -v                    List skipped files in -report-unreadable; report -lang-filter's detections")
+	fmt.Println("  -skip-errors          Keep walking past files and directories that can't be read")
+	fmt.Println("  -report-unreadable    Summarize skipped files by reason (permission, binary, ...) at the end")
+	fmt.Println("  -skip-report          Write that summary as \"reason<TAB>path\" lines")
+	fmt.Println("  -summarize-skips      Print one count of skipped files per reason at the end, not a line each")
+	fmt.Println("  -show-mime            With -n, show each file's sniffed media type")
+	fmt.Println("  -show-mode            Note each file's permissions in its header, as (0755, executable)")
+	fmt.Println("  -mime patterns        Only process files whose media type matches, e.g. text/*,application/json")
+	fmt.Println("  -binary-marker        With -n, mark binary files as \"path  [binary]\"")
+	fmt.Println("  -max-size bytes       Maximum bytes to show (default 10485760, 0 = unlimited)")
+	fmt.Println("  -total-max bytes      Maximum bytes to show across all files (0 = unlimited)")
+	fmt.Println("  -model name           Set -total-max from a model's context window and warn if over it")
+	fmt.Println("  -max-tokens-per-file  Truncate each file after this many tokens")
+	fmt.Println("  -max-total-tokens N   Maximum tokens to show across all files (0 = unlimited)")
+	fmt.Println("  -max-total-lines N    Maximum lines to show across all files (0 = unlimited)")
+	fmt.Println("  -stats                Report totals by extension (files, lines, bytes, tokens)")
+	fmt.Println("  -human                Show sizes as 3.2 KiB, 10.0 MiB instead of byte counts")
+	fmt.Println("  -stat-format tmpl     Write the -stats report with a Go template, e.g. '{{.TotalTokens}}'")
+	fmt.Println("  -stats-stdout         Write the -stats report to stdout instead of stderr")
+	fmt.Println("  -count-tokens         Report the number of tokens printed")
+	fmt.Println("  -numbered             Number each file's header, as in [3/17] path, with a ==== line between files")
+	fmt.Println("  -smart-header         Leave out the file header when only one file is printed")
+	fmt.Println("  -tree-json            Start the dump with a JSON tree of the files printed and their sizes")
+	fmt.Println("  -tree-json-empty-dirs With -tree-json, also list walked directories with no files printed")
+	fmt.Println("  -count-header         Start the dump with a line giving its files, lines and tokens")
+	fmt.Println("  -header-tokens        Show each file's token count in its header")
+	fmt.Println("  -warn-tokens N        Warn about each file of more than N tokens")
+	fmt.Println("  -tokenizer name       Count tokens exactly with cl100k or o200k instead of estimating")
+	fmt.Println("  -no-token-cache       With -tokenizer, count every file again instead of reusing earlier counts")
+	fmt.Println("  -drop-over fraction   Skip files larger than this fraction of -total-max")
+	fmt.Println("  -per-dir-max bytes    Maximum bytes to show from any one directory when recursing")
+	fmt.Println("  -max-files-per-dir N  Print at most N files from any one directory when recursing")
+	fmt.Println("  -split-output SIZE    With -o, write parts out.001, out.002, ... of at most SIZE bytes")
+	fmt.Println("  -max-per-ext N        Print at most N files with each extension")
+	fmt.Println("  -max-bytes-ext list   Maximum bytes to show across the files with each extension, as .json=50000,.go=500000")
+	fmt.Println("  -sample N             Print only N of the selected files, chosen at random")
+	fmt.Println("  -skip-above-percentile P  Skip files larger than the Pth percentile of the selected files' sizes")
+	fmt.Println("  -seed N               Seed for -sample, to choose the same files again")
+	fmt.Println("  -diff-against dir     Print only files that differ from the same paths under dir")
+	fmt.Println("  -diff                 With -diff-against, print differing files as unified diffs")
+	fmt.Println("  -changed-since REF    Print only the changed lines (and context) of files changed since git REF")
+	fmt.Println("  -changed-context N    Lines of context around each change for -changed-since (default 3)")
+	fmt.Println("  -git-status           Mark changed files' headers with their git status ([M], [A], [??])")
+	fmt.Println("  -blame                Start each line with its author and commit, from git blame")
+	fmt.Println("  -blame-max N          With -blame, blame at most N files (default 20)")
+	fmt.Println("  -git-root-paths       Show paths relative to the top of their git work tree")
+	fmt.Println("  -chunk                Print files over -max-size as parts (part 1/3, ...) instead of skipping")
+	fmt.Println("  -merge-small N        Print runs of files under N bytes in one block with sub-headers")
+	fmt.Println("  -path-style style     Show paths with posix forward slashes (default) or native separators")
+	fmt.Println("  -native-separators    Show paths with the OS's separators, as -path-style native")
+	fmt.Println("  -stdin-name name      Header name for contents read from - (default <stdin>)")
+	fmt.Println("  -buffer-input         Read the whole path list from stdin before printing anything")
+	fmt.Println("  -mixed-stdin          On stdin, @path lines name files; other lines are text to print as notes")
+	fmt.Println("  -ndjson-input         Read stdin as JSON objects with a path or name and contents, each with its own settings")
+	fmt.Println("  -require-input        Fail if no files are given as arguments or on stdin")
+	fmt.Println("  -stdin-timeout d      Give up if no paths arrive on stdin within d, e.g. 5s (default: wait forever)")
+	fmt.Println("  -fail-over-budget     Exit with an error, printing nothing, if the output is over -total-max")
+	fmt.Println("  -fail-on-secret       Exit with an error, printing nothing, if a file looks like it holds a secret")
+	fmt.Println("  -entropy-threshold bits  Flag random-looking strings with this entropy per character (default 4.5, 0 = off)")
+	fmt.Println("  -min-secret-length N  Shortest string -entropy-threshold checks (default 20)")
+	fmt.Println("  -group-by-ext         Print files in sections by language (## Go, ## Markdown, ...)")
+	fmt.Println("  -ext-map list         Classify extensions as languages, as .tmpl=go-template,.jenkinsfile=groovy")
+	fmt.Println("  -order-file file      Print files matching the patterns in file first, in that order")
+	fmt.Println("  -docs-first           Print top-level READMEs, *.md and LICENSE before other files")
+	fmt.Println("  -readme-first-per-dir  Print each directory's README before the rest of that directory")
+	fmt.Println("  -dir-context          Print the start of each directory's README before its first file")
+	fmt.Println("  -dir-context-lines N  Lines of each README to print with -dir-context (default 10)")
+	fmt.Println("  -xml                  Print each file as a <file path=\"...\"> element")
+	fmt.Println("  -md                   Print each file as a Markdown code block tagged with its language")
+	fmt.Println("  -front-matter         Start each file with a YAML header of its path, size, lines and lang")
+	fmt.Println("  -io-workers N         Files to read at once (default 4)")
+	fmt.Println("  -fast-walk N          Read up to N directories at once when recursing (default 0, one at a time)")
+	fmt.Println("  -concurrent-stat-prefetch N  Stat up to N files at once ahead of processing (default 0, one at a time)")
+	fmt.Println("  -cpu-workers N        Files to filter and tokenize at once (default: number of CPUs)")
+	fmt.Println("  -retry N              Retry failed reads up to N times (not for missing files)")
+	fmt.Println("  -buffer-size bytes    Copy file contents this many bytes at a time (default: let the copy choose)")
+	fmt.Println("  -mmap                 Memory-map files of 1 MiB or more instead of reading them")
+	fmt.Println("  -max-lines N          Print only the first N lines of each file")
+	fmt.Println("  -preview-lines N      Lines to print around LINE for a path:@LINE argument (default 10)")
+	fmt.Println("  -compact-imports      Merge runs of top-level imports in Python files")
+	fmt.Println("  -strip-imports        Replace imports in Go, Python and JS/TS files with a count of them")
+	fmt.Println("  -minify-json          Print .json files compactly, without indentation")
+	fmt.Println("  -pretty-json          Print .json files indented by two spaces")
+	fmt.Println("  -strip-license        Drop a leading license comment block from each file")
+	fmt.Println("  -nfc                  Put contents in Unicode NFC, composing accented letters")
+	fmt.Println("  -trim-trailing-blank-lines  Drop blank lines at the end of each file")
+	fmt.Println("  -dedup-blocks N       Collapse blocks of N or more lines repeated back to back to one copy")
+	fmt.Println("  -editorconfig         Apply each file's .editorconfig whitespace settings")
+	fmt.Println("  -comment-out          Print every line commented out (// for Go, # for Python, ...)")
+	fmt.Println("  -reindent N           Re-indent files with N spaces per level where that is safe")
+	fmt.Println("  -line-template format Print each line as format, e.g. '{path}:{line}: {text}'")
+	fmt.Println("  -anchors              Start each line with a [path:line] anchor for a model to cite")
+	fmt.Println("  -show-whitespace      Show tabs as →, trailing spaces as ·, line endings as ↵ or ⏎↵")
+	fmt.Println("  -html-escape          HTML-escape file contents and header paths")
+	fmt.Println("  -transforms list      Run exactly these content transforms, in this order, e.g. nfc,max-lines=50")
+	fmt.Println("  -escape-delimiters    Prefix content lines that look like file headers with -escape-prefix")
+	fmt.Println("  -escape-prefix str    Prefix for -escape-delimiters (default: a zero-width space)")
+	fmt.Println("  -wrap N               Break lines past N columns (default: terminal width; never when piped)")
+	fmt.Println("  -highlight regexp     Show matches in reverse video on a terminal")
+	fmt.Println("  -color when           Highlight on a terminal (auto), always or never")
+	fmt.Println("  -base64-binary        Print binary files base64-encoded instead of skipping them")
+	fmt.Println("  -image-placeholders   Print a line with each image's name, size and format instead of skipping it")
+	fmt.Println("  -lenient-binary       Never skip valid UTF-8 without NUL bytes as binary")
+	fmt.Println("  -output-encoding name Write the dump in latin1, windows-1252 or ascii instead of utf-8")
+	fmt.Println("  -on-unmappable how    For characters the encoding lacks: replace (with ?, the default) or error")
+	fmt.Println("  -skip-minified        Skip minified files (*.min.*, or JS/CSS/HTML with very long lines)")
+	fmt.Println("  -skip-binary-check    Treat every file as text, without checking for binary data")
+	fmt.Println("  -binary-check-ext ext Check only files with these comma-separated extensions for binary data")
+	fmt.Println("  -sample-size N        Bytes examined to tell binary files (default 8192; 0 = whole file)")
+	fmt.Println("  -require-utf8         Skip files that are not entirely valid UTF-8")
+	fmt.Println("  -ignore-symlinks      Skip symlinks, including ones named as arguments")
+	fmt.Println("  -deref-files          Print the contents of symlinks to files found while recursing")
+	fmt.Println("  -report-symlink-loops Report symlinks found while recursing that loop back up the tree")
+	fmt.Println("  -allow-fifo           Read named pipes (with a timeout) instead of skipping them")
+	fmt.Println("  -output-dir DIR       Write each file's (filtered) contents to DIR/<path> instead")
+	fmt.Println("  -zip PATH             Write the selected (filtered) files into a zip archive instead")
+	fmt.Println("  -exact                Print the files byte for byte in a framing -split reads back")
+	fmt.Println("  -split DIR            Write the files in an -exact dump under DIR")
+	fmt.Println("  -index PATH           Write each file's byte offset and length in the output (JSON or TSV)")
+	fmt.Println("  -prepend text|file    Print this text (or the file's contents) before the first file")
+	fmt.Println("  -chat-json            Write the dump as a chat API messages array")
+	fmt.Println("  -chat-max SIZE        With -chat-json, split the dump into user messages of SIZE bytes")
+	fmt.Println("  -system text|file     With -chat-json, start with a system message")
+	fmt.Println("  -prompt-template t    Print the files in this text (or file) at {files}; fills in {count}, {tokens}")
+	fmt.Println("  -append text|file     Print this text (or the file's contents) after the last file")
+	fmt.Println("  -trace entry          Print entry and the local files it imports, transitively (JS/TS)")
+	fmt.Println("  -incremental file     Print files unchanged since the last run as markers; update file")
+	fmt.Println("  -full                 With -incremental, print every file in full")
+	fmt.Println("  -dedupe-content       Print files identical to an earlier one as a marker")
+	fmt.Println("  -exec 'cmd {}'        Run cmd once per selected file instead of printing it")
+	fmt.Println("  -o file               Write the output to file instead of stdout")
+	fmt.Println("  -edit                 Open the output in $EDITOR first, and print it as saved")
+	fmt.Println("  -events file          Write progress events as JSON lines to file (e.g. /dev/fd/3)")
+	fmt.Println("  -count-by-dir         Print bytes, tokens and files per directory, largest first, and exit")
+	fmt.Println("  -count-depth N        Directory levels -count-by-dir breaks totals down to (default 1)")
+	fmt.Println("  -skeleton             Experimental: print numbered files, then empty numbered slots for edits, and exit")
+	fmt.Println("  -lang-stats           Print bytes, share and files per language, largest first, and exit")
+	fmt.Println("  -eol-report           Print each file's line endings (lf, crlf, mixed) and final newline, and exit")
+	fmt.Println("  -list-json            Print the selected files as JSON {path, size, ext, binary} and exit")
+	fmt.Println("  -json-hash            With -list-json, add the sha256 of each file's printed contents")
+	fmt.Println("  -save-selection file  Write the names of the selected files to file, one per line, and exit")
+	fmt.Println("  -selection-diff file  Print files added (+) or removed (-) since -save-selection wrote file")
+	fmt.Println("  -watch                Dump again whenever a selected file changes (Ctrl-C to stop)")
+	fmt.Println("  -follow               Print one file, then its new lines as they are written (Ctrl-C to stop)")
+	fmt.Println("  -h                    Show this help message")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  llm-cat file1.txt file2.go")
 	fmt.Println("  llm-cat -r -ext .go src/")
 	fmt.Println("  llm-cat -n $(git ls-files)")
+	fmt.Println("  git diff | llm-cat -stdin-name patch.diff - main.go")
 	fmt.Println("  find . -type f -size -20M | llm-cat")
+	fmt.Println("  llm-cat -r -ext .go -exec 'gofmt -l {}' .")
 	fmt.Println()
 	fmt.Println("Output format when dumping:")
 	fmt.Println("  --- filename.go ---")
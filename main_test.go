@@ -0,0 +1,606 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testOptions returns the options main sets up when no flags are given,
+// except that skipped files aren't reported.
+func testOptions() *options {
+	return &options{
+		maxSize:        defaultMaxSize,
+		grepContext:    -1,
+		quiet:          true,
+		stdinName:      "<stdin>",
+		posixPaths:     true,
+		ioWorkers:      4,
+		cpuWorkers:     2,
+		sampleSize:     sampleSize,
+		outputEncoding: "utf-8",
+		replaceUnmap:   true,
+		countDepth:     1,
+		dirContextMax:  10,
+		previewLines:   10,
+		changedContext: 3,
+		blameMax:       20,
+	}
+}
+
+// testRunner returns a runner for opts that prints to the buffer returned,
+// set up as dump does for a run.
+func testRunner(opts *options) (*runner, *bytes.Buffer) {
+	r := newRunner(opts)
+	r.stats = newDumpStats()
+	var out bytes.Buffer
+	r.out = &out
+	return r, &out
+}
+
+// writeFiles creates each of files, by slash-separated name, under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// dumpFiles dumps paths as opts say and returns the output.
+func dumpFiles(t *testing.T, opts *options, paths ...string) string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out")
+	if err := newRunner(opts).dumpTo(&pathList{paths: paths}, out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// captureStderr returns what f writes to os.Stderr.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	defer func() { os.Stderr = stderr }()
+	f()
+	w.Close()
+	return string(<-done)
+}
+
+func TestHandleFileGrowing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.txt")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// What stat said while walking, before the file grew.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("abcdefghij")
+	f.Close()
+
+	tests := []struct {
+		limit     int64
+		want      string
+		truncated bool
+	}{
+		{limit: 10, want: "0123456789", truncated: true},
+		{limit: 15, want: "0123456789abcde", truncated: true},
+		{limit: 20, want: "0123456789abcdefghij"},
+		{limit: 0, want: "0123456789abcdefghij"},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.quiet = false
+		r, out := testRunner(opts)
+		var n int64
+		stderr := captureStderr(t, func() {
+			n, err = r.handleFile(path, info, tt.limit)
+		})
+		if err != nil {
+			t.Fatalf("limit %d: %v", tt.limit, err)
+		}
+		if n != int64(len(tt.want)) || !strings.Contains(out.String(), "\n"+tt.want+"\n") {
+			t.Errorf("limit %d: printed %d bytes:\n%s\nwant %q", tt.limit, n, out.String(), tt.want)
+		}
+		if got := strings.Contains(stderr, "Truncated "+path); got != tt.truncated {
+			t.Errorf("limit %d: stderr %q, want truncation note: %v", tt.limit, stderr, tt.truncated)
+		}
+	}
+}
+
+func TestHandleFileExecFilters(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"match.txt":  "foo bar\n",
+		"other.txt":  "bar baz\n",
+		"secret.txt": "foo SECRET\n",
+		"blob.bin":   "foo\x00\x01\x02\x03",
+		"big.txt":    strings.Repeat("foo\n", 100),
+		"latin1.txt": "foo caf\xe9\n",
+		"min.js":     "var foo=1;" + strings.Repeat("bar();", 1000) + "\n",
+	}
+	writeFiles(t, dir, files)
+	// What a dump would print of each file with no filter.
+	all := map[string]string{"match.txt": "foo bar\n", "other.txt": "bar baz\n", "secret.txt": "foo SECRET\n", "blob.bin": "",
+		"big.txt": files["big.txt"], "latin1.txt": files["latin1.txt"], "min.js": files["min.js"]}
+	without := func(names ...string) map[string]string {
+		want := maps.Clone(all)
+		for _, name := range names {
+			want[name] = ""
+		}
+		return want
+	}
+	tests := []struct {
+		name string
+		opts options
+		want map[string]string // file -> output from -exec cat
+	}{
+		{
+			name: "grep",
+			opts: options{grep: regexp.MustCompile("foo")},
+			want: without("other.txt"),
+		},
+		{
+			name: "grep and skip-matching",
+			opts: options{grep: regexp.MustCompile("foo"), skipMatching: regexp.MustCompile("SECRET")},
+			want: without("other.txt", "secret.txt"),
+		},
+		{
+			name: "max-size",
+			opts: options{maxSize: 100},
+			want: without("big.txt", "min.js"),
+		},
+		{
+			name: "require-utf8",
+			opts: options{requireUTF8: true},
+			want: without("latin1.txt"),
+		},
+		{
+			name: "skip-minified",
+			opts: options{skipMinified: true},
+			want: without("min.js"),
+		},
+		{
+			name: "no filter",
+			opts: options{},
+			want: all,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, want := range tt.want {
+				opts := tt.opts
+				opts.exec = []string{"cat"}
+				opts.grepContext = -1
+				opts.quiet = true
+				r, out := testRunner(&opts)
+				if _, err := r.handleFile(filepath.Join(dir, name), nil, 0); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if got := out.String(); got != want {
+					t.Errorf("%s: -exec cat printed %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// chdir changes to dir until the test ends, so that paths in the output are
+// short and the same on every run.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestSymlinks(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeFiles(t, ".", map[string]string{"real/a.txt": "hi\n"})
+	for link, target := range map[string]string{"real/link.txt": "a.txt", "root": "real"} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("can't make symlinks: %v", err)
+		}
+	}
+	tests := []struct {
+		name string
+		set  func(*options)
+		args []string
+		want string
+	}{
+		{"recurse", func(o *options) {}, []string{"real"}, "\n--- real/a.txt ---\nhi\n\n"},
+		{"names", func(o *options) { o.namesOnly = true }, []string{"real"}, "real/a.txt\n--- real/link.txt -> a.txt (symlink, not followed) ---\n"},
+		{"ignore names", func(o *options) { o.namesOnly, o.ignoreSymlinks = true, true }, []string{"real"}, "real/a.txt\n"},
+		{"symlinked root", func(o *options) { o.namesOnly = true }, []string{"root"}, "root/a.txt\n--- root/link.txt -> a.txt (symlink, not followed) ---\n"},
+		{"named link", func(o *options) {}, []string{"real/link.txt"}, "\n--- real/link.txt ---\nhi\n\n"},
+		{"ignore named link", func(o *options) { o.ignoreSymlinks = true }, []string{"real/link.txt"}, ""},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.recurse = true
+		tt.set(opts)
+		if got := dumpFiles(t, opts, tt.args...); got != tt.want {
+			t.Errorf("%s: printed %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestXMLEscape(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"main.go", "main.go"},
+		{"a&b.go", "a&amp;b.go"},
+		{`<"quoted">.go`, "&lt;&#34;quoted&#34;&gt;.go"},
+		{"it's.go", "it&#39;s.go"},
+		{"new\nline\t.go\r", "new&#xA;line&#x9;.go&#xD;"},
+	}
+	for _, tt := range tests {
+		if got := xmlEscape(tt.in); got != tt.want {
+			t.Errorf("xmlEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestXMLPaths(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeFiles(t, ".", map[string]string{"a&b.go": "if a < b && c {}\n"})
+	if err := os.WriteFile("new\nline.go", []byte("x\n"), 0o644); err != nil {
+		t.Skipf("can't name a file with a newline: %v", err)
+	}
+	opts := testOptions()
+	opts.xml = true
+	want := "\n<file path=\"a&amp;b.go\">\nif a < b && c {}\n</file>\n" +
+		"\n<file path=\"new&#xA;line.go\">\nx\n</file>\n"
+	if got := dumpFiles(t, opts, "a&b.go", "new\nline.go"); got != want {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+}
+
+func TestLenientBinary(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            string
+		strict, lenient bool
+	}{
+		{"plain.csv", "id,name\r\n1,alice\r\n2,bob\r\n", false, false},
+		{"dashes.csv", strings.Repeat("1,a – b – c\r\n", 20), true, false},
+		{"controls.tsv", strings.Repeat("1\t\x1b[0m\x07\f\x0b\r\n", 20), true, false},
+		{"nul.csv", "id,name\r\n1,\x00\x00\x00\x00\r\n", true, true},
+		{"cp1252.csv", strings.Repeat("1,caf\xe9\x80,\x81\x82\r\n", 20), true, true},
+	}
+	for _, tt := range tests {
+		for _, lenient := range []bool{false, true} {
+			opts := testOptions()
+			opts.lenientBinary = lenient
+			want := tt.strict
+			if lenient {
+				want = tt.lenient
+			}
+			if got := newRunner(opts).binary(tt.name, []byte(tt.data)); got != want {
+				t.Errorf("%s: binary = %v with -lenient-binary %v, want %v", tt.name, got, lenient, want)
+			}
+		}
+	}
+}
+
+func TestMatchesExtension(t *testing.T) {
+	tests := []struct {
+		path, ext      string
+		fold, caseSens bool
+	}{
+		{"vec.h", ".h", true, true},
+		{"vec.H", ".h", true, false},
+		{"vec.h", ".H", true, false},
+		{"vec.H", ".H", true, true},
+		{"vec.H", "H", true, true},
+		{"main.go", "", true, true},
+		{"main.go", ".h", false, false},
+	}
+	for _, tt := range tests {
+		if got := matchesExtension(tt.path, tt.ext, false); got != tt.fold {
+			t.Errorf("matchesExtension(%q, %q, false) = %v, want %v", tt.path, tt.ext, got, tt.fold)
+		}
+		if got := matchesExtension(tt.path, tt.ext, true); got != tt.caseSens {
+			t.Errorf("matchesExtension(%q, %q, true) = %v, want %v", tt.path, tt.ext, got, tt.caseSens)
+		}
+	}
+}
+
+func TestCaseSensitiveExt(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeFiles(t, ".", map[string]string{"src/vec.h": "// C\n", "src/vec.H": "// C++\n"})
+	if entries, err := os.ReadDir("src"); err != nil || len(entries) != 2 {
+		t.Skip("the filesystem folds case")
+	}
+	tests := []struct {
+		ext     string
+		caseExt bool
+		want    string
+	}{
+		{".h", false, "src/vec.H\nsrc/vec.h\n"},
+		{".H", false, "src/vec.H\nsrc/vec.h\n"},
+		{".h", true, "src/vec.h\n"},
+		{".H", true, "src/vec.H\n"},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.recurse, opts.namesOnly = true, true
+		opts.extension, opts.caseExt = tt.ext, tt.caseExt
+		if got := dumpFiles(t, opts, "src"); got != tt.want {
+			t.Errorf("-ext %s, -case-sensitive-ext %v: printed %q, want %q", tt.ext, tt.caseExt, got, tt.want)
+		}
+	}
+}
+
+func TestCaseSensitiveExtMap(t *testing.T) {
+	saved, savedExact := maps.Clone(extLanguages), maps.Clone(exactExtLanguages)
+	defer func() { extLanguages, exactExtLanguages = saved, savedExact }()
+	tests := []struct {
+		caseExt bool
+		h, H    language
+	}{
+		{false, langCPP, langCPP},
+		{true, langC, langCPP},
+	}
+	for _, tt := range tests {
+		extLanguages, exactExtLanguages = maps.Clone(saved), maps.Clone(savedExact)
+		if err := addExtLanguages(".H=cpp", tt.caseExt); err != nil {
+			t.Fatal(err)
+		}
+		if got := detectLanguage("vec.h", nil); got != tt.h {
+			t.Errorf("-ext-map .H=cpp, -case-sensitive-ext %v: vec.h is %v, want %v", tt.caseExt, got, tt.h)
+		}
+		if got := detectLanguage("vec.H", nil); got != tt.H {
+			t.Errorf("-ext-map .H=cpp, -case-sensitive-ext %v: vec.H is %v, want %v", tt.caseExt, got, tt.H)
+		}
+	}
+}
+
+func TestDisplayNameSlashes(t *testing.T) {
+	native := filepath.Join("src", "pkg", "a.go")
+	tests := []struct {
+		posix bool
+		want  string
+	}{
+		{true, "src/pkg/a.go"},
+		{false, native},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.posixPaths = tt.posix
+		if got := newRunner(opts).displayName(native); got != tt.want {
+			t.Errorf("displayName(%q) with posix paths %v = %q, want %q", native, tt.posix, got, tt.want)
+		}
+	}
+}
+
+// A readSizes reader records the largest read asked of it.
+type readSizes struct {
+	r   io.Reader
+	max int
+}
+
+func (s *readSizes) Read(p []byte) (int, error) {
+	s.max = max(s.max, len(p))
+	return s.r.Read(p)
+}
+
+func TestCopyContentsBufferSize(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789\n"), 1000)
+	opts := testOptions()
+	opts.bufferSize = 100
+	// A file has a ReaderFrom of its own, which must not override the
+	// -buffer-size reads.
+	dst, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	src := &readSizes{r: bytes.NewReader(data)}
+	n, err := copyContents(dst, src, "a.txt", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("copied %d bytes, want %d", n, len(data))
+	}
+	if src.max != 100 {
+		t.Errorf("largest read was %d bytes, want 100", src.max)
+	}
+	got, err := os.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("copied %d bytes that differ from the source", len(got))
+	}
+}
+
+// A writeCount writer counts the writes made to it.
+type writeCount struct{ n int }
+
+func (w *writeCount) Write(p []byte) (int, error) {
+	w.n++
+	return len(p), nil
+}
+
+func TestCopyContentsSingleWrite(t *testing.T) {
+	// Without -buffer-size a bytes.Reader, such as a mapped file, writes
+	// itself out in one call.
+	data := bytes.Repeat([]byte("0123456789\n"), 10000)
+	var w writeCount
+	if _, err := copyContents(&w, bytes.NewReader(data), "a.txt", testOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if w.n != 1 {
+		t.Errorf("copied in %d writes, want 1", w.n)
+	}
+}
+
+func TestReadFlagsSkipPipeline(t *testing.T) {
+	// testOptions has the default worker counts, which pipeline a dump.
+	if opts := testOptions(); !newRunner(opts).pipelined() {
+		t.Fatal("default options don't pipeline")
+	}
+	opts := testOptions()
+	opts.bufferSize = 1 << 20
+	if newRunner(opts).pipelined() {
+		t.Error("-buffer-size still pipelines, reading files whole")
+	}
+
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{"big.txt": strings.Repeat("0123456789abcde\n", mmapThreshold/16)})
+	mapOrSkip(t, "big.txt")
+	opts = testOptions()
+	opts.mmap, opts.verbose = true, true
+	var out string
+	stderr := captureStderr(t, func() { out = dumpFiles(t, opts, "big.txt") })
+	if !strings.Contains(stderr, "Mapped big.txt") {
+		t.Errorf("-mmap with the default workers didn't map big.txt; stderr:\n%s", stderr)
+	}
+	if !strings.Contains(out, "0123456789abcde\n0123456789abcde\n") {
+		t.Errorf("-mmap printed %d bytes without the file's contents", len(out))
+	}
+}
+
+// mapOrSkip skips t where path can't be memory-mapped.
+func mapOrSkip(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, unmap, err := mmapFile(f, 1)
+	if err != nil {
+		t.Skipf("can't map files here: %v", err)
+	}
+	unmap()
+}
+
+func TestMmapGrowing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.txt")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("0123456789abcde\n"), mmapThreshold/16), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mapOrSkip(t, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("more\n")
+	f.Close()
+
+	opts := testOptions()
+	opts.quiet, opts.mmap = false, true
+	r, _ := testRunner(opts)
+	var n int64
+	stderr := captureStderr(t, func() {
+		n, err = r.handleFile(path, info, info.Size())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != info.Size() {
+		t.Errorf("printed %d bytes, want %d", n, info.Size())
+	}
+	if !strings.Contains(stderr, "Truncated "+path) {
+		t.Errorf("no truncation note for a mapped file that grew; stderr:\n%s", stderr)
+	}
+}
+
+func TestMmapTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.txt")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("0123456789abcde\n"), mmapThreshold/16), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mapOrSkip(t, path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, unmap, err := mmapFile(f, mmapThreshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unmap()
+	// Truncating the file leaves the mapping's pages past the new end
+	// unreadable.
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := testRunner(testOptions())
+	if _, err := r.printMapped(path, f, data, 0); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("printMapped of a truncated file returned %v, want a truncation error", err)
+	}
+}
+
+func TestTreeJSONEmptyDirs(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go":           "package a\n",
+		"sub/b.go":       "package b\n",
+		"docs/notes.txt": "notes\n",
+		".git/HEAD":      "ref: refs/heads/main\n",
+	})
+	if err := os.Mkdir("empty", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		emptyDirs bool
+		want      string
+	}{
+		{false, `{"a.go":10,"sub":{"b.go":10}}`},
+		{true, `{"a.go":10,"docs":{},"empty":{},"sub":{"b.go":10}}`},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.recurse, opts.extension = true, ".go"
+		opts.treeJSON, opts.treeEmptyDirs = true, tt.emptyDirs
+		got, _, _ := strings.Cut(dumpFiles(t, opts, "."), "\n")
+		if got != tt.want {
+			t.Errorf("-tree-json-empty-dirs %v: tree is %s, want %s", tt.emptyDirs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestHeaderSlashesWindows(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeFiles(t, ".", map[string]string{"src/pkg/a.go": "package pkg\n"})
+	tests := []struct {
+		posix bool
+		arg   string
+		want  string
+	}{
+		{true, `src\pkg\a.go`, "\n--- src/pkg/a.go ---\npackage pkg\n\n"},
+		{true, "src", "\n--- src/pkg/a.go ---\npackage pkg\n\n"},
+		{false, `src\pkg\a.go`, "\n--- src\\pkg\\a.go ---\npackage pkg\n\n"},
+		{false, "src", "\n--- src\\pkg\\a.go ---\npackage pkg\n\n"},
+	}
+	for _, tt := range tests {
+		opts := testOptions()
+		opts.recurse = true
+		opts.posixPaths = tt.posix
+		if got := dumpFiles(t, opts, tt.arg); got != tt.want {
+			t.Errorf("%s with posix paths %v: printed %q, want %q", tt.arg, tt.posix, got, tt.want)
+		}
+	}
+}
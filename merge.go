@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// A smallFile is a file that -merge-small holds back, to print in one block
+// with the small files next to it.
+type smallFile struct {
+	name  string
+	label string // as for the file's own header
+	attrs string // as for its -xml element
+	lang  string // its -md fence info string
+	fence string // its -md code fence
+	data  []byte // its contents, filtered
+}
+
+// mergeable reports whether a file whose contents start with sample goes in
+// a -merge-small block. Since -merge-small is no larger than -sample-size, a
+// sample shorter than it is the whole file.
+func (r *runner) mergeable(sample []byte) bool {
+	return r.opts.mergeSmall > 0 && sample != nil && len(sample) < r.opts.mergeSmall && r.part == ""
+}
+
+// hold filters body, the contents of the small file name, and keeps them to
+// be printed by flushSmall. It returns the number of bytes read from body.
+func (r *runner) hold(name, label string, sample []byte, body io.Reader) (int64, error) {
+	var buf bytes.Buffer
+	out := &lastByteWriter{w: &buf, kinds: r.lineKindsFor(name, sample)}
+	written, err := copyContents(out, body, name, r.opts)
+	r.stats.add(name, out.n, out.lineCount())
+	r.stats.addKinds(name, out.kinds)
+	if err != nil {
+		return written, err
+	}
+	r.small = append(r.small, smallFile{
+		name:  name,
+		label: label,
+		attrs: r.xmlAttrs(name),
+		lang:  detectLanguage(name, sample).fence,
+		fence: codeFence(sample),
+		data:  buf.Bytes(),
+	})
+	return written, nil
+}
+
+// flushSmall prints the small files held so far in one block, each under a
+// short sub-header, or on its own if there is only one.
+func (r *runner) flushSmall() {
+	files := r.small
+	r.small = nil
+	if len(files) == 0 {
+		return
+	}
+	opts := r.opts
+	if len(files) == 1 {
+		f := files[0]
+		start := r.offset()
+		switch {
+		case opts.xml:
+			fmt.Fprintf(r.out, "\n<file %s>\n", f.attrs)
+		case opts.markdown:
+			fmt.Fprintf(r.out, "\n### %s\n\n%s%s\n", f.label, f.fence, f.lang)
+		default:
+			fmt.Fprintf(r.out, "\n--- %s ---\n", f.label)
+		}
+		r.out.Write(f.data)
+		r.closeBlock(unterminated(f.data), f.fence)
+		r.indexBlock(f.name, start)
+		return
+	}
+
+	switch {
+	case opts.xml:
+		fmt.Fprintf(r.out, "\n<files note=\"%d small files\">\n", len(files))
+	case opts.markdown:
+		fmt.Fprintf(r.out, "\n### %d small files\n", len(files))
+	default:
+		fmt.Fprintf(r.out, "\n--- %d small files ---\n", len(files))
+	}
+	for _, f := range files {
+		start := r.offset()
+		switch {
+		case opts.xml:
+			fmt.Fprintf(r.out, "<file %s>\n", f.attrs)
+		case opts.markdown:
+			fmt.Fprintf(r.out, "#### %s\n%s%s\n", f.label, f.fence, f.lang)
+		default:
+			fmt.Fprintf(r.out, "-- %s --\n", f.label)
+		}
+		r.out.Write(f.data)
+		if unterminated(f.data) {
+			fmt.Fprintln(r.out)
+		}
+		switch {
+		case opts.xml:
+			fmt.Fprintln(r.out, "</file>")
+		case opts.markdown:
+			fmt.Fprintln(r.out, f.fence)
+		}
+		r.indexBlock(f.name, start)
+	}
+	if opts.xml {
+		fmt.Fprintln(r.out, "</files>")
+	} else if !opts.markdown {
+		fmt.Fprintln(r.out)
+	}
+}
+
+// unterminated reports whether data ends in a line with no newline.
+func unterminated(data []byte) bool {
+	return len(data) > 0 && data[len(data)-1] != '\n'
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// binaryMIMEPrefixes are the sniffed media types that are binary data even
+// when the bytes of the sample happen to look like text.
+var binaryMIMEPrefixes = []string{
+	"image/", "audio/", "video/", "font/",
+	"application/pdf", "application/zip", "application/x-gzip",
+	"application/x-rar-compressed", "application/wasm", "application/ogg",
+	"application/vnd.ms-fontobject",
+}
+
+// sniffMIME returns the media type of sample, the start of a file, as
+// http.DetectContentType sees it, without parameters such as charset.
+func sniffMIME(sample []byte) string {
+	mime, _, _ := strings.Cut(http.DetectContentType(sample), ";")
+	return mime
+}
+
+// binaryMIME reports whether mime is a type of binary data.
+func binaryMIME(mime string) bool {
+	for _, prefix := range binaryMIMEPrefixes {
+		if strings.HasPrefix(mime, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mimeMatches reports whether mime matches one of the comma-separated glob
+// patterns in -mime, such as "text/*,application/json".
+func mimeMatches(mime, patterns string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), mime); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fileMIME returns the sniffed media type of the file at path, or "" if it
+// is not a regular file or can't be read.
+func fileMIME(path string) string {
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	// http.DetectContentType looks at no more than 512 bytes.
+	sample, err := readSample(f, 512)
+	if err != nil {
+		return ""
+	}
+	return sniffMIME(sample)
+}
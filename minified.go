@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// Minified files are recognized by name, or by their lines: code written by
+// hand averages well under minifiedLineLength bytes a line, even in a file
+// with a few long ones. Only samples of at least minifiedSample bytes are
+// judged by their lines, so short one-liners are left alone.
+const (
+	minifiedLineLength = 300
+	minifiedSample     = 2 << 10
+)
+
+// minifiableExts are the extensions of the files whose lines -skip-minified
+// looks at; others are only recognized by a .min. in their name.
+var minifiableExts = map[string]bool{
+	".js": true, ".mjs": true, ".cjs": true, ".css": true, ".html": true, ".htm": true,
+}
+
+// minified reports whether the file name, whose contents start with sample,
+// looks minified, for -skip-minified.
+func minified(name string, sample []byte) bool {
+	base := strings.ToLower(filepath.Base(name))
+	if strings.Contains(base, ".min.") {
+		return true
+	}
+	if !minifiableExts[filepath.Ext(base)] || len(sample) < minifiedSample {
+		return false
+	}
+	lines := bytes.Count(sample, []byte("\n")) + 1
+	return len(sample)/lines > minifiedLineLength
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeMirror writes body, through the usual filters, to the file for name
+// under -output-dir, creating directories as needed. It returns the number of
+// bytes read from body.
+func writeMirror(name string, body io.Reader, opts *options) (int64, error) {
+	target, err := mirrorPath(opts.outputDir, name)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	n, err := copyContents(f, body, name, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// mirrorPath returns where name goes under dir. Absolute names are placed
+// relative to the root, so /etc/hosts becomes dir/etc/hosts; names that
+// would climb out of dir with ".." are rejected.
+func mirrorPath(dir, name string) (string, error) {
+	rel := filepath.Clean(name)
+	if filepath.IsAbs(rel) {
+		rel = strings.TrimPrefix(rel, filepath.VolumeName(rel))
+		rel = strings.TrimLeft(rel, `/\`)
+	}
+	if rel == "." || rel == "" {
+		return "", fmt.Errorf("cannot mirror %q: no file name", name)
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to write %s outside %s", name, dir)
+	}
+	return filepath.Join(dir, rel), nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// An inputObject is one line of -ndjson-input: a file to read from disk, or
+// contents to print under a name, with settings of its own.
+type inputObject struct {
+	Path       string  `json:"path"`
+	Name       string  `json:"name"`
+	Contents   *string `json:"contents"`
+	Lines      string  `json:"lines"`      // START-END or @LINE
+	Lang       string  `json:"lang"`       // language for the code fence or front matter
+	Transforms *string `json:"transforms"` // as for -transforms
+
+	lines lineRange
+}
+
+// parseInputObject decodes line, one line of -ndjson-input, and checks that
+// its settings make sense.
+func parseInputObject(line []byte) (*inputObject, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	obj := new(inputObject)
+	if err := dec.Decode(obj); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("more than one value on the line")
+	}
+	switch {
+	case obj.Path != "" && obj.Contents != nil:
+		return nil, errors.New("both path and contents given")
+	case obj.Path == "" && obj.Contents == nil:
+		return nil, errors.New("neither path nor contents given")
+	case obj.Contents != nil && obj.Name == "":
+		return nil, errors.New("contents given without a name")
+	case obj.Path != "" && obj.Name != "":
+		return nil, errors.New("name is only for contents; a path is printed under its own name")
+	}
+	if obj.Lines != "" {
+		var err error
+		if obj.lines, err = parseLines(obj.Lines); err != nil {
+			return nil, fmt.Errorf("lines: %v", err)
+		}
+	}
+	if obj.Transforms != nil {
+		if err := applyTransforms(*obj.Transforms, new(options)); err != nil {
+			return nil, fmt.Errorf("transforms: %v", err)
+		}
+	}
+	return obj, nil
+}
+
+// scanObjects calls f for each object read from stdin with -ndjson-input,
+// under a made-up name that stands in for it in the list. Lines that
+// aren't a valid object are reported and skipped.
+func (l *pathList) scanObjects(f func(string)) error {
+	n := 0
+	for l.stdin.Scan() {
+		n++
+		line := bytes.TrimSpace(l.stdin.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		obj, err := parseInputObject(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping malformed -ndjson-input line %d: %v\n", n, err)
+			continue
+		}
+		name := fmt.Sprintf("<object %d>", n)
+		l.objects[name] = obj
+		f(name)
+	}
+	return l.stdin.Err()
+}
+
+// visitObject calls visit for obj: the files under its path, as for an
+// argument, or its contents.
+func visitObject(obj *inputObject, opts *options, visit func(fileEntry) error) error {
+	if obj.Contents != nil {
+		return visit(fileEntry{path: obj.Name, inline: []byte(*obj.Contents), top: true, input: obj})
+	}
+	return processPath(obj.Path, nil, opts, func(e fileEntry) error {
+		e.input = obj
+		return visit(e)
+	})
+}
+
+// useObject sets r.opts to print the file at path with the settings of
+// obj, and returns a func that puts the usual ones back.
+func (r *runner) useObject(obj *inputObject, path string) func() {
+	saved := r.opts
+	opts := *saved
+	if obj.Lines != "" {
+		opts.ranges = lineRanges{path: {obj.lines}}
+	}
+	if obj.Lang != "" {
+		opts.lang = strings.TrimSpace(obj.Lang)
+	}
+	if obj.Transforms != nil {
+		applyTransforms(*obj.Transforms, &opts)
+	}
+	r.opts = &opts
+	return func() { r.opts = saved }
+}
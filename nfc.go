@@ -0,0 +1,18 @@
+package main
+
+import "golang.org/x/text/unicode/norm"
+
+// nfcFilter puts each line in Unicode normalization form C, for -nfc: a
+// letter followed by combining accents, such as "e" and U+0301, becomes the
+// single precomposed character "é", in every script. Decomposed text mostly
+// comes from macOS file names and the files that quote them.
+type nfcFilter struct{}
+
+func (nfcFilter) filter(line []byte) []byte {
+	if norm.NFC.IsNormal(line) {
+		return line
+	}
+	return norm.NFC.Bytes(line)
+}
+
+func (nfcFilter) flush() []byte { return nil }
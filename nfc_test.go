@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestNFCFilter(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain ascii\n", "plain ascii\n"},
+		{"caf\u00e9\n", "caf\u00e9\n"},
+		{"cafe\u0301\n", "caf\u00e9\n"},
+		{"Vie\u0302\u0323t\n", "Vi\u1ec7t\n"}, // Vietnamese, accents out of order
+		{"\u0391\u0301\n", "\u0386\n"},        // Greek
+		{"\u3046\u3099\n", "\u3094\n"},        // Hiragana
+		{"e\u0301\u0301", "\u00e9\u0301"},     // only one accent composes
+	}
+	for _, tt := range tests {
+		if got := string(nfcFilter{}.filter([]byte(tt.in))); got != tt.want {
+			t.Errorf("nfc(%+q) = %+q, want %+q", tt.in, got, tt.want)
+		}
+	}
+}
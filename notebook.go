@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A notebook is the part of a Jupyter .ipynb file that -render-notebooks
+// looks at.
+type notebook struct {
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"`
+		Outputs  []struct {
+			OutputType string                     `json:"output_type"`
+			Text       json.RawMessage            `json:"text"`
+			Data       map[string]json.RawMessage `json:"data"`
+			Ename      string                     `json:"ename"`
+			Evalue     string                     `json:"evalue"`
+		} `json:"outputs"`
+	} `json:"cells"`
+}
+
+// rendersNotebook reports whether name is a notebook that -render-notebooks
+// prints as code.
+func (r *runner) rendersNotebook(name string) bool {
+	return r.opts.notebooks && strings.EqualFold(filepath.Ext(name), ".ipynb")
+}
+
+// renderNotebook returns data, the contents of the notebook name, as its
+// code cells in order, each after a "# %%" line, and sets r.opts to fence
+// them in the notebook's language. It returns a func that puts the usual
+// options back. A notebook that can't be parsed is returned as it is, with
+// a warning.
+func (r *runner) renderNotebook(name string, data []byte) ([]byte, func()) {
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s is not a valid notebook (%v); printing it as is\n", name, err)
+		return data, func() {}
+	}
+	lang := langPython
+	for _, kernel := range []string{nb.Metadata.Kernelspec.Language, nb.Metadata.LanguageInfo.Name} {
+		if l, ok := namedLanguage(strings.ToLower(kernel)); ok && l.comment != "" {
+			lang = l
+			break
+		}
+	}
+	comment := func(b *bytes.Buffer, text string) {
+		for _, line := range splitLines([]byte(text)) {
+			if strings.TrimSpace(line) == "" {
+				b.WriteString(strings.TrimSpace(lang.comment))
+			} else {
+				b.WriteString(lang.comment)
+			}
+			b.WriteString(line)
+		}
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+
+	var b bytes.Buffer
+	for _, cell := range nb.Cells {
+		switch cell.CellType {
+		case "code":
+			fmt.Fprintf(&b, "%s%%%%\n", lang.comment)
+			source := notebookText(cell.Source)
+			b.WriteString(source)
+			if source != "" && !strings.HasSuffix(source, "\n") {
+				b.WriteByte('\n')
+			}
+		case "markdown":
+			if !r.opts.nbMarkdown {
+				continue
+			}
+			fmt.Fprintf(&b, "%s%%%% [markdown]\n", lang.comment)
+			comment(&b, notebookText(cell.Source))
+		default:
+			continue
+		}
+		if !r.opts.nbOutput {
+			continue
+		}
+		for _, out := range cell.Outputs {
+			var text string
+			switch out.OutputType {
+			case "stream":
+				text = notebookText(out.Text)
+			case "execute_result", "display_data":
+				if plain, ok := out.Data["text/plain"]; ok {
+					text = notebookText(plain)
+				}
+				// Images and other rich outputs are only named.
+				var kinds []string
+				for kind := range out.Data {
+					if kind != "text/plain" {
+						kinds = append(kinds, kind)
+					}
+				}
+				slices.Sort(kinds)
+				for _, kind := range kinds {
+					text += fmt.Sprintf("\n[%s output omitted]", kind)
+				}
+				text = strings.TrimPrefix(text, "\n")
+			case "error":
+				text = out.Ename + ": " + out.Evalue
+			}
+			if text == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "%sOutput:\n", lang.comment)
+			comment(&b, text)
+		}
+	}
+
+	saved := r.opts
+	opts := *saved
+	if opts.lang == "" {
+		opts.lang = lang.fence
+	}
+	r.opts = &opts
+	return b.Bytes(), func() { r.opts = saved }
+}
+
+// notebookText returns the text in raw, a notebook string, which may be
+// stored as one string or as a list of lines.
+func notebookText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var lines []string
+	json.Unmarshal(raw, &lines)
+	return strings.Join(lines, "")
+}
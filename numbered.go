@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// numberedTotal stands in for the number of files in -numbered headers
+// until the dump is done and it is known.
+const numberedTotal = "\x00llm-cat total\x00"
+
+// numberedSeparator is the line -numbered prints between blocks.
+var numberedSeparator = strings.Repeat("=", 72)
+
+// numberBlock returns the "[3/17] " that starts the header of the block
+// about to be printed for name with -numbered, first printing a separator
+// line if it isn't the first block. The parts of a file printed with
+// -chunk share its number.
+func (r *runner) numberBlock(name string) string {
+	if r.numberedName != "" {
+		fmt.Fprintf(r.out, "\n%s\n", numberedSeparator)
+	}
+	if name != r.numberedName {
+		r.numbered++
+		r.numberedName = name
+	}
+	return fmt.Sprintf("[%d/%s] ", r.numbered, numberedTotal)
+}
+
+// fillNumbered writes held, the output of a -numbered dump, to r.out with
+// the total number of files filled in.
+func (r *runner) fillNumbered(held *bytes.Buffer) {
+	r.out.Write(bytes.ReplaceAll(held.Bytes(), []byte(numberedTotal), []byte(strconv.Itoa(r.numbered))))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pdfToText is the program -pdf-text runs to extract text, from poppler.
+// Running it instead of parsing PDFs here keeps llm-cat free of a PDF
+// library that only some people need.
+const pdfToText = "pdftotext"
+
+// rendersPDF reports whether name is a PDF that -pdf-text prints as its
+// text.
+func (r *runner) rendersPDF(name string) bool {
+	return r.opts.pdfText && strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+// pdfText returns the text of data, a PDF, page by page with a form feed
+// between pages, as pdftotext lays it out. A PDF with no text in it, such
+// as a scan, gives only whitespace.
+func pdfText(data []byte) ([]byte, error) {
+	cmd := exec.Command(pdfToText, "-layout", "-enc", "UTF-8", "-", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("%s: %s", pdfToText, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("%s: %v", pdfToText, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// dropAbovePercentile leaves out the files larger than the
+// -skip-above-percentile percentile of the sizes of all of them, reporting
+// the cutoff on stderr. Sizes come from the walk, so nothing is read. Files
+// without a size of their own, such as stdin and symlinks, are kept.
+func (r *runner) dropAbovePercentile(files []fileEntry) []fileEntry {
+	var sizes []int64
+	for _, e := range files {
+		if sized(e) {
+			sizes = append(sizes, e.size)
+		}
+	}
+	if len(sizes) == 0 {
+		return files
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	// The nearest-rank percentile: the smallest size at least P percent
+	// of the files are no larger than.
+	rank := int(math.Ceil(r.opts.sizePercentile / 100 * float64(len(sizes))))
+	cutoff := sizes[max(rank, 1)-1]
+	var kept []fileEntry
+	for _, e := range files {
+		if !sized(e) || e.size <= cutoff {
+			kept = append(kept, e)
+		}
+	}
+	if !r.opts.quiet {
+		fmt.Fprintf(os.Stderr, "Skipped %d of %d files larger than %s (-skip-above-percentile %g)\n", len(files)-len(kept), len(sizes), r.size(cutoff), r.opts.sizePercentile)
+	}
+	return kept
+}
+
+// sized reports whether e is a file whose size the walk found.
+func sized(e fileEntry) bool {
+	return e.err == nil && e.link == "" && e.inline == nil && e.input == nil && e.path != "-"
+}
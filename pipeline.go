@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+)
+
+// When -io-workers or -cpu-workers is above 1, a dump runs as a pipeline:
+//
+//  1. Select: the walk picks the files to print, in order, as usual.
+//  2. Read: up to -io-workers files at a time are read into memory.
+//  3. Prepare: up to -cpu-workers files at a time are run through the
+//     content filters and, if tokens are being counted, the tokenizer.
+//  4. Print: files are printed one at a time in selection order, applying
+//     the limits that depend on what was printed before them.
+//
+// Stages 2 and 3 run ahead of stage 4 by a bounded window of files, which
+// caps the memory used. Their work is thrown away for files a limit then
+// skips or truncates; a truncated file is filtered again as it prints.
+
+// A prepared file holds the read-ahead work for one selected file. It reads
+// as the file's contents.
+type prepared struct {
+	*bytes.Reader
+	data     []byte
+	filtered []byte // data through the content filters, nil if there are none
+	tokens   int    // tokens in data, if they are being counted
+	ok       bool   // data was read; otherwise, print the file as usual
+	ready    chan struct{}
+}
+
+// wait waits for p's preparation and reports whether it can be used.
+func (p *prepared) wait() bool {
+	<-p.ready
+	return p.ok
+}
+
+// prefiltered is file contents that have already been through the filters.
+// n is the length of the original.
+type prefiltered struct {
+	*bytes.Reader
+	n int64
+}
+
+// pipelined reports whether dumps run as a pipeline.
+func (r *runner) pipelined() bool {
+	opts := r.opts
+	// -max-total-lines filters each file by what was printed before it,
+	// and -blame by what git says as it is printed, so files can't be
+	// filtered ahead. The read stage reads each file whole, which would
+	// leave -mmap and -buffer-size nothing to do.
+	return (opts.ioWorkers > 1 || opts.cpuWorkers > 1) && !opts.namesOnly && opts.exec == nil && opts.maxTotalLines == 0 && !opts.blame &&
+		!opts.mmap && opts.bufferSize == 0
+}
+
+// startPipeline starts reading and preparing files as they arrive, and
+// passes them on, in the same order, on the channel it returns. emit takes
+// each one's prepared from r.queue.
+func (r *runner) startPipeline(files <-chan fileEntry) <-chan fileEntry {
+	opts := r.opts
+	window := 2 * (opts.ioWorkers + opts.cpuWorkers)
+	r.queue = make(chan *prepared, window)
+	out := make(chan fileEntry, window)
+	ioSlots := make(chan struct{}, opts.ioWorkers)
+	cpuSlots := make(chan struct{}, opts.cpuWorkers)
+	queue := r.queue
+	go func() {
+		defer close(out)
+		for e := range files {
+			p := &prepared{ready: make(chan struct{})}
+			queue <- p
+			out <- e
+			// Stdin, symlinks that aren't followed, -ndjson-input
+			// objects, which have settings of their own, and files too
+			// big to print are left to the usual path.
+			if e.path == "-" || e.link != "" || e.input != nil || (opts.maxSize > 0 && e.size > opts.maxSize) {
+				close(p.ready)
+				continue
+			}
+			go func() {
+				defer close(p.ready)
+				ioSlots <- struct{}{}
+				data, ok := r.readRegular(e)
+				<-ioSlots
+				if !ok {
+					return
+				}
+				cpuSlots <- struct{}{}
+				r.prepare(p, e.path, data)
+				<-cpuSlots
+			}()
+		}
+	}()
+	return out
+}
+
+// readRegular returns the contents of the file e if it is a regular file.
+// Errors are left for the print stage to report.
+func (r *runner) readRegular(e fileEntry) ([]byte, bool) {
+	info := e.info
+	if info == nil {
+		var err error
+		if info, err = os.Stat(e.path); err != nil {
+			return nil, false
+		}
+	}
+	if !info.Mode().IsRegular() {
+		return nil, false
+	}
+	data, err := r.readFile(e.path)
+	return data, err == nil
+}
+
+// prepare does the CPU-bound work on the contents of the file name.
+func (r *runner) prepare(p *prepared, name string, data []byte) {
+	p.data, p.Reader, p.ok = data, bytes.NewReader(data), true
+	if r.binary(name, r.head(data)) {
+		return
+	}
+	if filters := buildFilters(name, r.opts); len(filters) > 0 {
+		var b bytes.Buffer
+		copyLines(&b, bytes.NewReader(data), filters)
+		p.filtered = b.Bytes()
+	}
+	if r.countingTokens() {
+		p.tokens = r.tok.count(data)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// parsePromptTemplate splits a -prompt-template into the text to print
+// before the files and the text to print after them, at its one {files}
+// placeholder.
+func parsePromptTemplate(text string) (before, after string, err error) {
+	switch strings.Count(text, "{files}") {
+	case 0:
+		return "", "", errors.New("no {files} placeholder")
+	case 1:
+	default:
+		return "", "", errors.New("more than one {files} placeholder")
+	}
+	before, after, _ = strings.Cut(text, "{files}")
+	return before, after, nil
+}
+
+// promptTotals reports whether s, part of a -prompt-template, uses the
+// totals that are only known once the files have been printed.
+func promptTotals(s string) bool {
+	return strings.Contains(s, "{count}") || strings.Contains(s, "{tokens}")
+}
+
+// expandPrompt fills in the {count} and {tokens} placeholders in s, part of a
+// -prompt-template, with the number of files printed and their tokens. Text
+// from -prepend and -append is returned as it is.
+func (r *runner) expandPrompt(s string) string {
+	if !r.template {
+		return s
+	}
+	return strings.NewReplacer(
+		"{count}", strconv.Itoa(r.files),
+		"{tokens}", strconv.FormatInt(r.tokenTotal(), 10),
+	).Replace(s)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+var (
+	pyImportName   = regexp.MustCompile(`^[A-Za-z_][\w.]*(\s+as\s+[A-Za-z_]\w*)?$`)
+	pyImportModule = regexp.MustCompile(`^\.*([A-Za-z_][\w.]*)?$`)
+)
+
+// pyImportFilter merges runs of top-level Python imports for
+// -compact-imports: consecutive "import a" and "import b" lines become
+// "import a, b", and consecutive "from m import a" and "from m import b"
+// become "from m import a, b". Only simple, unindented import statements
+// merge, so imports under an if, try or def are never touched; a comment,
+// parentheses, a continuation or an import of * ends the run, and so does any
+// other line. Lines inside triple-quoted strings are left alone.
+type pyImportFilter struct {
+	pending []byte   // the first line of the run, as written
+	module  string   // module of a from-import run; "" for plain imports
+	names   []string // names imported by the run so far
+	lines   int      // lines in the run
+	eol     []byte   // line ending of the run's last line
+	quote   string   // the triple quote of a string still open, if any
+}
+
+func (p *pyImportFilter) filter(line []byte) []byte {
+	text, eol := splitEOL(line)
+	if p.quote == "" {
+		if module, names, ok := parsePyImport(string(text)); ok {
+			if p.lines > 0 && module == p.module {
+				p.names = append(p.names, names...)
+				p.lines++
+				p.eol = eol
+				return nil
+			}
+			out := p.flush()
+			p.pending, p.module, p.names, p.lines, p.eol = bytes.Clone(line), module, names, 1, eol
+			return out
+		}
+	}
+	p.quote = pyOpenQuote(string(text), p.quote)
+	return append(p.flush(), line...)
+}
+
+func (p *pyImportFilter) flush() []byte {
+	switch lines := p.lines; {
+	case lines == 0:
+		return nil
+	case lines == 1:
+		p.lines = 0
+		return p.pending
+	}
+	p.lines = 0
+	s := "import " + strings.Join(p.names, ", ")
+	if p.module != "" {
+		s = "from " + p.module + " " + s
+	}
+	return append([]byte(s), p.eol...)
+}
+
+// parsePyImport parses text as an unindented "import a, b" or
+// "from m import a, b" statement simple enough to merge with another, and
+// returns the module ("" for a plain import) and the imported names.
+func parsePyImport(text string) (module string, names []string, ok bool) {
+	if strings.ContainsAny(text, "#;()\\*") {
+		return "", nil, false
+	}
+	rest, found := strings.CutPrefix(text, "import ")
+	if !found {
+		if rest, found = strings.CutPrefix(text, "from "); !found {
+			return "", nil, false
+		}
+		module, rest, found = strings.Cut(rest, " import ")
+		module = strings.TrimSpace(module)
+		if !found || module == "" || !pyImportModule.MatchString(module) {
+			return "", nil, false
+		}
+	}
+	for _, name := range strings.Split(rest, ",") {
+		name = strings.TrimSpace(name)
+		if !pyImportName.MatchString(name) {
+			return "", nil, false
+		}
+		names = append(names, name)
+	}
+	return module, names, true
+}
+
+// pyOpenQuote returns the triple quote that is still open at the end of text,
+// given the one (if any) that was open at its start.
+func pyOpenQuote(text, quote string) string {
+	for {
+		if quote != "" {
+			i := strings.Index(text, quote)
+			if i < 0 {
+				return quote
+			}
+			text, quote = text[i+3:], ""
+			continue
+		}
+		d, s := strings.Index(text, `"""`), strings.Index(text, `'''`)
+		switch {
+		case d < 0 && s < 0:
+			return ""
+		case s < 0 || (d >= 0 && d < s):
+			text, quote = text[d+3:], `"""`
+		default:
+			text, quote = text[s+3:], `'''`
+		}
+	}
+}
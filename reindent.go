@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// reindentLanguages are the languages whose meaning does not depend on
+// indentation, so -reindent can change it, with how each writes the string
+// literals that can span lines, whose insides must be left alone. Languages
+// such as Python, YAML and Makefile, where indentation matters, PHP, whose
+// heredocs are beyond a line scanner, and anything unrecognized are left
+// alone.
+var reindentLanguages = map[language]literalSyntax{
+	langC:          {comment: "//", quotes: `"'`},
+	langCPP:        {comment: "//", quotes: `"'`, cppRaw: true},
+	langCSharp:     {comment: "//", quotes: `"'`, multi: []multiLiteral{{`"""`, `"""`, false}, {`@"`, `"`, false}, {`$@"`, `"`, false}, {`@$"`, `"`, false}}},
+	langCSS:        {quotes: `"'`},
+	langGo:         {comment: "//", quotes: `"'`, multi: []multiLiteral{{"`", "`", false}}},
+	langJava:       {comment: "//", quotes: `"'`, multi: []multiLiteral{{`"""`, `"""`, true}}},
+	langJavaScript: {comment: "//", quotes: `"'`, multi: []multiLiteral{{"`", "`", true}}},
+	langJSON:       {quotes: `"`},
+	langKotlin:     {comment: "//", quotes: `"'`, multi: []multiLiteral{{`"""`, `"""`, false}}},
+	langLua:        {comment: "--", quotes: `"'`, multi: []multiLiteral{{"[[", "]]", false}, {"[=[", "]=]", false}, {"[==[", "]==]", false}}},
+	langRust:       {comment: "//", rustChars: true, multi: []multiLiteral{{`r#"`, `"#`, false}, {`r##"`, `"##`, false}, {`"`, `"`, true}}},
+	langSwift:      {comment: "//", quotes: `"`, multi: []multiLiteral{{`"""`, `"""`, true}}},
+	langTypeScript: {comment: "//", quotes: `"'`, multi: []multiLiteral{{"`", "`", true}}},
+}
+
+// literalSyntax is how a language writes comments and strings, as far as
+// -reindent needs to know to find the lines inside multi-line literals.
+type literalSyntax struct {
+	comment   string         // starts a comment to the end of the line
+	quotes    string         // quote characters of literals that end on their line
+	multi     []multiLiteral // literals that can span lines, longest opening first
+	cppRaw    bool           // C++ raw strings, R"delim(...)delim"
+	rustChars bool           // Rust character literals, told apart from lifetimes
+}
+
+// multiLiteral is a kind of string literal that can span lines.
+type multiLiteral struct {
+	open, close string
+	escapes     bool // a backslash escapes the next character
+}
+
+// reindentUnsafe returns why -reindent leaves the file name as it is, or ""
+// if it doesn't. Files are judged by name alone, since the filters only see
+// their contents once they are being printed.
+func reindentUnsafe(name string) string {
+	lang := detectLanguage(name, nil)
+	if _, ok := reindentLanguages[lang]; ok {
+		return ""
+	}
+	switch lang {
+	case langOther:
+		return "unknown language"
+	case langPHP:
+		return "strings can span lines in " + lang.name
+	default:
+		return "indentation may be significant in " + lang.name
+	}
+}
+
+// reindentFilter re-renders a file's indentation for -reindent, with each
+// level as level (N spaces, or a tab). It holds the whole file until flush, since the
+// file's own indentation unit can only be found by looking at all of it.
+//
+// A leading tab is one level, and a run of spaces is as many levels as the
+// file's most common indentation step fits into it; spaces left over (the
+// one before each " * " in a block comment, say) are kept as they are, and
+// so are lines that start inside a multi-line string literal such as a Go
+// raw string or a JavaScript template literal.
+type reindentFilter struct {
+	level  []byte
+	syntax literalSyntax
+	lines  [][]byte
+}
+
+func (f *reindentFilter) filter(line []byte) []byte {
+	f.lines = append(f.lines, bytes.Clone(line))
+	return nil
+}
+
+func (f *reindentFilter) flush() []byte {
+	inside := f.syntax.literalLines(f.lines)
+	var code [][]byte
+	for i, line := range f.lines {
+		if !inside[i] {
+			code = append(code, line)
+		}
+	}
+	unit := indentUnit(code)
+	var b bytes.Buffer
+	for i, line := range f.lines {
+		if inside[i] {
+			b.Write(line)
+			continue
+		}
+		tabs := len(line) - len(bytes.TrimLeft(line, "\t"))
+		rest := line[tabs:]
+		spaces := len(rest) - len(bytes.TrimLeft(rest, " "))
+		rest = rest[spaces:]
+		text, _ := splitEOL(rest)
+		if len(bytes.TrimSpace(text)) == 0 || (len(rest) > 0 && rest[0] == '\t') {
+			// Blank, or indented with spaces before tabs; there's no
+			// telling what that was meant to look like.
+			b.Write(line)
+			continue
+		}
+		levels, extra := tabs, spaces
+		if unit > 0 {
+			levels += spaces / unit
+			extra = spaces % unit
+		}
+		b.Write(bytes.Repeat(f.level, levels))
+		b.Write(bytes.Repeat([]byte(" "), extra))
+		b.Write(rest)
+	}
+	f.lines = nil
+	return b.Bytes()
+}
+
+// indentUnit returns the number of spaces that lines most often indent by
+// from one line to the next, or 0 if they are not indented with spaces.
+// Steps of a single space, as in aligned block comments, are not counted.
+func indentUnit(lines [][]byte) int {
+	steps := make(map[int]int)
+	prev := 0
+	for _, line := range lines {
+		text, _ := splitEOL(line)
+		if len(bytes.TrimSpace(text)) == 0 {
+			continue
+		}
+		n := len(text) - len(bytes.TrimLeft(text, " "))
+		if n > prev+1 {
+			steps[n-prev]++
+		}
+		prev = n
+	}
+	unit := 0
+	for step, count := range steps {
+		if count > steps[unit] || (count == steps[unit] && step < unit) {
+			unit = step
+		}
+	}
+	return unit
+}
+
+// literalLines reports, for each of lines, whether it starts inside a
+// multi-line string literal. Block comments are reindented like code, so
+// only a comment to the end of the line is skipped over.
+func (s literalSyntax) literalLines(lines [][]byte) []bool {
+	inside := make([]bool, len(lines))
+	var open *multiLiteral // the literal the scan is in, if any
+	var raw multiLiteral
+	for n, line := range lines {
+		inside[n] = open != nil
+		for i := 0; i < len(line); {
+			if open != nil {
+				switch {
+				case open.escapes && line[i] == '\\':
+					i += 2
+				case bytes.HasPrefix(line[i:], []byte(open.close)):
+					i += len(open.close)
+					open = nil
+				default:
+					i++
+				}
+				continue
+			}
+			rest := line[i:]
+			if s.comment != "" && bytes.HasPrefix(rest, []byte(s.comment)) {
+				break
+			}
+			if s.cppRaw && bytes.HasPrefix(rest, []byte(`R"`)) {
+				if paren := bytes.IndexByte(rest, '('); paren > 0 {
+					raw = multiLiteral{close: ")" + string(rest[2:paren]) + `"`}
+					open = &raw
+					i += paren + 1
+					continue
+				}
+			}
+			if m := s.opening(rest); m != nil {
+				open = m
+				i += len(m.open)
+				continue
+			}
+			switch c := line[i]; {
+			case s.rustChars && c == '\'':
+				i += rustCharLen(rest)
+			case strings.IndexByte(s.quotes, c) >= 0:
+				i += quotedLen(rest)
+			default:
+				i++
+			}
+		}
+	}
+	return inside
+}
+
+// opening returns the multi-line literal that rest starts with, if any.
+func (s literalSyntax) opening(rest []byte) *multiLiteral {
+	for i := range s.multi {
+		if bytes.HasPrefix(rest, []byte(s.multi[i].open)) {
+			return &s.multi[i]
+		}
+	}
+	return nil
+}
+
+// quotedLen returns the length of the quoted literal at the start of rest,
+// up to its closing quote or, if it has none, the end of the line.
+func quotedLen(rest []byte) int {
+	for i := 1; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			i++
+		case rest[0]:
+			return i + 1
+		}
+	}
+	return len(rest)
+}
+
+// rustCharLen returns the length of the Rust character literal at the start
+// of rest, or 1 for the quote that starts a lifetime such as 'a.
+func rustCharLen(rest []byte) int {
+	if len(rest) > 1 && rest[1] == '\\' {
+		return quotedLen(rest)
+	}
+	_, size := utf8.DecodeRune(rest[1:])
+	if 1+size < len(rest) && rest[1+size] == '\'' {
+		return 2 + size
+	}
+	return 1
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReindentFilter(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "main.go",
+			in:   "func f() {\n\tif x {\n\t\ty()\n\t}\n}\n",
+			want: "func f() {\n  if x {\n    y()\n  }\n}\n",
+		},
+		{
+			name: "main.go",
+			in:   "var s = `\n\tkeep\n\t\tthis\n`\nfunc f() {\n\tg()\n}\n",
+			want: "var s = `\n\tkeep\n\t\tthis\n`\nfunc f() {\n  g()\n}\n",
+		},
+		{
+			name: "main.go",
+			in:   "func f() {\n\t// a ` in a comment\n\tr := '`'\n\tg(\"`\")\n}\n",
+			want: "func f() {\n  // a ` in a comment\n  r := '`'\n  g(\"`\")\n}\n",
+		},
+		{
+			name: "app.ts",
+			in:   "function f() {\n    return `\n        <div>\\`${x}\\`</div>\n    `;\n}\n",
+			want: "function f() {\n  return `\n        <div>\\`${x}\\`</div>\n    `;\n}\n",
+		},
+		{
+			name: "Main.java",
+			in:   "class A {\n    String s = \"\"\"\n        text\n        \"\"\";\n}\n",
+			want: "class A {\n  String s = \"\"\"\n        text\n        \"\"\";\n}\n",
+		},
+		{
+			name: "lib.rs",
+			in:   "fn f<'a>(x: &'a str) {\n    let s = \"one\n    two\";\n    let c = '\"';\n    g();\n}\n",
+			want: "fn f<'a>(x: &'a str) {\n  let s = \"one\n    two\";\n  let c = '\"';\n  g();\n}\n",
+		},
+		{
+			name: "raw.cpp",
+			in:   "void f() {\n    auto s = R\"x(\n    keep )\" this\n    )x\";\n}\n",
+			want: "void f() {\n  auto s = R\"x(\n    keep )\" this\n    )x\";\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		f := &reindentFilter{level: []byte("  "), syntax: reindentLanguages[detectLanguage(tt.name, nil)]}
+		var got bytes.Buffer
+		for _, line := range strings.SplitAfter(tt.in, "\n") {
+			got.Write(f.filter([]byte(line)))
+		}
+		got.Write(f.flush())
+		if got.String() != tt.want {
+			t.Errorf("%s: reindent(%q) = %q, want %q", tt.name, tt.in, got.String(), tt.want)
+		}
+	}
+}
+
+func TestReindentUnsafe(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"main.go", ""},
+		{"app.py", "indentation may be significant in Python"},
+		{"index.php", "strings can span lines in PHP"},
+		{"notes.xyz", "unknown language"},
+	}
+	for _, tt := range tests {
+		if got := reindentUnsafe(tt.name); got != tt.want {
+			t.Errorf("reindentUnsafe(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A skipRecord is a file left out of the dump, and why.
+type skipRecord struct {
+	path   string
+	reason string // a -events skip reason
+}
+
+// errorCategory returns the skip reason for a file that failed with err.
+func errorCategory(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return "permission"
+	case errors.Is(err, fs.ErrNotExist):
+		return "not-found"
+	}
+	return "error"
+}
+
+// errorText returns what went wrong in err, such as "permission denied",
+// without the path and operation that an *fs.PathError starts with.
+func errorText(err error) string {
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		return perr.Err.Error()
+	}
+	return err.Error()
+}
+
+// reportSkips writes the -report-unreadable summary of the files skipped so
+// far to w: a count per reason, with the paths under -v, or with
+// -skip-report, one "reason<TAB>path" line per file. Nothing is written if
+// no file was skipped.
+func (r *runner) reportSkips(w io.Writer) {
+	if r.opts.skipReport {
+		for _, s := range r.skips {
+			fmt.Fprintf(w, "%s\t%s\n", s.reason, s.path)
+		}
+		return
+	}
+	if len(r.skips) == 0 {
+		return
+	}
+	byReason := make(map[string][]string)
+	for _, s := range r.skips {
+		byReason[s.reason] = append(byReason[s.reason], s.path)
+	}
+	reasons := make([]string, 0, len(byReason))
+	for reason := range byReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	counts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		counts[i] = fmt.Sprintf("%d %s", len(byReason[reason]), reason)
+	}
+	files := "files"
+	if len(r.skips) == 1 {
+		files = "file"
+	}
+	fmt.Fprintf(w, "Skipped %d %s: %s\n", len(r.skips), files, strings.Join(counts, ", "))
+	if r.opts.verbose {
+		for _, reason := range reasons {
+			for _, p := range byReason[reason] {
+				fmt.Fprintf(w, "  %s: %s\n", reason, p)
+			}
+		}
+	}
+}
+
+// summarizeSkips says on stderr how many files were skipped for each
+// reason, for -summarize-skips, in one line such as "Skipped 1,204 binary
+// files, 12 too-large, 3 no-match", most common reason first. Nothing is
+// written if no file was skipped.
+func (r *runner) summarizeSkips() {
+	if len(r.skips) == 0 {
+		return
+	}
+	counts := make(map[string]int)
+	var reasons []string
+	for _, s := range r.skips {
+		if counts[s.reason] == 0 {
+			reasons = append(reasons, s.reason)
+		}
+		counts[s.reason]++
+	}
+	sort.SliceStable(reasons, func(i, j int) bool { return counts[reasons[i]] > counts[reasons[j]] })
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = thousands(counts[reason]) + " " + reason
+	}
+	files := " files"
+	if counts[reasons[0]] == 1 {
+		files = " file"
+	}
+	parts[0] += files
+	fmt.Fprintf(os.Stderr, "Skipped %s\n", strings.Join(parts, ", "))
+}
+
+// reportExtSkips says on stderr how many files -max-per-ext left out, and
+// with -v how many of each extension.
+func (r *runner) reportExtSkips() {
+	exts := make([]string, 0, len(r.extSkips))
+	total := 0
+	for ext, n := range r.extSkips {
+		exts = append(exts, ext)
+		total += n
+	}
+	fmt.Fprintf(os.Stderr, "Skipped %d files over -max-per-ext %d\n", total, r.opts.maxPerExt)
+	if !r.opts.verbose {
+		return
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if a, b := r.extSkips[exts[i]], r.extSkips[exts[j]]; a != b {
+			return a > b
+		}
+		return exts[i] < exts[j]
+	})
+	for _, ext := range exts {
+		name := ext
+		if name == "" {
+			name = "(no extension)"
+		}
+		fmt.Fprintf(os.Stderr, "  %s: %d\n", name, r.extSkips[ext])
+	}
+}
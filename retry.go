@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// retryDelay is how long -retry waits before the first retry. The wait
+// doubles after each one.
+const retryDelay = 100 * time.Millisecond
+
+// readFile reads the file at path. With -retry, a read that fails in a way
+// that may be transient is tried again, up to that many more times.
+func (r *runner) readFile(path string) ([]byte, error) {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		data, err := os.ReadFile(path)
+		if err == nil || attempt == r.opts.retry || !transient(err) {
+			if attempt > 0 && r.opts.verbose {
+				fmt.Fprintf(os.Stderr, "Retried %s %d times\n", path, attempt)
+			}
+			return data, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// transient reports whether err could go away by trying again, as a network
+// filesystem's timeouts and I/O errors can; a missing file or a permission
+// problem won't.
+func transient(err error) bool {
+	return !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission) && !errors.Is(err, fs.ErrInvalid)
+}
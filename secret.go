@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+)
+
+// A secretDetector recognizes one kind of credential.
+type secretDetector struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// secretDetectors are the patterns -fail-on-secret looks for. They aim at
+// well-known token formats, which rarely match by accident, plus quoted
+// values assigned to names like password or api_key.
+var secretDetectors = []secretDetector{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"Stripe live key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{"secret assignment", regexp.MustCompile(`(?i)\b(api[_-]?key|secret[_-]?key|client[_-]?secret|password|passwd|access[_-]?token|auth[_-]?token)["']?\s*[:=]\s*["'][^"'\s]{8,}["']`)},
+}
+
+// secretWord is a run of the characters that random tokens and keys, in
+// base64 or hex among others, are made of.
+var secretWord = regexp.MustCompile(`[A-Za-z0-9+/=_\-]+`)
+
+// A secretFinding is where a secretDetector matched.
+type secretFinding struct {
+	detector string
+	line     int
+}
+
+// findSecrets returns the first line of data that each detector matches,
+// and the first run of at least minLen token characters whose entropy is
+// at least threshold bits per character, unless threshold is 0.
+func findSecrets(data []byte, threshold float64, minLen int) []secretFinding {
+	var found []secretFinding
+	for _, d := range secretDetectors {
+		if loc := d.re.FindIndex(data); loc != nil {
+			found = append(found, secretFinding{d.name, bytes.Count(data[:loc[0]], []byte("\n")) + 1})
+		}
+	}
+	if threshold > 0 {
+		if at, bits, ok := highEntropy(data, threshold, minLen); ok {
+			found = append(found, secretFinding{fmt.Sprintf("high-entropy string (%.2f bits per character)", bits), bytes.Count(data[:at], []byte("\n")) + 1})
+		}
+	}
+	return found
+}
+
+// highEntropy returns where the first run of at least minLen token
+// characters in data starts whose Shannon entropy is at least threshold
+// bits per character, and its entropy. Runs without both letters and
+// digits, such as long identifiers, don't count.
+func highEntropy(data []byte, threshold float64, minLen int) (int, float64, bool) {
+	for _, loc := range secretWord.FindAllIndex(data, -1) {
+		word := data[loc[0]:loc[1]]
+		if len(word) < minLen || !bytes.ContainsAny(word, "0123456789") || bytes.IndexFunc(word, isASCIILetter) < 0 {
+			continue
+		}
+		if bits := entropy(word); bits >= threshold {
+			return loc[0], bits, true
+		}
+	}
+	return 0, 0, false
+}
+
+// entropy returns the Shannon entropy of the bytes of s, in bits per byte.
+func entropy(s []byte) float64 {
+	var counts [256]int
+	for _, c := range s {
+		counts[c]++
+	}
+	bits := 0.0
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			bits -= p * math.Log2(p)
+		}
+	}
+	return bits
+}
+
+func isASCIILetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
+
+// checkSecrets reads every text file that files selects and reports on
+// stderr each one that holds something that looks like a secret, naming the
+// kind of secret and its line but not the secret itself. It returns the
+// number of files reported.
+func (r *runner) checkSecrets(files *pathList) int {
+	flagged := 0
+	walkFiles(files, r.opts, func(e fileEntry) error {
+		if e.err != nil || e.link != "" || e.path == "-" {
+			return nil
+		}
+		data := e.inline
+		if data == nil {
+			var err error
+			if data, err = os.ReadFile(e.path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.path, err)
+				return nil
+			}
+		}
+		if r.binary(e.path, r.head(data)) {
+			return nil
+		}
+		found := findSecrets(data, r.opts.entropyMin, r.opts.secretMinLen)
+		for _, f := range found {
+			fmt.Fprintf(os.Stderr, "Possible secret in %s line %d: %s\n", r.displayName(e.path), f.line, f.detector)
+		}
+		if len(found) > 0 {
+			flagged++
+		}
+		return nil
+	})
+	return flagged
+}
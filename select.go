@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// testFilePatterns match the base names of test files for -exclude-tests and
+// -only-tests, and testDirNames the directories whose contents are all tests.
+// Add to them to recognize more conventions.
+var (
+	testFilePatterns = []string{
+		"*_test.go",
+		"test_*.py",
+		"*_test.py",
+		"*.test.js", "*.spec.js",
+		"*.test.jsx", "*.spec.jsx",
+		"*.test.ts", "*.spec.ts",
+		"*.test.tsx", "*.spec.tsx",
+	}
+	testDirNames = map[string]bool{
+		"tests":     true,
+		"__tests__": true,
+	}
+)
+
+// junkDirNames are the directories -skip-common-junk prunes: dependencies,
+// build output and caches that are almost never worth dumping. Hidden ones
+// such as .venv only matter with -a.
+var junkDirNames = []string{
+	"node_modules", "bower_components", "vendor",
+	"target", "build", "dist", "out",
+	"__pycache__", ".venv", "venv", ".tox", ".mypy_cache", ".pytest_cache",
+	".gradle", ".next", ".nuxt", "coverage",
+}
+
+// langPresets are the file name patterns that each -lang preset selects.
+// Keep them to the files someone working in the stack would expect to see.
+var langPresets = map[string][]string{
+	"go":     {"*.go", "go.mod", "go.sum"},
+	"web":    {"*.html", "*.css", "*.js", "*.ts", "*.jsx", "*.tsx"},
+	"python": {"*.py", "*.pyi"},
+	"rust":   {"*.rs", "Cargo.toml"},
+	"c":      {"*.c", "*.h", "*.cc", "*.cpp", "*.hpp"},
+}
+
+// expandLangs returns the include patterns for a comma-separated list of
+// -lang presets.
+func expandLangs(list string) ([]string, error) {
+	var patterns []string
+	for _, name := range strings.Split(list, ",") {
+		preset, ok := langPresets[strings.TrimSpace(name)]
+		if !ok {
+			known := make([]string, 0, len(langPresets))
+			for name := range langPresets {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown -lang preset %q (known presets: %s)", name, strings.Join(known, ", "))
+		}
+		patterns = append(patterns, preset...)
+	}
+	return patterns, nil
+}
+
+// loadPatterns reads glob patterns from the file at path, one per line,
+// skipping blank lines and # comments.
+func loadPatterns(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, nil
+}
+
+// loadExcludes reads -exclude-from patterns from the file at path, as
+// loadPatterns does. Patterns are written as in a .gitignore file, so a
+// leading or trailing slash is dropped: a pattern with a slash in it is
+// matched against the whole path, and one without against each name.
+// Negated (!) patterns aren't supported.
+func loadExcludes(path string) ([]string, error) {
+	patterns, err := loadPatterns(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range patterns {
+		if strings.HasPrefix(p, "!") {
+			return nil, fmt.Errorf("%s: negated pattern %q isn't supported", path, p)
+		}
+		p = strings.TrimPrefix(strings.TrimSuffix(p, "/"), "/")
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("%s: bad pattern %q: %v", path, patterns[i], err)
+		}
+		patterns[i] = p
+	}
+	return patterns, nil
+}
+
+// A stringList is a flag that may be given more than once.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// selects reports whether the file at p passes the name filters in opts.
+func selects(p string, opts *options) bool {
+	if !matchesExtension(p, opts.extension, opts.caseExt) || matchesAny(p, opts.exclude) {
+		return false
+	}
+	if len(opts.include) > 0 && !matchesAny(p, opts.include) {
+		return false
+	}
+	if opts.pathRegex != nil && !opts.pathRegex.MatchString(filepath.ToSlash(filepath.Clean(p))) {
+		return false
+	}
+	switch {
+	case opts.excludeTests:
+		return !isTest(p)
+	case opts.onlyTests:
+		return isTest(p)
+	}
+	return true
+}
+
+// prunes reports whether the walk should skip the directory at p entirely.
+// With -v, it says on stderr which directories -skip-vendored prunes.
+func prunes(p string, opts *options) bool {
+	base := filepath.Base(p)
+	if matchesAny(p, opts.exclude) || (opts.excludeTests && testDirNames[base]) || opts.junkDirs[base] {
+		return true
+	}
+	if opts.skipSubmodules && isSubmodule(p, false) {
+		if opts.verbose && !opts.quiet {
+			fmt.Fprintf(os.Stderr, "Skipping submodule %s\n", p)
+		}
+		return true
+	}
+	if opts.skipVendored {
+		if why := vendored(p); why != "" {
+			if opts.verbose && !opts.quiet {
+				fmt.Fprintf(os.Stderr, "Skipping vendored directory %s (%s)\n", p, why)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// newEnough reports whether the file described by info was modified late
+// enough for -newer-than.
+func newEnough(info os.FileInfo, opts *options) bool {
+	return opts.newerThan.IsZero() || info.ModTime().After(opts.newerThan)
+}
+
+// parseAge parses a -newer-than duration: anything time.ParseDuration
+// accepts, or a whole number of days or weeks such as 7d or 2w.
+func parseAge(s string) (time.Duration, error) {
+	unit := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[s[len(s)-1]]
+	if unit != 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("negative duration %q", s)
+	}
+	return d, err
+}
+
+// matchesAny reports whether p matches one of patterns, either by its base
+// name or as a whole (slash-separated) path, where ** matches any number of
+// directories (see matchGlob).
+func matchesAny(p string, patterns []string) bool {
+	slashed := filepath.ToSlash(filepath.Clean(p))
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, path.Base(slashed)); ok {
+			return true
+		}
+		if matchGlob(pattern, slashed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTest reports whether p is a test file by its name or by a directory it
+// is in.
+func isTest(p string) bool {
+	base := filepath.Base(p)
+	for _, pattern := range testFilePatterns {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(p)), "/") {
+		if testDirNames[dir] {
+			return true
+		}
+	}
+	return false
+}
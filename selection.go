@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// selection returns the names of the files that files selects, as they would
+// appear in a dump, sorted.
+func (r *runner) selection(files *pathList) []string {
+	var names []string
+	walkFiles(files, r.opts, func(e fileEntry) error {
+		if e.err != nil {
+			return e.err
+		}
+		name := e.path
+		if name == "-" {
+			name = r.opts.stdinName
+		}
+		names = append(names, r.displayName(name))
+		return nil
+	})
+	sort.Strings(names)
+	return names
+}
+
+// compareSelection prints, in place of a dump, how the files that files
+// selects differ from the list saved at diffPath by -save-selection: "+ name"
+// for each file that is new to the selection and "- name" for each that is
+// no longer in it. The output goes to out, or stdout if it is empty. If
+// savePath is set, the selection is then saved there, so the same file can
+// be used as the baseline for the next run. Either path may be empty.
+func (r *runner) compareSelection(files *pathList, diffPath, savePath, out string) error {
+	names := r.selection(files)
+	if diffPath != "" {
+		data, err := os.ReadFile(diffPath)
+		if err != nil {
+			return err
+		}
+		saved := make(map[string]bool)
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSuffix(line, "\r"); line != "" {
+				saved[line] = true
+			}
+		}
+		var added, removed []string
+		for _, name := range names {
+			if !saved[name] {
+				added = append(added, name)
+			}
+			delete(saved, name)
+		}
+		for name := range saved {
+			removed = append(removed, name)
+		}
+		sort.Strings(removed)
+		if err := writeLines(out, func(w *bufio.Writer) {
+			for _, name := range added {
+				fmt.Fprintf(w, "+ %s\n", name)
+			}
+			for _, name := range removed {
+				fmt.Fprintf(w, "- %s\n", name)
+			}
+		}); err != nil {
+			return err
+		}
+		if !r.opts.quiet {
+			fmt.Fprintf(os.Stderr, "%d files selected: %d added, %d removed since %s\n", len(names), len(added), len(removed), diffPath)
+		}
+	}
+	if savePath != "" {
+		return writeLines(savePath, func(w *bufio.Writer) {
+			for _, name := range names {
+				fmt.Fprintln(w, name)
+			}
+		})
+	}
+	return nil
+}
+
+// writeLines calls write with a buffered writer for the file at path,
+// replacing its contents, or for stdout if path is empty.
+func writeLines(path string, write func(w *bufio.Writer)) error {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	write(w)
+	err := w.Flush()
+	if path != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
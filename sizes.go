@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// humanSize formats n bytes in IEC units, as "512 B", "3.2 KiB" or
+// "10.0 MiB".
+func humanSize(n int64) string {
+	if n < 1024 && n > -1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	f := float64(n) / 1024
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for (f >= 1024 || f <= -1024) && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", f, units[i])
+}
+
+// size formats n bytes for a message: "10485760 bytes", or with -human
+// "10.0 MiB".
+func (r *runner) size(n int64) string {
+	if r.opts.human {
+		return humanSize(n)
+	}
+	return strconv.FormatInt(n, 10) + " bytes"
+}
+
+// sizeCell formats n bytes for a column of a table of byte counts:
+// "10485760", or if human is set "10.0 MiB".
+func sizeCell(n int64, human bool) string {
+	if human {
+		return humanSize(n)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// parseExtSizes parses a -max-bytes-ext list such as ".json=50000,.go=500000"
+// into byte limits by lower-case extension. The leading dot may be left off.
+func parseExtSizes(s string) (map[string]int64, error) {
+	limits := make(map[string]int64)
+	for _, item := range strings.Split(s, ",") {
+		ext, n, ok := strings.Cut(strings.TrimSpace(item), "=")
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !ok || ext == "" || ext == "." {
+			return nil, fmt.Errorf("%q is not ext=bytes", item)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		limit, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("%q is not a number of bytes", n)
+		}
+		limits[ext] = limit
+	}
+	return limits, nil
+}
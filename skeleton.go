@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// skeletonIntro starts the second part of a -skeleton dump.
+const skeletonIntro = "Write each line you want to change after its number below, and leave the\nother lines empty.\n"
+
+// skeleton writes files to path (or stdout if it is empty) for -skeleton, in
+// place of a dump: first each file in full with numbered lines, under
+// "# Reference", then under "# Edits" the same headers and line numbers
+// with nothing after them, for a model to fill in with its changes. Binary
+// files and files over -max-size are skipped.
+func (r *runner) skeleton(files *pathList, path string) error {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	var slots bytes.Buffer
+	fmt.Fprintln(w, "# Reference")
+	walkFiles(files, r.opts, func(e fileEntry) error {
+		if e.err != nil {
+			return e.err
+		}
+		if e.link != "" || e.path == "-" || e.inline != nil {
+			return nil
+		}
+		if r.opts.maxSize > 0 && e.size > r.opts.maxSize {
+			r.skip(e.path, "too-large", "%s (size %s exceeds limit %s)", e.path, r.size(e.size), r.size(r.opts.maxSize))
+			return nil
+		}
+		data, err := os.ReadFile(e.path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.path, err)
+			return nil
+		}
+		if r.binary(e.path, r.head(data)) {
+			r.skip(e.path, "binary", "binary file %s", e.path)
+			return nil
+		}
+		lines := splitLines(data)
+		width := len(fmt.Sprint(len(lines)))
+		name := r.displayName(e.path)
+		fmt.Fprintf(w, "\n--- %s ---\n", name)
+		for i, line := range lines {
+			if line = strings.TrimSuffix(line, "\n"); line == "" {
+				fmt.Fprintf(w, "%*d |\n", width, i+1)
+			} else {
+				fmt.Fprintf(w, "%*d | %s\n", width, i+1, line)
+			}
+		}
+		span := fmt.Sprintf("lines 1-%d", len(lines))
+		if len(lines) == 0 {
+			span = "empty"
+		}
+		fmt.Fprintf(&slots, "\n--- %s (%s) ---\n", name, span)
+		for i := range lines {
+			fmt.Fprintf(&slots, "%*d |\n", width, i+1)
+		}
+		return nil
+	})
+	fmt.Fprintf(w, "\n# Edits\n\n%s", skeletonIntro)
+	slots.WriteTo(w)
+	err := w.Flush()
+	if path != "" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// smartHeaderStart and smartHeaderEnd mark a file's header and the blank
+// line that closes its block with -smart-header, until the dump is done and
+// it is known whether they stay.
+const (
+	smartHeaderStart = "\x00llm-cat header\x00"
+	smartHeaderEnd   = "\x00llm-cat header end\x00"
+)
+
+var smartHeaderMarked = regexp.MustCompile("(?s)" + smartHeaderStart + ".*?" + smartHeaderEnd)
+
+// smartHeader returns s, part of the delimiters of the block being printed,
+// marked for -smart-header to take out if the block turns out to be the
+// only one.
+func (r *runner) smartHeader(s string) string {
+	if !r.opts.smartHeader {
+		return s
+	}
+	return smartHeaderStart + s + smartHeaderEnd
+}
+
+// fillSmartHeaders writes held, the output of a -smart-header dump, to
+// r.out: without the delimiters of its one block if only one was printed,
+// or with them all otherwise.
+func (r *runner) fillSmartHeaders(held *bytes.Buffer) {
+	b := held.Bytes()
+	if r.blocks == 1 {
+		r.out.Write(smartHeaderMarked.ReplaceAll(b, nil))
+		return
+	}
+	b = bytes.ReplaceAll(b, []byte(smartHeaderStart), nil)
+	r.out.Write(bytes.ReplaceAll(b, []byte(smartHeaderEnd), nil))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// fifoTimeout is how long -allow-fifo waits for a named pipe to be opened by
+// a writer, and then for each read from it, before giving up.
+const fifoTimeout = 10 * time.Second
+
+// fileKind describes the type of a file that is not a regular file.
+func fileKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	case mode.IsDir():
+		return "directory"
+	default:
+		return "special file"
+	}
+}
+
+// handleFIFO prints the contents of a named pipe. Opening a pipe blocks until
+// there is a writer, and reading blocks until the writer sends data or goes
+// away, so both are bounded by fifoTimeout.
+func (r *runner) handleFIFO(path string, limit int64) (int64, error) {
+	type result struct {
+		f   *os.File
+		err error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		f, err := os.Open(path)
+		opened <- result{f, err}
+	}()
+
+	var file *os.File
+	select {
+	case res := <-opened:
+		if res.err != nil {
+			return 0, res.err
+		}
+		file = res.f
+	case <-time.After(fifoTimeout):
+		// The goroutine stays blocked in open until a writer shows up
+		// or we exit; the file is closed if it ever gets opened.
+		go func() {
+			if res := <-opened; res.f != nil {
+				res.f.Close()
+			}
+		}()
+		return 0, fmt.Errorf("timed out after %v waiting for a writer", fifoTimeout)
+	}
+	defer file.Close()
+	return r.printContents(path, &deadlineReader{file}, limit)
+}
+
+// deadlineReader fails a Read that does not complete within fifoTimeout.
+type deadlineReader struct {
+	f *os.File
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	// Pipes opened by os.Open support deadlines on most platforms; if
+	// this one doesn't, fall back to a plain blocking read.
+	_ = d.f.SetReadDeadline(time.Now().Add(fifoTimeout))
+	return d.f.Read(p)
+}
+
+// waitForInput returns a reader for the rest of in once it has something to
+// read, or has ended, and false if neither happens within timeout. The read
+// that was waited on is left running if it times out.
+func waitForInput(in io.Reader, timeout time.Duration) (io.Reader, bool) {
+	br := bufio.NewReader(in)
+	ready := make(chan struct{})
+	go func() {
+		br.Peek(1)
+		close(ready)
+	}()
+	select {
+	case <-ready:
+		return br, true
+	case <-time.After(timeout):
+		return nil, false
+	}
+}
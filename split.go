@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// A splitWriter writes -split-output parts: path.001, path.002 and so on,
+// each kept under size bytes. What is written is held until boundary is
+// called between files, so that a new part is only started between two
+// files and no file is cut across parts. A file too large for a part of its
+// own still gets one, over the size.
+type splitWriter struct {
+	path  string
+	size  int64
+	parts []splitPart
+	f     *os.File
+	held  bytes.Buffer
+	err   error
+}
+
+// A splitPart is one file written by a splitWriter.
+type splitPart struct {
+	name string
+	size int64
+}
+
+func (s *splitWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.held.Write(p)
+}
+
+// boundary writes out what has been held since the last call, first
+// starting a new part if it won't fit in the current one.
+func (s *splitWriter) boundary() error {
+	if s.err != nil || s.held.Len() == 0 {
+		return s.err
+	}
+	n := int64(s.held.Len())
+	if s.f == nil || s.current().size > 0 && s.current().size+n > s.size {
+		if s.err = s.closePart(); s.err != nil {
+			return s.err
+		}
+		name := fmt.Sprintf("%s.%03d", s.path, len(s.parts)+1)
+		if s.f, s.err = os.Create(name); s.err != nil {
+			return s.err
+		}
+		s.parts = append(s.parts, splitPart{name: name})
+	}
+	_, s.err = s.held.WriteTo(s.f)
+	s.current().size += n
+	return s.err
+}
+
+func (s *splitWriter) current() *splitPart { return &s.parts[len(s.parts)-1] }
+
+func (s *splitWriter) closePart() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// close writes out anything still held and closes the last part.
+func (s *splitWriter) close() error {
+	err := s.boundary()
+	if cerr := s.closePart(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// reportParts lists the parts written on stderr, with their sizes as
+// formatted by size.
+func (s *splitWriter) reportParts(size func(int64) string) {
+	fmt.Fprintf(os.Stderr, "Wrote %d parts:\n", len(s.parts))
+	for _, p := range s.parts {
+		over := ""
+		if p.size > s.size {
+			over = " (over -split-output)"
+		}
+		fmt.Fprintf(os.Stderr, "  %s  %s%s\n", p.name, size(p.size), over)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import "os"
+
+// statFile and lstatFile are the stats made of each file named or walked,
+// which -concurrent-stat-prefetch runs concurrently. Benchmarks replace them
+// to stand in for a slow filesystem.
+var (
+	statFile  = os.Stat
+	lstatFile = os.Lstat
+)
+
+// prefetchStats calls walk for each path in files, in order, as files.each
+// does, along with what os.Stat said about it, for -concurrent-stat-prefetch.
+// Up to workers paths are stat'ed at once, running a bounded window of paths
+// ahead of walk, so that on a high-latency filesystem walk rarely waits for
+// one. walk gets a nil os.FileInfo for a path that failed to stat, which is
+// stat'ed again to report the error, and for stdin, -mixed-stdin text and
+// -ndjson-input objects, which aren't stat'ed.
+//
+// Everything but the stats runs on the calling goroutine, since files.each
+// adds to files.notes and files.objects as it reads stdin.
+func prefetchStats(files *pathList, workers int, walk func(string, os.FileInfo)) error {
+	type ahead struct {
+		path string
+		info os.FileInfo
+		done chan struct{}
+	}
+	sem := make(chan struct{}, workers)
+	var queue []*ahead
+	next := func() {
+		a := queue[0]
+		queue = queue[1:]
+		<-a.done
+		walk(a.path, a.info)
+	}
+	err := files.each(func(f string) {
+		a := &ahead{path: f, done: make(chan struct{})}
+		_, note := files.notes[f]
+		if _, obj := files.objects[f]; note || obj || f == "-" {
+			close(a.done)
+		} else {
+			go func() {
+				sem <- struct{}{}
+				defer func() {
+					<-sem
+					close(a.done)
+				}()
+				if info, err := statFile(f); err == nil {
+					a.info = info
+				}
+			}()
+		}
+		queue = append(queue, a)
+		if len(queue) > 2*workers {
+			next()
+		}
+	})
+	for len(queue) > 0 {
+		next()
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// dumpStats totals what a dump printed, for -stats. Its fields are the ones
+// a -stat-format template can refer to.
+type dumpStats struct {
+	FileCount   int
+	TotalBytes  int64
+	TotalLines  int64
+	TotalTokens int64
+	ByExt       map[string]*extStats // by lower-case extension, "" for none
+
+	// The lines of the files in a language with line comments, by kind.
+	CodeLines, CommentLines, BlankLines int64
+}
+
+// extStats totals the files with one extension.
+type extStats struct {
+	Files int
+	Bytes int64
+	Lines int64
+
+	// Classified files are the ones in a language with line comments,
+	// whose lines are also counted by kind.
+	Classified           int
+	Code, Comment, Blank int64
+}
+
+func newDumpStats() *dumpStats {
+	return &dumpStats{ByExt: make(map[string]*extStats)}
+}
+
+// add counts a printed file.
+func (s *dumpStats) add(name string, bytes, lines int64) {
+	ext := strings.ToLower(filepath.Ext(name))
+	e := s.ByExt[ext]
+	if e == nil {
+		e = &extStats{}
+		s.ByExt[ext] = e
+	}
+	e.Files++
+	e.Bytes += bytes
+	e.Lines += lines
+	s.FileCount++
+	s.TotalBytes += bytes
+	s.TotalLines += lines
+}
+
+// addKinds counts the lines of a printed file by kind, if k counted them.
+func (s *dumpStats) addKinds(name string, k *lineKinds) {
+	if k == nil {
+		return
+	}
+	k.finish()
+	e := s.ByExt[strings.ToLower(filepath.Ext(name))]
+	e.Classified++
+	e.Code += k.code
+	e.Comment += k.comment
+	e.Blank += k.blank
+	s.CodeLines += k.code
+	s.CommentLines += k.comment
+	s.BlankLines += k.blank
+}
+
+// write writes the report to w using tmpl, or as a table if tmpl is nil,
+// with -human sizes in the table if human is set.
+func (s *dumpStats) write(w io.Writer, tmpl *template.Template, tok tokenizer, human bool) error {
+	if tmpl != nil {
+		if err := tmpl.Execute(w, s); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	}
+
+	exts := make([]string, 0, len(s.ByExt))
+	for ext := range s.ByExt {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Extension\tFiles\tLines\tCode\tComment\tBlank\tBytes\t")
+	classified := false
+	for _, ext := range exts {
+		e := s.ByExt[ext]
+		if ext == "" {
+			ext = "(none)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", ext, e.Files, e.Lines, kindCell(e.Code, e.Classified > 0), kindCell(e.Comment, e.Classified > 0), kindCell(e.Blank, e.Classified > 0), sizeCell(e.Bytes, human))
+		classified = classified || e.Classified > 0
+	}
+	fmt.Fprintf(tw, "Total\t%d\t%d\t%s\t%s\t%s\t%s\t\n", s.FileCount, s.TotalLines, kindCell(s.CodeLines, classified), kindCell(s.CommentLines, classified), kindCell(s.BlankLines, classified), sizeCell(s.TotalBytes, human))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Tokens: %d (%s)\n", s.TotalTokens, tok)
+	return err
+}
+
+// kindCell formats a count of lines of one kind for the -stats table, or
+// "-" if none of the files counted were classified.
+func kindCell(n int64, classified bool) string {
+	if !classified {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+// lineKinds counts the lines written through it as code, comments or blank
+// for -stats. A comment line is one that starts with the language's line
+// comment marker, after any indentation; the lines inside block comments
+// count as code.
+type lineKinds struct {
+	marker               []byte
+	code, comment, blank int64
+
+	pending bool   // the current line has begun
+	text    bool   // and has something other than spaces in it
+	lead    []byte // its first bytes after any indentation, up to len(marker)
+}
+
+// lineKindsFor returns a lineKinds for the file name, whose contents start
+// with sample, or nil if -stats isn't set or its language has no line
+// comments.
+func (r *runner) lineKindsFor(name string, sample []byte) *lineKinds {
+	if !r.opts.stats {
+		return nil
+	}
+	marker := strings.TrimSpace(detectLanguage(name, sample).comment)
+	if marker == "" {
+		return nil
+	}
+	return &lineKinds{marker: []byte(marker)}
+}
+
+func (k *lineKinds) count(p []byte) {
+	for _, c := range p {
+		if c == '\n' {
+			k.endLine()
+			continue
+		}
+		k.pending = true
+		if !k.text {
+			if c == ' ' || c == '\t' || c == '\r' {
+				continue
+			}
+			k.text = true
+		}
+		if len(k.lead) < len(k.marker) {
+			k.lead = append(k.lead, c)
+		}
+	}
+}
+
+func (k *lineKinds) endLine() {
+	switch {
+	case !k.text:
+		k.blank++
+	case bytes.Equal(k.lead, k.marker):
+		k.comment++
+	default:
+		k.code++
+	}
+	k.pending, k.text, k.lead = false, false, k.lead[:0]
+}
+
+// finish counts a last line with no newline.
+func (k *lineKinds) finish() {
+	if k.pending {
+		k.endLine()
+	}
+}
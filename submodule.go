@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// submodules caches what -skip-submodules and -submodule-headers have
+// learned about directories, since every file and directory walked asks.
+var submodules struct {
+	sync.Mutex
+	lists map[string]map[string]bool // absolute submodule paths listed by the .gitmodules in each directory
+	dirs  map[string]bool            // whether each directory is a submodule
+	of    map[string]string          // the submodule holding each directory, or ""
+}
+
+// isSubmodule reports whether dir is the work tree of a git submodule: it
+// has a .git file, as submodules are checked out with, and the .gitmodules
+// of a directory above it lists it. The first time a submodule is found,
+// it is reported on stderr under -v.
+func isSubmodule(dir string, verbose bool) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	submodules.Lock()
+	defer submodules.Unlock()
+	if is, ok := submodules.dirs[abs]; ok {
+		return is
+	}
+	is := false
+	if info, err := os.Lstat(filepath.Join(abs, ".git")); err == nil && info.Mode().IsRegular() {
+		for parent := filepath.Dir(abs); ; parent = filepath.Dir(parent) {
+			if gitmodules(parent)[abs] {
+				is = true
+				break
+			}
+			if parent == filepath.Dir(parent) {
+				break
+			}
+		}
+	}
+	if submodules.dirs == nil {
+		submodules.dirs = make(map[string]bool)
+	}
+	submodules.dirs[abs] = is
+	if is && verbose {
+		fmt.Fprintf(os.Stderr, "Found submodule %s\n", dir)
+	}
+	return is
+}
+
+// gitmodules returns the absolute paths of the submodules that the
+// .gitmodules file in dir lists, if it has one. The caller holds the lock
+// on submodules.
+func gitmodules(dir string) map[string]bool {
+	if list, ok := submodules.lists[dir]; ok {
+		return list
+	}
+	list := make(map[string]bool)
+	if f, err := os.Open(filepath.Join(dir, ".gitmodules")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), "=")
+			if ok && strings.TrimSpace(key) == "path" {
+				list[filepath.Join(dir, filepath.FromSlash(strings.TrimSpace(value)))] = true
+			}
+		}
+		f.Close()
+	}
+	if submodules.lists == nil {
+		submodules.lists = make(map[string]map[string]bool)
+	}
+	submodules.lists[dir] = list
+	return list
+}
+
+// submoduleOf returns the submodule that the file at path is in, as a
+// directory named the way path is, or "" if it isn't in one.
+func submoduleOf(path string, verbose bool) string {
+	dir := filepath.Dir(path)
+	submodules.Lock()
+	sub, ok := submodules.of[dir]
+	submodules.Unlock()
+	if ok {
+		return sub
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if isSubmodule(d, verbose) {
+			sub = d
+			break
+		}
+		if d == filepath.Dir(d) {
+			break
+		}
+	}
+	submodules.Lock()
+	if submodules.of == nil {
+		submodules.of = make(map[string]string)
+	}
+	submodules.of[dir] = sub
+	submodules.Unlock()
+	return sub
+}
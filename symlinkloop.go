@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// symlinkLoop reports whether the symlink at p, found while walking, would
+// lead a walk that followed it round in a loop, and if so where to: either
+// the directory it points to holds the link itself, or following the chain
+// of links never ends.
+func symlinkLoop(p string) (string, bool) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", false
+	}
+	target, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		// EvalSymlinks gives up on a chain of links too long to follow,
+		// which is how a chain that loops ends.
+		if errors.Is(err, syscall.ELOOP) || strings.Contains(err.Error(), "too many links") {
+			return "itself", true
+		}
+		return "", false
+	}
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		return "", false
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return "", false
+	}
+	if dir == target || strings.HasPrefix(dir, strings.TrimSuffix(target, string(filepath.Separator))+string(filepath.Separator)) {
+		return target, true
+	}
+	return "", false
+}
@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// ttyColumns can't ask the terminal for its size on this platform, so
+// terminalWidth falls back to $COLUMNS.
+func ttyColumns(f *os.File) int { return 0 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// tokenCacheMin is the smallest text whose token count is cached; shorter
+// ones are counted faster than they could be looked up on the next run.
+const tokenCacheMin = 4096
+
+// tokenCacheMax is the most counts the cache file keeps, those used in the
+// latest run first.
+const tokenCacheMax = 100_000
+
+// A tokenCache remembers, across runs, how many tokens an exact tokenizer
+// counted in each text it was given, by the SHA-256 of the text, so that
+// files that haven't changed since the last run aren't counted again. A
+// changed file has a different digest, so its old count is never used.
+//
+// The cache file holds one "<hex digest> <count>" line per text.
+type tokenCache struct {
+	path  string
+	mu    sync.Mutex // guards prev and used; files may be counted concurrently
+	prev  map[[sha256.Size]byte]int
+	used  map[[sha256.Size]byte]int // counts looked up or made in this run
+	dirty bool                      // whether used holds counts the file lacks
+}
+
+// tokenCachePath returns the cache file for the encoding name.
+func tokenCachePath(name string) string {
+	return filepath.Join(tokenizerDir(), name+".tokencache")
+}
+
+// loadTokenCache reads the cache at path. A missing cache is not an error;
+// every file is then counted.
+func loadTokenCache(path string) (*tokenCache, error) {
+	c := &tokenCache{path: path, prev: make(map[[sha256.Size]byte]int), used: make(map[[sha256.Size]byte]int)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		digest, count, ok := bytes.Cut(scanner.Bytes(), []byte(" "))
+		var sum [sha256.Size]byte
+		if !ok || hex.DecodedLen(len(digest)) != len(sum) {
+			continue
+		}
+		if _, err := hex.Decode(sum[:], digest); err != nil {
+			continue
+		}
+		if n, err := strconv.Atoi(string(count)); err == nil {
+			c.prev[sum] = n
+		}
+	}
+	return c, scanner.Err()
+}
+
+// count returns the number of tokens in text, from the cache if it is there,
+// or else from counting with count and remembering the result.
+func (c *tokenCache) count(text []byte, count func([]byte) int) int {
+	sum := sha256.Sum256(text)
+	c.mu.Lock()
+	n, ok := c.used[sum]
+	if !ok {
+		if n, ok = c.prev[sum]; ok {
+			c.used[sum] = n
+		}
+	}
+	c.mu.Unlock()
+	if ok {
+		return n
+	}
+	n = count(text)
+	c.mu.Lock()
+	c.used[sum] = n
+	c.dirty = true
+	c.mu.Unlock()
+	return n
+}
+
+// save writes the cache, if it gained any counts since it was read: the
+// counts used in this run, then as many of the others as fit.
+func (c *tokenCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".llm-cat-tokencache-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	n := 0
+	for sum, count := range c.used {
+		if n == tokenCacheMax {
+			break
+		}
+		fmt.Fprintf(w, "%x %d\n", sum, count)
+		n++
+	}
+	for sum, count := range c.prev {
+		if n == tokenCacheMax {
+			break
+		}
+		if _, ok := c.used[sum]; !ok {
+			fmt.Fprintf(w, "%x %d\n", sum, count)
+			n++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import "sort"
+
+// bytesPerToken is the rough average number of bytes per token for source
+// code and English text with current tokenizers.
+const bytesPerToken = 4
+
+// estimateTokens returns the approximate number of tokens in n bytes.
+func estimateTokens(n int64) int64 {
+	return (n + bytesPerToken - 1) / bytesPerToken
+}
+
+// modelContextTokens lists the context window, in tokens, of models that
+// -model knows about. To support another model, add it here.
+var modelContextTokens = map[string]int64{
+	"claude-3-5-haiku":  200_000,
+	"claude-3-5-sonnet": 200_000,
+	"claude-3-opus":     200_000,
+	"claude-sonnet-4":   200_000,
+	"gemini-1.5-flash":  1_048_576,
+	"gemini-1.5-pro":    2_097_152,
+	"gpt-4":             8_192,
+	"gpt-4-turbo":       128_000,
+	"gpt-4.1":           1_047_576,
+	"gpt-4o":            128_000,
+	"gpt-4o-mini":       128_000,
+	"llama-3.1-70b":     128_000,
+	"o3":                200_000,
+}
+
+// knownModels returns the names in modelContextTokens, sorted.
+func knownModels() []string {
+	names := make([]string, 0, len(modelContextTokens))
+	for name := range modelContextTokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A resolver returns the local files that the source file at path, with
+// contents src, imports. Paths that can't be resolved are left out.
+type resolver func(path string, src []byte) []string
+
+// resolvers maps lower-case file extensions to the resolver for -trace. Files
+// of other types are included but not followed. To support another language,
+// add a resolver here.
+var resolvers = map[string]resolver{
+	".js":  resolveJS,
+	".jsx": resolveJS,
+	".mjs": resolveJS,
+	".cjs": resolveJS,
+	".ts":  resolveJS,
+	".tsx": resolveJS,
+	".mts": resolveJS,
+	".cts": resolveJS,
+}
+
+// traceDeps returns entry followed by every local file it transitively
+// imports, in the order they are first found.
+func traceDeps(entry string) ([]string, error) {
+	if _, err := os.Stat(entry); err != nil {
+		return nil, err
+	}
+	entry = tidyPath(entry)
+	seen := map[string]bool{entry: true}
+	order := []string{entry}
+	for i := 0; i < len(order); i++ {
+		path := order[i]
+		resolve, ok := resolvers[strings.ToLower(filepath.Ext(path))]
+		if !ok {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return order, err
+		}
+		for _, dep := range resolve(path, src) {
+			dep = tidyPath(dep)
+			if !seen[dep] {
+				seen[dep] = true
+				order = append(order, dep)
+			}
+		}
+	}
+	return order, nil
+}
+
+// tidyPath cleans path and, if it is inside the current directory, makes it
+// relative to it, so that one file reached by different routes (such as
+// "../app/x.js" from inside app) gets one name.
+func tidyPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Clean(path)
+	}
+	return rel
+}
+
+var (
+	// import x from "./x", import "./x", export { x } from "./x",
+	// import type { T } from "./t"
+	jsImportFrom = regexp.MustCompile(`(?m)^\s*(?:import|export)\b[^'"]*?['"]([^'"\n]+)['"]`)
+	// require("./x"), import("./x")
+	jsRequire = regexp.MustCompile(`\b(?:require|import)\s*\(\s*['"]([^'"\n]+)['"]\s*\)`)
+)
+
+// jsExtensions are tried, in order, for JavaScript and TypeScript imports
+// that leave off the extension.
+var jsExtensions = []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts", ".json"}
+
+// resolveJS finds relative imports and requires in JavaScript or TypeScript.
+// Bare specifiers such as "react" refer to packages and are ignored, as is
+// anything that resolves into node_modules.
+func resolveJS(path string, src []byte) []string {
+	var deps []string
+	for _, re := range []*regexp.Regexp{jsImportFrom, jsRequire} {
+		for _, m := range re.FindAllSubmatch(src, -1) {
+			spec := string(m[1])
+			if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+				continue
+			}
+			if dep, ok := resolveJSSpec(filepath.Join(filepath.Dir(path), spec)); ok {
+				deps = append(deps, dep)
+			}
+		}
+	}
+	return deps
+}
+
+// resolveJSSpec finds the file that a relative import of base refers to:
+// base itself, base with one of jsExtensions, or an index file in the
+// directory base.
+func resolveJSSpec(base string) (string, bool) {
+	candidates := []string{base}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, filepath.Join(base, "index"+ext))
+	}
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && info.Mode().IsRegular() {
+			for _, part := range strings.Split(filepath.ToSlash(c), "/") {
+				if part == "node_modules" {
+					return "", false
+				}
+			}
+			return c, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// transformNames are the content transforms -transforms can list, in the
+// order they run without it. Those marked =N take a number, as their flags
+// do.
+var transformNames = []string{
+	"nfc",
+	"trim-trailing-blank-lines",
+	"dedup-blocks=N",
+	"strip-imports",
+	"compact-imports",
+	"editorconfig",
+	"reindent=N",
+	"comment-out",
+	"show-whitespace",
+	"wrap=N",
+	"max-lines=N",
+	"html-escape",
+}
+
+// applyTransforms sets opts from a -transforms list, such as
+// "nfc,max-lines=50,comment-out": the transforms listed are turned on, in
+// that order, and every other one is turned off, whatever its own flag
+// says. An empty list turns them all off.
+func applyTransforms(list string, opts *options) error {
+	opts.nfc, opts.trimBlankEnds, opts.stripImports, opts.compactImports, opts.editorconfig = false, false, false, false, false
+	opts.dedupBlocks, opts.reindent, opts.commentOut, opts.showWhitespace = 0, 0, false, false
+	opts.wrap, opts.maxLines, opts.htmlEscape = 0, 0, false
+	opts.transforms = []string{}
+	if list == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, t := range strings.Split(list, ",") {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(t), "=")
+		if seen[name] {
+			return fmt.Errorf("%s is listed twice", name)
+		}
+		seen[name] = true
+		n := 0
+		if numeric := slices.Contains(transformNames, name+"=N"); numeric || hasArg {
+			var err error
+			if n, err = strconv.Atoi(arg); !numeric || err != nil || n <= 0 {
+				return fmt.Errorf("bad transform %q (want %s)", t, transformUsage(name))
+			}
+		}
+		switch name {
+		case "nfc":
+			opts.nfc = true
+		case "trim-trailing-blank-lines":
+			opts.trimBlankEnds = true
+		case "dedup-blocks":
+			opts.dedupBlocks = n
+		case "strip-imports":
+			opts.stripImports = true
+		case "compact-imports":
+			opts.compactImports = true
+		case "editorconfig":
+			opts.editorconfig = true
+		case "reindent":
+			opts.reindent = n
+		case "comment-out":
+			opts.commentOut = true
+		case "show-whitespace":
+			opts.showWhitespace = true
+		case "wrap":
+			opts.wrap = n
+		case "max-lines":
+			opts.maxLines = n
+		case "html-escape":
+			opts.htmlEscape = true
+		default:
+			return fmt.Errorf("unknown transform %q (known transforms: %s)", name, strings.Join(transformNames, ", "))
+		}
+		opts.transforms = append(opts.transforms, name)
+	}
+	return nil
+}
+
+// transformUsage returns how the transform name is written in a list.
+func transformUsage(name string) string {
+	if slices.Contains(transformNames, name+"=N") {
+		return name + "=N, N > 0"
+	}
+	return name + ", without a value"
+}
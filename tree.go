@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A treeFile is a file printed in a -tree-json dump, and the number of
+// content bytes printed of it.
+type treeFile struct {
+	name string
+	size int64
+}
+
+// treeJSON returns the -tree-json object for the files printed: each
+// directory is an object holding its files and directories by name, and
+// each file is the number of bytes printed of it. Directories only appear
+// on the way to a printed file, so none is empty, unless
+// -tree-json-empty-dirs adds the rest of those walked. A leading / of an
+// absolute path is a directory named "/".
+func (r *runner) treeJSON() []byte {
+	root := make(map[string]any)
+	for _, f := range r.tree {
+		parts := r.treePath(f.name)
+		dir := treeDir(root, parts[:len(parts)-1])
+		// A file printed twice, such as for two line ranges, counts
+		// the bytes of both.
+		size, _ := dir[parts[len(parts)-1]].(int64)
+		dir[parts[len(parts)-1]] = size + f.size
+	}
+	for _, d := range r.treeDirs {
+		if parts := r.treePath(d); parts[0] != "." {
+			treeDir(root, parts)
+		}
+	}
+	// Maps are marshaled with their keys sorted, so the tree is in a
+	// stable order whatever order the files were printed in.
+	b, _ := json.Marshal(root)
+	return b
+}
+
+// treePath splits the display name of path into the names along the way to
+// it in the tree.
+func (r *runner) treePath(path string) []string {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(r.displayName(path))), "/")
+	if parts[0] == "" {
+		parts[0] = "/"
+	}
+	return parts
+}
+
+// treeDir returns the directory object at the end of parts under root,
+// creating the ones on the way as needed.
+func treeDir(root map[string]any, parts []string) map[string]any {
+	dir := root
+	for _, part := range parts {
+		sub, ok := dir[part].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			dir[part] = sub
+		}
+		dir = sub
+	}
+	return dir
+}
+
+// walkedDirs returns the directories a dump of the command-line arguments
+// in files walks into, for -tree-json-empty-dirs: each directory argument
+// it recurses into, and the ones below it that aren't hidden, pruned or too
+// deep.
+func walkedDirs(files *pathList, opts *options) []string {
+	// The dump has already said which directories it pruned.
+	quiet := *opts
+	quiet.verbose = false
+	var dirs []string
+	for _, arg := range files.paths {
+		path, depth, ok := splitDepth(arg)
+		if !ok {
+			depth = opts.depth
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() || !ok && !opts.recurse {
+			continue
+		}
+		// As in walkPath, a symlink to a directory is walked through a
+		// trailing separator.
+		root := path
+		if li, err := os.Lstat(path); err == nil && li.Mode()&os.ModeSymlink != 0 {
+			root += string(filepath.Separator)
+		}
+		filepath.Walk(root, func(p string, i os.FileInfo, err error) error {
+			if err != nil || !i.IsDir() {
+				return nil
+			}
+			hidden := p != root && strings.HasPrefix(i.Name(), ".") && !opts.all
+			if p != root && (hidden || prunes(p, &quiet) || tooDeep(root, p, depth)) {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, p)
+			return nil
+		})
+	}
+	return dirs
+}
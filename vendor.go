@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// vendoredMinFiles is how many files a directory with a license of its own
+// must hold before -skip-vendored takes it for a vendored copy of another
+// project, rather than a part of this one that happens to carry a license.
+const vendoredMinFiles = 50
+
+// vendoredDirNames are directories that only ever hold installed packages.
+var vendoredDirNames = map[string]bool{
+	"node_modules":  true,
+	"site-packages": true,
+	"dist-packages": true,
+}
+
+// vendoredMarkers are files whose presence in a vendor directory, or next
+// to it, shows it was filled by a package manager: Go modules and Composer.
+var vendoredMarkers = []string{"vendor/modules.txt", "vendor/autoload.php", "go.mod", "composer.json"}
+
+// vendored returns why the directory at dir looks like third-party code for
+// -skip-vendored, or "" if it doesn't. The checks are conservative: a
+// directory of installed packages, a vendor directory made by Go modules
+// or Composer, or a directory with its own license file and at least
+// vendoredMinFiles files below it.
+func vendored(dir string) string {
+	base := filepath.Base(dir)
+	if vendoredDirNames[base] {
+		return base
+	}
+	if base == "vendor" {
+		parent := filepath.Dir(dir)
+		for _, marker := range vendoredMarkers {
+			if _, err := os.Stat(filepath.Join(parent, filepath.FromSlash(marker))); err == nil {
+				return "vendor directory next to " + filepath.Base(marker)
+			}
+		}
+	}
+	if license := licenseFile(dir); license != "" && countFiles(dir, vendoredMinFiles) >= vendoredMinFiles {
+		return fmt.Sprintf("own %s and %d+ files", license, vendoredMinFiles)
+	}
+	return ""
+}
+
+// licenseFile returns the name of a license file directly in dir, or "".
+func licenseFile(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		name := strings.ToUpper(e.Name())
+		if !e.IsDir() && (strings.HasPrefix(name, "LICENSE") || strings.HasPrefix(name, "LICENCE") || strings.HasPrefix(name, "COPYING")) {
+			return e.Name()
+		}
+	}
+	return ""
+}
+
+// countFiles counts the files below dir, stopping at limit.
+func countFiles(dir string, limit int) int {
+	n := 0
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		if n >= limit {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// fastWalk walks the tree at root as filepath.Walk does, calling fn for each
+// file and directory in the same lexical order and handling its results the
+// same way, but reads up to workers directories at once. As each directory
+// is walked, the subdirectories in it are listed ahead in the background, so
+// that on a slow filesystem the walk rarely waits for one. A directory that
+// fn skips costs one wasted listing, but nothing below it is read. The
+// entries of each directory are lstat'ed up to stats at a time.
+func fastWalk(root string, workers, stats int, fn filepath.WalkFunc) error {
+	w := &fastWalker{sem: make(chan struct{}, max(workers, 1)), stats: stats, fn: fn}
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		var l *dirListing
+		if info.IsDir() {
+			l = w.list(root)
+		}
+		err = w.walk(root, info, l)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// A dirListing is a directory's entries, sorted by name, with what Lstat
+// says about each one, read in the background.
+type dirListing struct {
+	names []string
+	infos []os.FileInfo
+	errs  []error // Lstat errors, by entry
+	err   error   // the error reading the directory, if any
+	done  chan struct{}
+}
+
+type fastWalker struct {
+	sem   chan struct{} // limits how many directories are read at once
+	stats int           // entries of a directory lstat'ed at once
+	fn    filepath.WalkFunc
+}
+
+// list starts reading the directory dir and returns its listing, which is
+// ready once done is closed.
+func (w *fastWalker) list(dir string) *dirListing {
+	l := &dirListing{done: make(chan struct{})}
+	go func() {
+		w.sem <- struct{}{}
+		defer func() {
+			<-w.sem
+			close(l.done)
+		}()
+		f, err := os.Open(dir)
+		if err != nil {
+			l.err = err
+			return
+		}
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			l.err = err
+			return
+		}
+		sort.Strings(names)
+		l.names = names
+		l.infos = make([]os.FileInfo, len(names))
+		l.errs = make([]error, len(names))
+		w.lstat(dir, l)
+	}()
+	return l
+}
+
+// lstat fills in l.infos and l.errs for the entries of dir.
+func (w *fastWalker) lstat(dir string, l *dirListing) {
+	if w.stats <= 1 {
+		for i, name := range l.names {
+			l.infos[i], l.errs[i] = lstatFile(filepath.Join(dir, name))
+		}
+		return
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(w.stats, len(l.names)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				l.infos[i], l.errs[i] = lstatFile(filepath.Join(dir, l.names[i]))
+			}
+		}()
+	}
+	for i := range l.names {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// walk walks path, described by info; l is its listing if it is a
+// directory. It mirrors the walk function inside filepath.Walk.
+func (w *fastWalker) walk(path string, info os.FileInfo, l *dirListing) error {
+	if !info.IsDir() {
+		return w.fn(path, info, nil)
+	}
+	<-l.done
+	err := w.fn(path, info, l.err)
+	if l.err != nil || err != nil {
+		return err
+	}
+	subs := make([]*dirListing, len(l.names))
+	for i, name := range l.names {
+		if l.errs[i] == nil && l.infos[i].IsDir() {
+			subs[i] = w.list(filepath.Join(path, name))
+		}
+	}
+	for i, name := range l.names {
+		p := filepath.Join(path, name)
+		if l.errs[i] != nil {
+			if err := w.fn(p, l.infos[i], l.errs[i]); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := w.walk(p, l.infos[i], subs[i]); err != nil && (!l.infos[i].IsDir() || err != filepath.SkipDir) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// makeTree creates a tree of dirs directories, nested up to three deep,
+// each holding files files, under a temporary directory, and returns it.
+func makeTree(tb testing.TB, dirs, files int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%d", d%7), fmt.Sprintf("e%d", d%5), fmt.Sprintf("f%d", d))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		for f := 0; f < files; f++ {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.go", f)), nil, 0o644); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+// walked returns the paths walk visits under root, in order, skipping the
+// directories named e0.
+func walked(t *testing.T, root string, walk func(string, filepath.WalkFunc) error) []string {
+	var paths []string
+	err := walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "e0" {
+			return filepath.SkipDir
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestFastWalk(t *testing.T) {
+	root := makeTree(t, 40, 3)
+	want := walked(t, root, filepath.Walk)
+	for _, workers := range []int{0, 1, 4, 32} {
+		for _, stats := range []int{0, 8} {
+			got := walked(t, root, func(root string, fn filepath.WalkFunc) error {
+				return fastWalk(root, workers, stats, fn)
+			})
+			if !slices.Equal(got, want) {
+				t.Errorf("fastWalk with %d workers and %d stats visited %d paths in a different order from filepath.Walk's %d", workers, stats, len(got), len(want))
+			}
+		}
+	}
+	missing := filepath.Join(root, "missing")
+	if err := fastWalk(missing, 4, 0, func(path string, info os.FileInfo, err error) error { return err }); !os.IsNotExist(err) {
+		t.Errorf("fastWalk(%s) = %v, want a not-exist error", missing, err)
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	root := makeTree(b, 500, 10)
+	noop := func(path string, info os.FileInfo, err error) error { return err }
+	b.Run("filepath.Walk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			filepath.Walk(root, noop)
+		}
+	})
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("fastWalk-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fastWalk(root, workers, 0, noop)
+			}
+		})
+	}
+	for _, stats := range []int{4, 16} {
+		b.Run(fmt.Sprintf("fastWalk-1-stats-%d", stats), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fastWalk(root, 1, stats, noop)
+			}
+		})
+	}
+}
+
+// slowStats makes each stat of a file named or walked take an extra 200µs,
+// as a round trip to a network filesystem might, until the benchmark ends.
+func slowStats(b *testing.B) {
+	stat, lstat := statFile, lstatFile
+	statFile = func(path string) (os.FileInfo, error) {
+		time.Sleep(200 * time.Microsecond)
+		return stat(path)
+	}
+	lstatFile = func(path string) (os.FileInfo, error) {
+		time.Sleep(200 * time.Microsecond)
+		return lstat(path)
+	}
+	b.Cleanup(func() { statFile, lstatFile = stat, lstat })
+}
+
+func BenchmarkWalkSlowStats(b *testing.B) {
+	root := makeTree(b, 50, 20)
+	slowStats(b)
+	noop := func(path string, info os.FileInfo, err error) error { return err }
+	for _, stats := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("fastWalk-1-stats-%d", stats), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fastWalk(root, 1, stats, noop)
+			}
+		})
+	}
+}
+
+// listed returns the paths of the files under root, as a -files-from list
+// would name them.
+func listed(tb testing.TB, root string) []string {
+	tb.Helper()
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return paths
+}
+
+func TestPrefetchStats(t *testing.T) {
+	root := makeTree(t, 20, 5)
+	paths := append(listed(t, root), filepath.Join(root, "missing"), "-")
+	for _, workers := range []int{1, 4, 32} {
+		var got []string
+		err := prefetchStats(&pathList{paths: paths}, workers, func(path string, info os.FileInfo) {
+			got = append(got, path)
+			if stat := path != "-" && filepath.Base(path) != "missing"; (info != nil) != stat {
+				t.Errorf("%d workers: %s came with info %v", workers, path, info)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, paths) {
+			t.Errorf("%d workers: walked %d paths in a different order from the %d listed", workers, len(got), len(paths))
+		}
+	}
+
+	// A dump selects the same files either way, named or walked.
+	opts := testOptions()
+	opts.recurse, opts.namesOnly = true, true
+	args := append(listed(t, root)[:10], root)
+	want := dumpFiles(t, opts, args...)
+	opts.statPrefetch = 8
+	if got := dumpFiles(t, opts, args...); got != want {
+		t.Errorf("-concurrent-stat-prefetch 8 printed:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func BenchmarkPrefetchStats(b *testing.B) {
+	paths := listed(b, makeTree(b, 50, 20))
+	slowStats(b)
+	opts := &options{}
+	noop := func(fileEntry) error { return nil }
+	for _, workers := range []int{0, 4, 16} {
+		b.Run(fmt.Sprintf("prefetch-%d", workers), func(b *testing.B) {
+			opts.statPrefetch = workers
+			for i := 0; i < b.N; i++ {
+				walkFiles(&pathList{paths: paths}, opts, noop)
+			}
+		})
+	}
+}
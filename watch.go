@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// pollInterval is how often -watch checks the selected files for changes.
+// Once it sees one, it waits until the selection has gone settleTime
+// without changing again, so that saving several files produces one dump.
+const (
+	pollInterval = 500 * time.Millisecond
+	settleTime   = 300 * time.Millisecond
+)
+
+// watch dumps files to path (or stdout) as dumpTo does, then again each time
+// a selected file changes, appears or goes away, until it is interrupted.
+func (r *runner) watch(files *pathList, path string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	state := r.snapshot(files)
+	for {
+		if err := r.dumpTo(files, path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		dest := path
+		if dest == "" {
+			dest = "stdout"
+		}
+		fmt.Fprintf(os.Stderr, "[%s] Dumped to %s; watching for changes\n", time.Now().Format(time.TimeOnly), dest)
+
+		for changed := false; !changed; {
+			if !sleep(ctx, pollInterval) {
+				return
+			}
+			next := r.snapshot(files)
+			for next != state {
+				if !sleep(ctx, settleTime) {
+					return
+				}
+				state, next = next, r.snapshot(files)
+				changed = true
+			}
+		}
+	}
+}
+
+// sleep waits for d, and reports false if ctx was canceled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// snapshot describes the current selection for files: each selected path
+// with its size and modification time, and any error selecting it. Two
+// snapshots differ if anything that would change the dump has changed.
+func (r *runner) snapshot(files *pathList) string {
+	var b strings.Builder
+	for _, f := range files.paths {
+		err := processPath(f, nil, r.opts, func(e fileEntry) error {
+			if info, err := os.Stat(e.path); err == nil {
+				fmt.Fprintf(&b, "%s\x00%d\x00%d\n", e.path, info.Size(), info.ModTime().UnixNano())
+			} else {
+				fmt.Fprintf(&b, "%s\x00%v\n", e.path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(&b, "%s\x00%v\n", f, err)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+// terminalWidth returns the number of columns of the terminal f, from the
+// terminal itself or else $COLUMNS, or 0 if it can't tell.
+func terminalWidth(f *os.File) int {
+	if n := ttyColumns(f); n > 0 {
+		return n
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// wrapFilter breaks lines longer than width columns for -wrap. A rune counts
+// as one column and a tab runs to the next multiple of 8; ANSI escape
+// sequences, such as -highlight's, take no room.
+type wrapFilter struct {
+	width int
+}
+
+func (w wrapFilter) filter(line []byte) []byte {
+	text, eol := splitEOL(line)
+	var b bytes.Buffer
+	col := 0
+	for i := 0; i < len(text); {
+		if text[i] == '\x1b' {
+			end := ansiEnd(text, i)
+			b.Write(text[i:end])
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRune(text[i:])
+		n := 1
+		if r == '\t' {
+			n = 8 - col%8
+		}
+		if col > 0 && col+n > w.width {
+			b.WriteByte('\n')
+			col = 0
+			if r == '\t' {
+				n = 8
+			}
+		}
+		b.Write(text[i : i+size])
+		col += n
+		i += size
+	}
+	if b.Len() == len(text) {
+		return line
+	}
+	b.Write(eol)
+	return b.Bytes()
+}
+
+func (wrapFilter) flush() []byte { return nil }
+
+// ansiEnd returns the index just past the escape sequence starting at
+// text[i], which is ESC.
+func ansiEnd(text []byte, i int) int {
+	j := i + 1
+	if j >= len(text) || text[j] != '[' {
+		return j
+	}
+	for j++; j < len(text); j++ {
+		if c := text[j]; c >= 0x40 && c <= 0x7e {
+			return j + 1
+		}
+	}
+	return j
+}
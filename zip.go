@@ -0,0 +1,51 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// zipTo writes files into a new zip archive at path, each under its relative
+// path, in place of printing them, and then reports on the run.
+func (r *runner) zipTo(files *pathList, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	r.zip = zip.NewWriter(f)
+	r.out = io.Discard
+	r.dump(files)
+	err = r.zip.Close()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	r.zip = nil
+	if err != nil {
+		return err
+	}
+	return r.finish()
+}
+
+// writeZip adds body to the -zip archive as name, through the usual filters
+// unless raw is set. Names are placed as -output-dir would place them. It
+// returns the number of bytes read from body.
+func (r *runner) writeZip(name string, body io.Reader, raw bool) (int64, error) {
+	rel, err := mirrorPath(".", name)
+	if err != nil {
+		return 0, err
+	}
+	hdr := &zip.FileHeader{Name: filepath.ToSlash(rel), Method: zip.Deflate}
+	if fi, err := os.Stat(name); err == nil && fi.Mode().IsRegular() {
+		hdr.Modified = fi.ModTime()
+	}
+	w, err := r.zip.CreateHeader(hdr)
+	if err != nil {
+		return 0, err
+	}
+	if raw {
+		return io.Copy(w, body)
+	}
+	return copyContents(w, body, name, r.opts)
+}